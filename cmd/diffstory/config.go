@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mchowning/diffstory/internal/config"
+)
+
+// maxShownValueLength is the longest value `diffstory config` prints in full
+const maxShownValueLength = 60
+
+// filterLevels are the values -filter accepts
+var filterLevels = []string{"low", "medium", "high"}
+
+// loadConfig reads the config for workDir and applies the flags given on the
+// command line, which take precedence over every config file. Without a
+// config file, the defaults are used. A repository config with a problem is
+// ignored with a warning written to warnings; only the user's own config
+// can stop diffstory.
+func loadConfig(workDir string, debug bool, filter string, warnings io.Writer) (*config.Config, error) {
+	if filter != "" && !slices.Contains(filterLevels, filter) {
+		return nil, fmt.Errorf("invalid -filter %q (want %s)", filter, strings.Join(filterLevels, ", "))
+	}
+
+	cfg, err := config.LoadForDir(workDir)
+	var repoErr *config.RepoConfigError
+	if errors.As(err, &repoErr) {
+		fmt.Fprintf(warnings, "Warning: %v\n", repoErr)
+	} else if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = config.Default()
+	}
+
+	if debug {
+		cfg.Override("debugLoggingEnabled", "-debug flag", func(c *config.Config) { c.DebugLoggingEnabled = true })
+	}
+	if filter != "" {
+		cfg.Override("defaultFilterLevel", "-filter flag", func(c *config.Config) { c.DefaultFilterLevel = filter })
+	}
+	return cfg, nil
+}
+
+// runConfig prints the effective config for workDir and where each setting
+// came from
+func runConfig(args []string, out io.Writer, workDir string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(out)
+	debug := fs.Bool("debug", false, "Show the config as with diffstory -debug")
+	filter := fs.String("filter", "", "Show the config as with diffstory -filter")
+	fs.Usage = func() {
+		fmt.Fprintf(out, `Usage:
+  diffstory config [flags]
+
+Prints each setting in effect here and where it came from: the repository's
+%s, your own config file (which overrides it), a flag (which
+overrides both), or a default.
+
+Flags:
+`, config.RepoConfigFile)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(workDir, *debug, *filter, out)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	v := reflect.ValueOf(*cfg)
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		source, ok := cfg.Sources[name]
+		if name == "-" || !ok {
+			continue
+		}
+		value, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, shortenValue(string(value)), source)
+	}
+	return tw.Flush()
+}
+
+// shortenValue cuts long values, such as reviewer instructions, to fit a line
+func shortenValue(value string) string {
+	runes := []rune(value)
+	if len(runes) <= maxShownValueLength {
+		return value
+	}
+	return string(runes[:maxShownValueLength-3]) + "..."
+}
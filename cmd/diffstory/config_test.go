@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/config"
+)
+
+func TestRunConfig_ShowsWhereEachSettingCameFrom(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repoPath := filepath.Join(root, config.RepoConfigFile)
+	content := `{"exclude": ["*.lock"], "defaultFilterLevel": "medium", "reviewerInstructions": "` + strings.Repeat("x", 100) + `"}`
+	if err := os.WriteFile(repoPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runConfig([]string{"-filter", "high"}, &out, root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		lines[fields[0]] = fields[1:]
+	}
+	expected := map[string][]string{
+		"exclude":              {`["*.lock"]`, repoPath},
+		"defaultFilterLevel":   {`"high"`, "-filter", "flag"},
+		"maxBatchBytes":        {"200000", config.SourceDefault},
+		"reviewerInstructions": {`"` + strings.Repeat("x", 56) + "...", repoPath},
+	}
+	for name, want := range expected {
+		if got := strings.Join(lines[name], " "); got != strings.Join(want, " ") {
+			t.Errorf("%s: expected %q, got %q", name, strings.Join(want, " "), got)
+		}
+	}
+	if _, ok := lines["llmCommand"]; ok {
+		t.Errorf("expected unset settings left out, got:\n%s", out.String())
+	}
+}
+
+func TestRunConfig_IgnoresRepoConfigItCannotUse(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repoPath := filepath.Join(root, config.RepoConfigFile)
+	if err := os.WriteFile(repoPath, []byte(`{"llmCommand": ["sh", "-c", "curl evil"], "exclude": ["*.lock"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runConfig(nil, &out, root); err != nil {
+		t.Fatalf("expected the repository config to be ignored, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Warning: ignoring "+repoPath) {
+		t.Errorf("expected a warning naming the file, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "*.lock") {
+		t.Errorf("expected none of the repository's settings, got:\n%s", out.String())
+	}
+}
+
+func TestRunConfig_RejectsUnknownFilterLevel(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	var out bytes.Buffer
+	if err := runConfig([]string{"-filter", "everything"}, &out, t.TempDir()); err == nil {
+		t.Error("expected an error for an unknown filter level")
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mchowning/diffstory/internal/config"
+	"github.com/mchowning/diffstory/internal/logging"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/prompt"
+	"github.com/mchowning/diffstory/internal/storage"
+	"github.com/mchowning/diffstory/internal/tui"
+)
+
+// errIncompleteClassification is returned by runGenerate when the LLM left
+// hunks out of the story after every repair attempt, after they have been
+// reported
+var errIncompleteClassification = errors.New("incomplete classification")
+
+// generateSources are the names -source accepts besides the labels of
+// configured diff sources
+var generateSources = []string{"uncommitted", "staged", "base", "commit", "range"}
+
+// runGenerate generates a review of workDir without the viewer, reporting
+// progress to stderr. The review is saved to store, or written to the -out
+// file, where "-" is stdout.
+func runGenerate(ctx context.Context, args []string, stdout, stderr io.Writer, workDir string, store *storage.Store) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	source := fs.String("source", "uncommitted", "Changes to review")
+	contextFile := fs.String("context-file", "", "File holding the context given to the LLM")
+	outPath := fs.String("out", "", "Write the review to this file instead of the review cache")
+	incremental := fs.Bool("incremental", false, "Keep the cached review's classification, placing only new hunks")
+	force := fs.Bool("force", false, "Ask the LLM even if the classification is cached")
+	twoPass := fs.Bool("two-pass", false, "Classify into an outline, then write each section's narrative separately")
+	debug := fs.Bool("debug", false, "Enable debug logging to /tmp/diffstory.log")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, `Usage:
+  diffstory generate [flags]
+  diffstory generate -source commit <ref> [flags]
+  diffstory generate -source range <start>..<end> [flags]
+
+Generates a review of the changes in the current directory without the
+viewer, as the generate dialog does, reporting progress to stderr. The review
+is saved to the review cache, where a running viewer picks it up, unless -out
+is given. Exits with status 2 if the LLM leaves hunks out of the story after
+every repair attempt, and 1 on any other failure.
+
+Flags:
+  -source         Changes to review: uncommitted, staged, base (changes since
+                  the base branch), commit <ref>, range <start>..<end>, or the
+                  label of a configured diff source (default: uncommitted)
+  -context-file   File holding the context given to the LLM (default: the
+                  reviewerInstructions setting, or the built-in guidance)
+  -out            Write the review to this file instead of the review cache;
+                  "-" writes it to stdout
+  -incremental    Keep the cached review's classification, placing only new
+                  hunks
+  -force          Ask the LLM even if the classification is cached
+  -two-pass       Classify the hunks into an outline, then write each
+                  section's what and why in a request of its own (default:
+                  the twoPassGeneration setting)
+  -debug          Enable debug logging to /tmp/diffstory.log
+`)
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(workDir, *debug, "", stderr)
+	if err != nil {
+		return err
+	}
+	if *twoPass {
+		cfg.Override("twoPassGeneration", "-two-pass flag", func(c *config.Config) { c.TwoPassGeneration = true })
+	}
+	diffSource, err := resolveGenerateSource(*source, positional, workDir, cfg)
+	if err != nil {
+		return err
+	}
+
+	reviewContext := tui.DefaultReviewerInstructions
+	if cfg.ReviewerInstructions != "" {
+		reviewContext = cfg.ReviewerInstructions
+	}
+	if *contextFile != "" {
+		data, err := os.ReadFile(*contextFile)
+		if err != nil {
+			return fmt.Errorf("failed to read context file: %w", err)
+		}
+		reviewContext = strings.TrimSpace(string(data))
+	}
+
+	resolved := tui.ResolveLLMCommand(cfg, tui.DefaultLookPath)
+	if resolved.Error != "" {
+		return errors.New(strings.ReplaceAll(resolved.Error, "\n\n", " "))
+	}
+	prompts, err := prompt.Load(config.PromptDirs(workDir)...)
+	if err != nil {
+		return err
+	}
+
+	params := tui.GenerateParams{
+		DiffCommand:          diffSource.Command,
+		DiffSource:           diffSource.Label,
+		LLM:                  resolved.Provider,
+		Context:              reviewContext,
+		Incremental:          *incremental,
+		MaxBatchBytes:        cfg.MaxBatchBytes,
+		MaxRepairAttempts:    max(cfg.MaxRepairAttempts, 0),
+		Prompts:              prompts,
+		Exclude:              cfg.Exclude,
+		CacheMaxBytes:        max(cfg.LLMCacheMaxBytes, 0),
+		Force:                *force,
+		TwoPass:              cfg.TwoPassGeneration,
+		NarrativeConcurrency: max(cfg.NarrativeConcurrency, 1),
+		Progress: func(step string) {
+			fmt.Fprintln(stderr, step)
+		},
+	}
+	if *outPath != "" {
+		params.Output = func(review model.Review) error {
+			return writeReview(review, *outPath, stdout)
+		}
+	}
+
+	logger := logging.Setup(cfg.DebugLoggingEnabled)
+	fmt.Fprintf(stderr, "Generating a review of %q with %s\n", diffSource.Label, resolved.Provider.Name())
+	switch msg := tui.Generate(ctx, workDir, store, logger, params).(type) {
+	case tui.GenerateSuccessMsg:
+		if *outPath != "" && *outPath != "-" {
+			fmt.Fprintf(stderr, "Wrote the review to %s\n", *outPath)
+		}
+		return nil
+	case tui.GenerateValidationFailedMsg:
+		fmt.Fprintf(stderr, "The LLM left %d of %d hunks out of the story after %d repair attempts:\n", len(msg.Missing), len(msg.Hunks), msg.Attempts)
+		for _, id := range msg.Missing {
+			fmt.Fprintf(stderr, "  %s\n", id)
+		}
+		return errIncompleteClassification
+	case tui.GenerateCancelledMsg:
+		return errors.New("generation cancelled")
+	case tui.GenerateErrorMsg:
+		return msg.Err
+	default:
+		return fmt.Errorf("unexpected generation result %T", msg)
+	}
+}
+
+// parseInterspersed parses args with fs, allowing flags to follow the
+// positional arguments it returns
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// resolveGenerateSource returns the diff source named by -source, with the
+// commit or range given after it resolved to commit hashes
+func resolveGenerateSource(name string, positional []string, workDir string, cfg *config.Config) (tui.DiffSource, error) {
+	wantArgs := 0
+	if name == "commit" || name == "range" {
+		wantArgs = 1
+	}
+	if len(positional) != wantArgs {
+		switch name {
+		case "commit":
+			return tui.DiffSource{}, errors.New("-source commit needs a commit, as in: -source commit HEAD~1")
+		case "range":
+			return tui.DiffSource{}, errors.New("-source range needs a range, as in: -source range main..HEAD")
+		default:
+			return tui.DiffSource{}, fmt.Errorf("unexpected arguments: %s", strings.Join(positional, " "))
+		}
+	}
+
+	defaults := tui.DefaultDiffSources(tui.DetectBaseBranch(workDir))
+	switch name {
+	case "uncommitted":
+		return defaults[0], nil
+	case "staged":
+		return defaults[1], nil
+	case "base":
+		return defaults[2], nil
+	case "commit":
+		commit, err := resolveCommitRef(workDir, positional[0])
+		if err != nil {
+			return tui.DiffSource{}, err
+		}
+		return tui.CommitDiffSource(commit), nil
+	case "range":
+		start, end, ok := strings.Cut(positional[0], "..")
+		if !ok || start == "" || end == "" || strings.HasPrefix(end, ".") {
+			return tui.DiffSource{}, fmt.Errorf("invalid range %q: expected <start>..<end>", positional[0])
+		}
+		startCommit, err := resolveCommitRef(workDir, start)
+		if err != nil {
+			return tui.DiffSource{}, err
+		}
+		endCommit, err := resolveCommitRef(workDir, end)
+		if err != nil {
+			return tui.DiffSource{}, err
+		}
+		return tui.RangeDiffSource(startCommit, endCommit), nil
+	}
+	for _, configured := range cfg.DiffSources {
+		if configured.Label == name {
+			return tui.DiffSource{Label: configured.Label, Command: configured.Command}, nil
+		}
+	}
+	return tui.DiffSource{}, fmt.Errorf("unknown -source %q: use %s, or the label of a configured diff source", name, strings.Join(generateSources, ", "))
+}
+
+// resolveCommitRef returns the abbreviated hash of the commit ref names in
+// workDir, as the TUI's commit picker lists it, so that a review generated
+// here is stored under the same diff source as one generated there
+func resolveCommitRef(workDir, ref string) (string, error) {
+	output, err := runDiffCommand(workDir, []string{"git", "rev-parse", "--verify", "-q", "--short", ref + "^{commit}"})
+	if err != nil {
+		return "", fmt.Errorf("unknown commit %q", ref)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// writeReview writes review as JSON to path, or to stdout when path is "-"
+func writeReview(review model.Review, path string, stdout io.Writer) error {
+	review.SchemaVersion = model.CurrentSchemaVersion
+	data, err := json.MarshalIndent(review, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/config"
+)
+
+const (
+	generatePatch    = "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n"
+	generateClassify = `{"title":"T","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"S","what":"w","why":"y","hunks":[{"id":"a.go::1","importance":"high"}]}]}]}`
+)
+
+// setupGenerate writes a user config whose LLM runs script, with the prompt
+// as $1, and whose "Fixture" diff source prints generatePatch
+func setupGenerate(t *testing.T, script string, extra map[string]any) {
+	t.Helper()
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", t.TempDir())
+	settings := map[string]any{
+		"llmCommand":     []string{"sh", "-c", script, "llm"},
+		"promptDelivery": "argument",
+		"diffSources":    []config.DiffSource{{Label: "Fixture", Command: []string{"printf", "%s", generatePatch}}},
+	}
+	for name, value := range extra {
+		settings[name] = value
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(configHome, "diffstory"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "diffstory", "config.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunGenerate_WritesReviewToFile(t *testing.T) {
+	setupGenerate(t, "echo '"+generateClassify+"'", nil)
+	store := newTestStore(t)
+	workDir := t.TempDir()
+	outPath := filepath.Join(t.TempDir(), "review.json")
+
+	var stdout, stderr bytes.Buffer
+	err := runGenerate(context.Background(), []string{"-source", "Fixture", "-out", outPath}, &stdout, &stderr, workDir, store)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\nstderr: %s", err, stderr.String())
+	}
+
+	review, err := loadReviewFromFile(outPath)
+	if err != nil {
+		t.Fatalf("failed to load written review: %v", err)
+	}
+	if review.DiffSource != "Fixture" || len(review.Chapters) != 1 || review.Chapters[0].Sections[0].Hunks[0].ID != "a.go::1" {
+		t.Errorf("unexpected review: %+v", review)
+	}
+	if entries, _ := store.All(); len(entries) != 0 {
+		t.Errorf("expected nothing saved to the store with -out, got %d reviews", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(store.BaseDir(), "transcripts")); !os.IsNotExist(err) {
+		t.Errorf("expected no transcript archived in the store with -out, got err %v", err)
+	}
+	if review.Generation == nil || review.Generation.Transcript != "" {
+		t.Errorf("expected generation metadata without a transcript, got %+v", review.Generation)
+	}
+	for _, want := range []string{"Found 1 hunks", "Classifying 1 hunks", "Wrote the review to " + outPath} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("expected progress %q, got:\n%s", want, stderr.String())
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", stdout.String())
+	}
+}
+
+func TestRunGenerate_OutDashWritesToStdout(t *testing.T) {
+	setupGenerate(t, "echo '"+generateClassify+"'", nil)
+
+	var stdout, stderr bytes.Buffer
+	err := runGenerate(context.Background(), []string{"-source", "Fixture", "-out", "-"}, &stdout, &stderr, t.TempDir(), newTestStore(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v\nstderr: %s", err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"a.go::1"`) {
+		t.Errorf("expected the review on stdout, got %q", stdout.String())
+	}
+}
+
+func TestRunGenerate_SavesToStore(t *testing.T) {
+	setupGenerate(t, "echo '"+generateClassify+"'", nil)
+	store := newTestStore(t)
+	workDir := t.TempDir()
+
+	var stdout, stderr bytes.Buffer
+	if err := runGenerate(context.Background(), []string{"-source", "Fixture"}, &stdout, &stderr, workDir, store); err != nil {
+		t.Fatalf("unexpected error: %v\nstderr: %s", err, stderr.String())
+	}
+
+	path, err := store.PathForReview(workDir, "", "Fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := store.ReadPath(path)
+	if err != nil {
+		t.Fatalf("expected the review in the store: %v", err)
+	}
+	if review.Title != "T" {
+		t.Errorf("Title = %q, want %q", review.Title, "T")
+	}
+	if !strings.Contains(stderr.String(), "Saved the review to "+path) {
+		t.Errorf("expected the saved path in the progress, got:\n%s", stderr.String())
+	}
+}
+
+func TestRunGenerate_ContextFile(t *testing.T) {
+	setupGenerate(t, `case "$1" in *'User context: Mind the API'*) ;; *) exit 1 ;; esac
+echo '`+generateClassify+`'`, nil)
+	contextPath := filepath.Join(t.TempDir(), "context.txt")
+	if err := os.WriteFile(contextPath, []byte("Mind the API\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-source", "Fixture", "-context-file", contextPath, "-out", "-"}
+	if err := runGenerate(context.Background(), args, &stdout, &stderr, t.TempDir(), newTestStore(t)); err != nil {
+		t.Fatalf("expected the context in the prompt: %v\nstderr: %s", err, stderr.String())
+	}
+}
+
+func TestRunGenerate_IncompleteClassificationFails(t *testing.T) {
+	empty := `{"title":"T","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"S","what":"w","why":"y","hunks":[]}]}]}`
+	setupGenerate(t, "echo '"+empty+"'", map[string]any{"maxRepairAttempts": 0})
+	outPath := filepath.Join(t.TempDir(), "review.json")
+
+	var stdout, stderr bytes.Buffer
+	err := runGenerate(context.Background(), []string{"-source", "Fixture", "-out", outPath}, &stdout, &stderr, t.TempDir(), newTestStore(t))
+	if !errors.Is(err, errIncompleteClassification) {
+		t.Fatalf("expected errIncompleteClassification, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "  a.go::1\n") {
+		t.Errorf("expected the missing hunk to be listed, got:\n%s", stderr.String())
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("expected no review to be written, got %v", err)
+	}
+}
+
+func TestRunGenerate_TwoPassFlag(t *testing.T) {
+	setupGenerate(t, `case "$1" in
+*'Outline Only'*) echo '`+generateClassify+`' ;;
+*'writing one section'*) echo '{"what":"w in depth","why":"y in depth"}' ;;
+*) exit 1 ;;
+esac`, nil)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-source", "Fixture", "-two-pass", "-out", "-"}
+	if err := runGenerate(context.Background(), args, &stdout, &stderr, t.TempDir(), newTestStore(t)); err != nil {
+		t.Fatalf("unexpected error: %v\nstderr: %s", err, stderr.String())
+	}
+	var review struct {
+		Chapters []struct {
+			Sections []struct{ What, Why string }
+		}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &review); err != nil {
+		t.Fatal(err)
+	}
+	if section := review.Chapters[0].Sections[0]; section.What != "w in depth" || section.Why != "y in depth" {
+		t.Errorf("expected the section's narrative, got %+v", section)
+	}
+	if !strings.Contains(stderr.String(), "Writing the narrative of each section") {
+		t.Errorf("expected the narrative pass in the progress, got:\n%s", stderr.String())
+	}
+}
+
+// commitRepo returns a git repository with two commits and the abbreviated
+// hashes of its first and second commit
+func commitRepo(t *testing.T) (string, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %s failed: %v", strings.Join(args, " "), err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	git("init", "-q")
+	for _, message := range []string{"first", "second"} {
+		git("-c", "user.name=Test", "-c", "user.email=test@test.com", "commit", "-q", "--allow-empty", "-m", message)
+	}
+	return dir, git("rev-parse", "--short", "HEAD~1"), git("rev-parse", "--short", "HEAD")
+}
+
+func TestResolveGenerateSource(t *testing.T) {
+	repo, first, second := commitRepo(t)
+	cfg := config.Default()
+	cfg.DiffSources = []config.DiffSource{{Label: "Jujutsu change", Command: []string{"jj", "diff", "--git"}}}
+	tests := []struct {
+		name        string
+		source      string
+		positional  []string
+		wantLabel   string
+		wantCommand []string
+		wantErr     string
+	}{
+		{name: "staged", source: "staged", wantLabel: "Staged changes", wantCommand: []string{"git", "diff", "--cached", "--no-color", "--no-ext-diff"}},
+		{name: "commit", source: "commit", positional: []string{"HEAD~1"}, wantLabel: "Commit: " + first, wantCommand: []string{"git", "show", first, "--no-color", "--no-ext-diff", "--format="}},
+		{name: "range", source: "range", positional: []string{"HEAD~1..HEAD"}, wantLabel: "Range: " + first + ".." + second, wantCommand: []string{"git", "diff", first + ".." + second, "--no-color", "--no-ext-diff"}},
+		{name: "configured", source: "Jujutsu change", wantLabel: "Jujutsu change", wantCommand: []string{"jj", "diff", "--git"}},
+		{name: "commit without ref", source: "commit", wantErr: "needs a commit"},
+		{name: "unknown commit", source: "commit", positional: []string{"nope"}, wantErr: `unknown commit "nope"`},
+		{name: "symmetric range", source: "range", positional: []string{"main...HEAD"}, wantErr: "invalid range"},
+		{name: "range with unknown end", source: "range", positional: []string{"HEAD..nope"}, wantErr: `unknown commit "nope"`},
+		{name: "extra argument", source: "staged", positional: []string{"HEAD"}, wantErr: "unexpected arguments: HEAD"},
+		{name: "unknown", source: "stash", wantErr: `unknown -source "stash"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := resolveGenerateSource(tt.source, tt.positional, repo, cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if source.Label != tt.wantLabel || !reflect.DeepEqual(source.Command, tt.wantCommand) {
+				t.Errorf("got %q %v, want %q %v", source.Label, source.Command, tt.wantLabel, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestParseInterspersed_AllowsFlagsAfterArguments(t *testing.T) {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	source := fs.String("source", "", "")
+	out := fs.String("out", "", "")
+
+	positional, err := parseInterspersed(fs, []string{"-source", "commit", "HEAD~1", "-out", "review.json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *source != "commit" || *out != "review.json" || !reflect.DeepEqual(positional, []string{"HEAD~1"}) {
+		t.Errorf("got source %q, out %q, arguments %v", *source, *out, positional)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+// runList prints every cached review with the directory it belongs to
+func runList(store *storage.Store, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, `Usage:
+  diffstory list
+
+Lists cached reviews with their directory, branch, diff source, title,
+creation time and section count.
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	entries, err := store.All()
+	if err != nil {
+		return fmt.Errorf("reading cache: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(out, "No cached reviews in %s\n", store.BaseDir())
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIRECTORY\tBRANCH\tSOURCE\tTITLE\tCREATED\tSECTIONS")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n",
+			e.WorkingDirectory,
+			orDash(e.Branch),
+			orDash(e.DiffSource),
+			orDash(e.Title),
+			formatCreatedAt(e.CreatedAt),
+			e.SectionCount)
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatCreatedAt(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
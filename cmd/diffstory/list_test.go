@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func newTestStore(t *testing.T) *storage.Store {
+	t.Helper()
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	return store
+}
+
+func writeTestReview(t *testing.T, store *storage.Store, review model.Review) {
+	t.Helper()
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+}
+
+func TestRunList_ShowsEachReview(t *testing.T) {
+	store := newTestStore(t)
+	dir := t.TempDir()
+	writeTestReview(t, store, model.Review{
+		WorkingDirectory: dir,
+		Title:            "Add auth",
+		Branch:           "feature-x",
+		DiffSource:       "Staged changes",
+		CreatedAt:        time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Chapters: []model.Chapter{{ID: "c", Sections: []model.Section{
+			{ID: "1"}, {ID: "2"},
+		}}},
+	})
+
+	var out bytes.Buffer
+	if err := runList(store, nil, &out); err != nil {
+		t.Fatalf("runList failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got:\n%s", out.String())
+	}
+	if !strings.HasPrefix(lines[0], "DIRECTORY") {
+		t.Errorf("expected header row, got %q", lines[0])
+	}
+	for _, want := range []string{dir, "feature-x", "Staged changes", "Add auth", "2025-03-01"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("expected row to contain %q, got %q", want, lines[1])
+		}
+	}
+	if !strings.HasSuffix(lines[1], "2") {
+		t.Errorf("expected row to end with section count 2, got %q", lines[1])
+	}
+}
+
+func TestRunList_EmptyCache(t *testing.T) {
+	store := newTestStore(t)
+
+	var out bytes.Buffer
+	if err := runList(store, nil, &out); err != nil {
+		t.Fatalf("runList failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "No cached reviews") {
+		t.Errorf("expected empty message, got %q", out.String())
+	}
+}
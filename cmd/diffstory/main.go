@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mchowning/diffstory/internal/config"
 	"github.com/mchowning/diffstory/internal/logging"
 	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/prompt"
 	"github.com/mchowning/diffstory/internal/storage"
 	"github.com/mchowning/diffstory/internal/tui"
 	"github.com/mchowning/diffstory/internal/watcher"
@@ -32,11 +38,40 @@ func main() {
 		return
 	}
 
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "list", "prune":
+			os.Exit(runCacheCommand(os.Args[1], os.Args[2:]))
+		case "validate":
+			os.Exit(runValidateCommand(os.Args[2:]))
+		case "config":
+			cwd, err := os.Getwd()
+			if err == nil {
+				err = runConfig(os.Args[2:], os.Stdout, cwd)
+			}
+			if err != nil && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "generate":
+			os.Exit(runGenerateCommand(os.Args[2:]))
+		case "schema":
+			if err := runSchema(os.Args[2:], os.Stdout); err != nil && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Viewer mode (default)
 	debug := flag.Bool("debug", false, "Enable debug logging to /tmp/diffstory.log")
+	filter := flag.String("filter", "", "Lowest importance shown at startup: low, medium or high")
 	reviewPath := flag.String("review", "", "Load review from JSON file (bypasses watcher)")
 	flag.Parse()
-	runViewer(*debug, *reviewPath)
+	runViewer(*debug, *filter, *reviewPath)
 }
 
 func printUsage() {
@@ -44,29 +79,94 @@ func printUsage() {
 
 Usage:
   diffstory [flags]
+  diffstory list               List cached reviews
+  diffstory prune [flags]      Remove cached reviews for deleted directories
+  diffstory validate <file>... Check review JSON files for problems
+  diffstory schema [name]      Print the JSON Schema of review or classification files
+  diffstory config [flags]     Show the settings in effect and where each came from
+  diffstory generate [flags]   Generate a review without the viewer, for scripts and hooks
 
 Flags:
   -debug    Enable debug logging to /tmp/diffstory.log
+  -filter   Lowest importance shown at startup: low, medium or high
   -review   Load review from JSON file (bypasses watcher)
 
 See README.md for configuration options and keybindings.
 `)
 }
 
-func runViewer(debug bool, reviewPath string) {
-	// Force TrueColor for consistent rendering in headless environments (e.g., VHS recordings)
-	lipgloss.SetColorProfile(termenv.TrueColor)
+// runCacheCommand runs a subcommand that operates on the review cache and
+// returns the process exit code
+func runCacheCommand(name string, args []string) int {
+	store, err := storage.NewStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create storage: %v\n", err)
+		return 1
+	}
 
-	// Load config
-	cfg, err := config.Load()
+	switch name {
+	case "list":
+		err = runList(store, args, os.Stdout)
+	case "prune":
+		err = runPrune(store, args, os.Stdout, time.Now())
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
 	if err != nil {
-		log.Printf("Warning: config error: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
 	}
+	return 0
+}
 
-	// Enable logging if flag or config enables it
-	debugEnabled := debug || (cfg != nil && cfg.DebugLoggingEnabled)
-	logger := logging.Setup(debugEnabled)
-	logger.Info("diffstory starting", "debug_flag", debug, "config_enabled", cfg != nil && cfg.DebugLoggingEnabled)
+// runValidateCommand runs `diffstory validate` and returns the process exit
+// code: 1 if any file has problems or the command fails
+func runValidateCommand(args []string) int {
+	err := runValidate(args, os.Stdout, runDiffCommand)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errInvalidReviews):
+		return 1
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+}
+
+// runGenerateCommand runs `diffstory generate` and returns the process exit
+// code: 2 if the LLM left hunks out of the story, 1 if generation fails
+func runGenerateCommand(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	store, err := storage.NewStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create storage: %v\n", err)
+		return 1
+	}
+
+	err = runGenerate(ctx, args, os.Stdout, os.Stderr, cwd, store)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errIncompleteClassification):
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+}
+
+func runViewer(debug bool, filter, reviewPath string) {
+	// Force TrueColor for consistent rendering in headless environments (e.g., VHS recordings)
+	lipgloss.SetColorProfile(termenv.TrueColor)
 
 	// Get current working directory
 	cwd, err := os.Getwd()
@@ -74,6 +174,17 @@ func runViewer(debug bool, reviewPath string) {
 		log.Fatalf("Failed to get working directory: %v", err)
 	}
 
+	// Load config: the repository's, then the user's, then flags
+	cfg, err := loadConfig(cwd, debug, filter, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Enable logging if flag or config enables it
+	logger := logging.Setup(cfg.DebugLoggingEnabled)
+	logger.Info("diffstory starting", "debug_flag", debug, "debug_source", cfg.Sources["debugLoggingEnabled"])
+
 	// Load review from file if provided (bypasses watcher)
 	var initialReview *model.Review
 	if reviewPath != "" {
@@ -92,22 +203,34 @@ func runViewer(debug bool, reviewPath string) {
 		log.Fatalf("Failed to create storage: %v", err)
 	}
 
-	var opts []tui.ModelOption
-	if initialReview != nil {
-		opts = append(opts, tui.WithInitialReview(initialReview))
+	// Prompt templates are checked now rather than when generating
+	prompts, err := prompt.Load(config.PromptDirs(cwd)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	m := tui.NewModel(cwd, cfg, store, logger, opts...)
-	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	opts := []tui.ModelOption{tui.WithPrompts(prompts)}
+	if initialReview != nil {
+		// A review file's provenance is not ours to run
+		opts = append(opts, tui.WithInitialReview(initialReview), tui.WithoutStaleCheck())
+	}
 
 	// Only create watcher if not in direct review mode
+	var w *watcher.Watcher
 	if initialReview == nil {
-		w, err := watcher.NewWithStore(cwd, store, logger)
+		w, err = watcher.NewWithStore(cwd, store, logger)
 		if err != nil {
 			log.Fatalf("Failed to create watcher: %v", err)
 		}
 		defer w.Close()
+		opts = append(opts, tui.WithReviewShown(w.Show))
+	}
+
+	m := tui.NewModel(cwd, cfg, store, logger, opts...)
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
+	if w != nil {
 		w.Start()
 
 		// Pump watcher events to TUI
@@ -115,7 +238,7 @@ func runViewer(debug bool, reviewPath string) {
 			for {
 				select {
 				case review := <-w.Reviews:
-					p.Send(tui.ReviewReceivedMsg{Review: review})
+					p.Send(tui.ReviewReceivedMsg{Review: review, Watched: true})
 				case <-w.Cleared:
 					p.Send(tui.ReviewClearedMsg{})
 				case err := <-w.Errors:
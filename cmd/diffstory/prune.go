@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+// runPrune removes cached reviews for deleted checkouts and, optionally,
+// reviews older than a given age
+func runPrune(store *storage.Store, args []string, out io.Writer, now time.Time) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dryRun := fs.Bool("dry-run", false, "Show what would be removed without removing anything")
+	olderThan := fs.String("older-than", "", "Also remove reviews older than this age (e.g. 30d, 2w, 12h)")
+	unknownDirs := fs.Bool("unknown-dirs", false, "Also remove reviews that don't record their working directory")
+	fs.Usage = func() {
+		fmt.Fprint(out, `Usage:
+  diffstory prune [flags]
+
+Removes cached reviews whose working directory no longer exists. With
+-older-than, also removes reviews created before that age. Reviews that don't
+record their working directory are kept unless -unknown-dirs is given. History
+and transcripts are removed once no review of their directory remains. Review
+files that cannot be read are reported and left in place.
+
+Flags:
+  -dry-run        Show what would be removed without removing anything
+  -older-than     Also remove reviews older than this age (e.g. 30d, 2w, 12h)
+  -unknown-dirs   Also remove reviews that don't record their working directory
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var maxAge time.Duration
+	if *olderThan != "" {
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			return err
+		}
+		maxAge = age
+	}
+
+	entries, unreadable, err := store.Scan()
+	if err != nil {
+		return fmt.Errorf("reading cache: %w", err)
+	}
+
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+	}
+
+	var removed []string
+	for _, e := range entries {
+		reason := pruneReason(e, maxAge, *unknownDirs, now)
+		if reason == "" {
+			continue
+		}
+		if !*dryRun {
+			if err := store.Remove(e.Path); err != nil {
+				return fmt.Errorf("removing %s: %w", e.Path, err)
+			}
+		}
+		fmt.Fprintf(out, "%s %s (%s): %s\n", verb, e.WorkingDirectory, orDash(e.Title), reason)
+		removed = append(removed, e.Path)
+	}
+
+	// History and transcripts go with the last review of their directory,
+	// including those left behind by earlier prunes
+	archives, err := store.OrphanedArchives(removed...)
+	if err != nil {
+		return fmt.Errorf("reading archives: %w", err)
+	}
+	for _, dir := range archives {
+		if !*dryRun {
+			if err := store.RemoveArchive(dir); err != nil {
+				return fmt.Errorf("removing %s: %w", dir, err)
+			}
+		}
+		fmt.Fprintf(out, "%s %s: no review of its directory remains\n", verb, dir)
+	}
+
+	for _, f := range unreadable {
+		fmt.Fprintf(out, "Could not read %s, left in place: %v\n", f.Path, f.Err)
+	}
+
+	if *dryRun {
+		fmt.Fprintf(out, "%d of %d reviews would be removed\n", len(removed), len(entries))
+	} else {
+		fmt.Fprintf(out, "%d of %d reviews removed\n", len(removed), len(entries))
+	}
+	return nil
+}
+
+const (
+	reasonMissingDirectory = "working directory no longer exists"
+	reasonUnknownDirectory = "working directory unknown"
+)
+
+// pruneReason returns why an entry should be pruned, or "" to keep it. An
+// entry without a working directory can't be shown to be gone, so only
+// unknownDirs removes it.
+func pruneReason(e storage.ReviewEntry, maxAge time.Duration, unknownDirs bool, now time.Time) string {
+	if e.WorkingDirectory == "" {
+		if unknownDirs {
+			return reasonUnknownDirectory
+		}
+	} else if _, err := os.Stat(e.WorkingDirectory); os.IsNotExist(err) {
+		return reasonMissingDirectory
+	}
+	if maxAge > 0 && !e.CreatedAt.IsZero() && now.Sub(e.CreatedAt) > maxAge {
+		return "older than " + formatAge(maxAge)
+	}
+	return ""
+}
+
+// parseAge parses a duration that may also use d (days) and w (weeks) units
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q: use e.g. 30d, 2w or 12h", s)
+	}
+	return d, nil
+}
+
+func formatAge(d time.Duration) string {
+	day := 24 * time.Hour
+	if d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestRunPrune_RemovesReviewsForMissingDirectories(t *testing.T) {
+	store := newTestStore(t)
+	kept := t.TempDir()
+	gone := filepath.Join(t.TempDir(), "deleted")
+	now := time.Now()
+	writeTestReview(t, store, model.Review{WorkingDirectory: kept, Title: "Kept", CreatedAt: now})
+	writeTestReview(t, store, model.Review{WorkingDirectory: gone, Title: "Gone", CreatedAt: now})
+
+	var out bytes.Buffer
+	if err := runPrune(store, nil, &out, now); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+
+	entries, _ := store.All()
+	if len(entries) != 1 || entries[0].Title != "Kept" {
+		t.Fatalf("expected only the kept review to remain, got %+v", entries)
+	}
+	history, _ := store.History(gone)
+	if len(history) != 0 {
+		t.Errorf("expected history for removed directory to be deleted, got %d entries", len(history))
+	}
+	if !strings.Contains(out.String(), "Removed "+gone) {
+		t.Errorf("expected removal to be reported, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "1 of 2 reviews removed") {
+		t.Errorf("expected summary line, got:\n%s", out.String())
+	}
+}
+
+func TestRunPrune_OlderThanRemovesOldReviews(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	writeTestReview(t, store, model.Review{WorkingDirectory: t.TempDir(), Title: "Old", CreatedAt: now.Add(-40 * 24 * time.Hour)})
+	writeTestReview(t, store, model.Review{WorkingDirectory: t.TempDir(), Title: "Recent", CreatedAt: now.Add(-24 * time.Hour)})
+
+	var out bytes.Buffer
+	if err := runPrune(store, []string{"-older-than", "30d"}, &out, now); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+
+	entries, _ := store.All()
+	if len(entries) != 1 || entries[0].Title != "Recent" {
+		t.Fatalf("expected only the recent review to remain, got %+v", entries)
+	}
+	if !strings.Contains(out.String(), "older than 30d") {
+		t.Errorf("expected reason in output, got:\n%s", out.String())
+	}
+}
+
+func TestRunPrune_DryRunKeepsFiles(t *testing.T) {
+	store := newTestStore(t)
+	gone := filepath.Join(t.TempDir(), "deleted")
+	writeTestReview(t, store, model.Review{WorkingDirectory: gone, Title: "Gone", CreatedAt: time.Now()})
+
+	var out bytes.Buffer
+	if err := runPrune(store, []string{"-dry-run"}, &out, time.Now()); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+
+	entries, _ := store.All()
+	if len(entries) != 1 {
+		t.Errorf("dry run should not remove reviews, got %d remaining", len(entries))
+	}
+	if !strings.Contains(out.String(), "Would remove "+gone) {
+		t.Errorf("expected dry-run report, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(store.BaseDir()); err != nil {
+		t.Errorf("base dir should still exist: %v", err)
+	}
+}
+
+func TestRunPrune_OlderThanRemovesHistoryWithLastReview(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	old, mixed := t.TempDir(), t.TempDir()
+	writeTestReview(t, store, model.Review{WorkingDirectory: old, Title: "Old", CreatedAt: now.Add(-40 * 24 * time.Hour)})
+	writeTestReview(t, store, model.Review{WorkingDirectory: mixed, Branch: "a", Title: "Mixed old", CreatedAt: now.Add(-40 * 24 * time.Hour)})
+	writeTestReview(t, store, model.Review{WorkingDirectory: mixed, Branch: "b", Title: "Mixed recent", CreatedAt: now.Add(-24 * time.Hour)})
+	if _, err := store.WriteTranscript(old, model.Transcript{}); err != nil {
+		t.Fatalf("WriteTranscript failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runPrune(store, []string{"-older-than", "30d"}, &out, now); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+
+	if history, _ := store.History(old); len(history) != 0 {
+		t.Errorf("expected history to go with the directory's last review, got %d entries", len(history))
+	}
+	if _, err := os.Stat(filepath.Join(store.BaseDir(), "transcripts", storage.HashDirectory(old))); !os.IsNotExist(err) {
+		t.Errorf("expected transcripts to go with the directory's last review, got %v", err)
+	}
+	if history, _ := store.History(mixed); len(history) != 2 {
+		t.Errorf("expected history to stay while a review of the directory remains, got %d entries", len(history))
+	}
+	if !strings.Contains(out.String(), "no review of its directory remains") {
+		t.Errorf("expected the history removal to be reported, got:\n%s", out.String())
+	}
+}
+
+func TestRunPrune_SweepsArchivesLeftBehind(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := store.WriteTranscript(t.TempDir(), model.Transcript{}); err != nil {
+		t.Fatalf("WriteTranscript failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runPrune(store, nil, &out, time.Now()); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+	if orphaned, _ := store.OrphanedArchives(); len(orphaned) != 0 {
+		t.Errorf("expected archives without a review to be removed, got %v", orphaned)
+	}
+}
+
+func TestRunPrune_ReportsUnreadableFiles(t *testing.T) {
+	store := newTestStore(t)
+	corrupt := filepath.Join(store.BaseDir(), "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runPrune(store, nil, &out, time.Now()); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Could not read "+corrupt) {
+		t.Errorf("expected the unreadable file to be reported, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(corrupt); err != nil {
+		t.Errorf("unreadable file should be left in place: %v", err)
+	}
+}
+
+func TestRunPrune_KeepsReviewsWithoutWorkingDirectory(t *testing.T) {
+	store := newTestStore(t)
+	path := filepath.Join(store.BaseDir(), "nodir.json")
+	if err := os.WriteFile(path, []byte(`{"title":"No directory","chapters":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runPrune(store, nil, &out, time.Now()); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected a review without a working directory to be kept: %v\n%s", err, out.String())
+	}
+
+	out.Reset()
+	if err := runPrune(store, []string{"-unknown-dirs"}, &out, time.Now()); err != nil {
+		t.Fatalf("runPrune failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected -unknown-dirs to remove the review, got err %v", err)
+	}
+	if !strings.Contains(out.String(), "working directory unknown") {
+		t.Errorf("expected the reason in the output, got:\n%s", out.String())
+	}
+}
+
+func TestRunPrune_InvalidAge(t *testing.T) {
+	store := newTestStore(t)
+
+	var out bytes.Buffer
+	if err := runPrune(store, []string{"-older-than", "soon"}, &out, time.Now()); err == nil {
+		t.Error("expected error for invalid age")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.input)
+		if err != nil {
+			t.Errorf("parseAge(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "0d", "-1w", "xd", "soon"} {
+		if _, err := parseAge(bad); err == nil {
+			t.Errorf("parseAge(%q) should fail", bad)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
@@ -14,20 +14,27 @@ func loadReviewFromFile(path string) (*model.Review, error) {
 		return nil, fmt.Errorf("reading file: %w", err)
 	}
 
-	var review model.Review
-	if err := json.Unmarshal(data, &review); err != nil {
+	review, err := model.ParseReview(data)
+	if err != nil {
+		if errors.Is(err, model.ErrSchemaTooNew) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("parsing JSON: %w", err)
 	}
 
 	for _, chapter := range review.Chapters {
 		for _, section := range chapter.Sections {
 			for _, hunk := range section.Hunks {
-				if hunk.Importance != "" && !model.ValidImportance(hunk.Importance) {
+				// Required, as in the published schema and diffstory validate
+				if hunk.Importance == "" {
+					return nil, fmt.Errorf("missing importance in file %s (want high, medium or low)", hunk.File)
+				}
+				if !model.ValidImportance(hunk.Importance) {
 					return nil, fmt.Errorf("invalid importance %q in file %s", hunk.Importance, hunk.File)
 				}
 			}
 		}
 	}
 
-	return &review, nil
+	return review, nil
 }
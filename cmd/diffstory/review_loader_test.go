@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -96,7 +97,8 @@ func TestLoadReviewFromFile_InvalidImportance(t *testing.T) {
 }
 
 func TestLoadReviewFromFile_EmptyImportance(t *testing.T) {
-	// Create temp file with empty importance (should be valid)
+	// Create temp file with empty importance, which the review schema and
+	// diffstory validate reject
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, "empty_importance.json")
 	reviewJSON := `{
@@ -124,10 +126,49 @@ func TestLoadReviewFromFile_EmptyImportance(t *testing.T) {
 
 	review, err := loadReviewFromFile(path)
 
+	if err == nil || !strings.Contains(err.Error(), "missing importance") {
+		t.Fatalf("expected a missing importance error, got %v", err)
+	}
+	if review != nil {
+		t.Fatal("expected review to be nil on error")
+	}
+}
+
+func TestLoadReviewFromFile_FlatSections(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "flat.json")
+	reviewJSON := `{
+		"workingDirectory": "/test",
+		"title": "Old Review",
+		"sections": [{"id": "sec-1", "title": "First", "hunks": []}]
+	}`
+	if err := os.WriteFile(path, []byte(reviewJSON), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	review, err := loadReviewFromFile(path)
+
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if review == nil {
-		t.Fatal("expected review to be non-nil")
+	if review.SectionCount() != 1 {
+		t.Errorf("expected 1 section, got %d", review.SectionCount())
+	}
+}
+
+func TestLoadReviewFromFile_NewerSchemaVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "future.json")
+	if err := os.WriteFile(path, []byte(`{"schemaVersion": 99, "chapters": []}`), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	_, err := loadReviewFromFile(path)
+
+	if err == nil {
+		t.Fatal("expected error for newer schema version")
+	}
+	if strings.Contains(err.Error(), "parsing JSON") {
+		t.Errorf("newer schema should not be reported as a parse error: %v", err)
 	}
 }
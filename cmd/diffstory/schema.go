@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/mchowning/diffstory/internal/schema"
+	"github.com/mchowning/diffstory/internal/tui"
+)
+
+// schemas are the formats `diffstory schema` can describe
+var schemas = map[string]func() *schema.Schema{
+	"review":         schema.Review,
+	"classification": tui.ClassificationSchema,
+}
+
+// runSchema prints the JSON Schema of a review or classification file
+func runSchema(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, `Usage:
+  diffstory schema [review|classification]
+
+Prints a JSON Schema generated from diffstory's own types:
+
+  review           Review files, as loaded with -review (default)
+  classification   The classification an LLM returns during generation
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one schema name")
+	}
+
+	name := "review"
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+	generate, ok := schemas[name]
+	if !ok {
+		return fmt.Errorf("unknown schema %q (want review or classification)", name)
+	}
+
+	data, err := schema.MarshalIndent(generate())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunSchema_DefaultsToReview(t *testing.T) {
+	var out bytes.Buffer
+	if err := runSchema(nil, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if decoded["title"] != "diffstory review" {
+		t.Errorf("title = %v, want the review schema", decoded["title"])
+	}
+}
+
+func TestRunSchema_Classification(t *testing.T) {
+	var out bytes.Buffer
+	if err := runSchema([]string{"classification"}, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"LLMHunkRef"`) {
+		t.Errorf("expected the classification schema, got:\n%s", out.String())
+	}
+}
+
+func TestRunSchema_UnknownName(t *testing.T) {
+	var out bytes.Buffer
+	if err := runSchema([]string{"bogus"}, &out); err == nil {
+		t.Error("expected an error for an unknown schema")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/review"
+)
+
+// errInvalidReviews is returned by runValidate when any file has problems,
+// after they have been reported
+var errInvalidReviews = errors.New("invalid reviews")
+
+// defaultDiffCommand is compared against by -check-diff when neither the flag
+// nor the review's provenance names a command
+var defaultDiffCommand = []string{"git", "diff", "HEAD", "--no-color", "--no-ext-diff"}
+
+// diffRunner runs a diff command in dir and returns its output
+type diffRunner func(dir string, command []string) (string, error)
+
+// fileResult is the validation outcome for one file, as printed by -json
+type fileResult struct {
+	File     string           `json:"file"`
+	Valid    bool             `json:"valid"`
+	Problems []review.Problem `json:"problems"`
+}
+
+// runValidate checks review files and reports every problem found in each
+func runValidate(args []string, out io.Writer, runDiff diffRunner) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	jsonOutput := fs.Bool("json", false, "Print results as JSON")
+	checkDiff := fs.Bool("check-diff", false, "Report hunks that are not in the current diff")
+	diffCommand := fs.String("diff-command", "", "Diff command used by -check-diff (default: the review's own, or git diff HEAD)")
+	fs.Usage = func() {
+		fmt.Fprint(out, `Usage:
+  diffstory validate [flags] <review.json>...
+
+Checks review files and reports every problem with its JSON path: missing or
+duplicate IDs, empty chapters and sections, invalid importance and hunks that
+are not well-formed unified diff hunks. Exits with status 1 if any file has
+problems.
+
+Flags:
+  -json           Print results as JSON
+  -check-diff     Also report hunks that are not in the current diff, run in
+                  the review's working directory
+  -diff-command   Diff command used by -check-diff (default: the command the
+                  review was generated from, or "git diff HEAD")
+`)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no review files given")
+	}
+
+	var override []string
+	if *diffCommand != "" {
+		override = strings.Fields(*diffCommand)
+	}
+
+	results := make([]fileResult, 0, fs.NArg())
+	valid := true
+	for _, path := range fs.Args() {
+		problems := validateFile(path, *checkDiff, override, runDiff)
+		if problems == nil {
+			problems = []review.Problem{}
+		}
+		results = append(results, fileResult{File: path, Valid: len(problems) == 0, Problems: problems})
+		valid = valid && len(problems) == 0
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printValidation(out, results)
+	}
+
+	if !valid {
+		return errInvalidReviews
+	}
+	return nil
+}
+
+// validateFile loads and validates one review file. Problems reading or
+// decoding the file are reported at the document root.
+func validateFile(path string, checkDiff bool, diffCommand []string, runDiff diffRunner) []review.Problem {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []review.Problem{{Path: "$", Message: fmt.Sprintf("reading file: %v", err)}}
+	}
+	r, err := model.ParseReview(data)
+	if err != nil {
+		return []review.Problem{{Path: "$", Message: fmt.Sprintf("parsing JSON: %v", err)}}
+	}
+
+	var opts review.ValidateOptions
+	if checkDiff {
+		command := diffCommand
+		if command == nil && r.Provenance != nil && len(r.Provenance.DiffCommand) > 0 {
+			command = r.Provenance.DiffCommand
+		}
+		if command == nil {
+			command = defaultDiffCommand
+		}
+		dir := r.WorkingDirectory
+		if dir == "" {
+			dir = "."
+		}
+
+		output, err := runDiff(dir, command)
+		if err != nil {
+			return append(review.Validate(*r, opts), review.Problem{
+				Path:    "$",
+				Message: fmt.Sprintf("running %s: %v", strings.Join(command, " "), err),
+			})
+		}
+		hunks, err := diff.Parse(output)
+		if err != nil {
+			return append(review.Validate(*r, opts), review.Problem{Path: "$", Message: fmt.Sprintf("parsing current diff: %v", err)})
+		}
+		// Non-nil even for an empty diff, so every hunk is reported
+		opts.CurrentHunks = append([]diff.ParsedHunk{}, hunks...)
+	}
+	return review.Validate(*r, opts)
+}
+
+// printValidation prints results for people, one line per problem
+func printValidation(out io.Writer, results []fileResult) {
+	for _, result := range results {
+		if result.Valid {
+			fmt.Fprintf(out, "%s: ok\n", result.File)
+			continue
+		}
+		noun := "problems"
+		if len(result.Problems) == 1 {
+			noun = "problem"
+		}
+		fmt.Fprintf(out, "%s: %d %s\n", result.File, len(result.Problems), noun)
+		for _, p := range result.Problems {
+			fmt.Fprintf(out, "  %s\n", p)
+		}
+	}
+}
+
+// runDiffCommand runs a diff command with exec in dir
+func runDiffCommand(dir string, command []string) (string, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
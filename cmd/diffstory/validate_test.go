@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validReviewJSON = `{
+	"workingDirectory": "/test",
+	"title": "Test",
+	"chapters": [{
+		"id": "ch-1",
+		"title": "Changes",
+		"sections": [{
+			"id": "s-1",
+			"title": "Update",
+			"hunks": [{"file": "main.go", "startLine": 1, "diff": "@@ -1 +1 @@\n-old\n+new", "importance": "high"}]
+		}]
+	}]
+}`
+
+const invalidReviewJSON = `{
+	"workingDirectory": "/test",
+	"chapters": [{
+		"title": "Changes",
+		"sections": [{
+			"id": "s-1",
+			"hunks": [{"file": "main.go", "diff": "+new", "importance": "urgent"}]
+		}]
+	}]
+}`
+
+func writeReviewFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write review file: %v", err)
+	}
+	return path
+}
+
+func noDiff(dir string, command []string) (string, error) {
+	return "", errors.New("diff should not be run")
+}
+
+func TestRunValidate_ValidFile(t *testing.T) {
+	path := writeReviewFile(t, "review.json", validReviewJSON)
+	var out bytes.Buffer
+
+	if err := runValidate([]string{path}, &out, noDiff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := out.String(); got != path+": ok\n" {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
+
+func TestRunValidate_ReportsProblemsWithPaths(t *testing.T) {
+	path := writeReviewFile(t, "review.json", invalidReviewJSON)
+	var out bytes.Buffer
+
+	err := runValidate([]string{path}, &out, noDiff)
+
+	if !errors.Is(err, errInvalidReviews) {
+		t.Fatalf("expected errInvalidReviews, got %v", err)
+	}
+	output := out.String()
+	for _, want := range []string{
+		path + ": 3 problems",
+		"$.chapters[0].id: chapter id is required",
+		"$.chapters[0].sections[0].hunks[0].importance: invalid importance \"urgent\"",
+		"$.chapters[0].sections[0].hunks[0].diff: not a unified diff hunk",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestRunValidate_JSONOutput(t *testing.T) {
+	valid := writeReviewFile(t, "valid.json", validReviewJSON)
+	invalid := writeReviewFile(t, "invalid.json", invalidReviewJSON)
+	var out bytes.Buffer
+
+	err := runValidate([]string{"-json", valid, invalid}, &out, noDiff)
+
+	if !errors.Is(err, errInvalidReviews) {
+		t.Fatalf("expected errInvalidReviews, got %v", err)
+	}
+	var results []fileResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if !results[0].Valid || results[0].Problems == nil || len(results[0].Problems) != 0 {
+		t.Errorf("expected valid file with an empty problem list, got %+v", results[0])
+	}
+	if results[1].Valid || len(results[1].Problems) != 3 {
+		t.Errorf("expected 3 problems for the invalid file, got %+v", results[1])
+	}
+}
+
+func TestRunValidate_UnreadableFile(t *testing.T) {
+	var out bytes.Buffer
+
+	err := runValidate([]string{"/nonexistent/review.json"}, &out, noDiff)
+
+	if !errors.Is(err, errInvalidReviews) {
+		t.Fatalf("expected errInvalidReviews, got %v", err)
+	}
+	if !strings.Contains(out.String(), "$: reading file") {
+		t.Errorf("expected a reading problem at the root, got %q", out.String())
+	}
+}
+
+func TestRunValidate_NoFiles(t *testing.T) {
+	var out bytes.Buffer
+	if err := runValidate(nil, &out, noDiff); err == nil || errors.Is(err, errInvalidReviews) {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
+
+func TestRunValidate_CheckDiff(t *testing.T) {
+	path := writeReviewFile(t, "review.json", validReviewJSON)
+	var ranIn string
+	var ranCommand []string
+	runDiff := func(dir string, command []string) (string, error) {
+		ranIn, ranCommand = dir, command
+		return "diff --git a/other.go b/other.go\n--- a/other.go\n+++ b/other.go\n@@ -1 +1 @@\n-a\n+b\n", nil
+	}
+	var out bytes.Buffer
+
+	err := runValidate([]string{"-check-diff", "-diff-command", "git diff main", path}, &out, runDiff)
+
+	if !errors.Is(err, errInvalidReviews) {
+		t.Fatalf("expected errInvalidReviews, got %v", err)
+	}
+	if ranIn != "/test" || strings.Join(ranCommand, " ") != "git diff main" {
+		t.Errorf("ran %v in %q, want git diff main in /test", ranCommand, ranIn)
+	}
+	if !strings.Contains(out.String(), "$.chapters[0].sections[0].hunks[0]: hunk in main.go is not in the current diff") {
+		t.Errorf("expected the hunk to be reported, got:\n%s", out.String())
+	}
+}
+
+func TestRunValidate_CheckDiffMatchingHunk(t *testing.T) {
+	path := writeReviewFile(t, "review.json", validReviewJSON)
+	runDiff := func(dir string, command []string) (string, error) {
+		if strings.Join(command, " ") != strings.Join(defaultDiffCommand, " ") {
+			t.Errorf("expected the default diff command, got %v", command)
+		}
+		return "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-old\n+new\n", nil
+	}
+	var out bytes.Buffer
+
+	if err := runValidate([]string{"-check-diff", path}, &out, runDiff); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out.String())
+	}
+}
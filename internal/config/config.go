@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,14 +10,227 @@ import (
 )
 
 type Config struct {
-	LLMCommand          []string `json:"llmCommand"`
-	DiffCommand         []string `json:"diffCommand"`
-	DebugLoggingEnabled bool     `json:"debugLoggingEnabled"`
-	DefaultFilterLevel  string   `json:"defaultFilterLevel"`
+	LLMCommand           []string     `json:"llmCommand"`
+	LLMProvider          *LLMProvider `json:"llmProvider"`
+	PromptDelivery       string       `json:"promptDelivery"`
+	DiffCommand          []string     `json:"diffCommand"`
+	DebugLoggingEnabled  bool         `json:"debugLoggingEnabled"`
+	DefaultFilterLevel   string       `json:"defaultFilterLevel"`
+	MaxBatchBytes        int          `json:"maxBatchBytes"`
+	MaxRepairAttempts    int          `json:"maxRepairAttempts"`
+	LLMCacheMaxBytes     int64        `json:"llmCacheMaxBytes"`
+	TwoPassGeneration    bool         `json:"twoPassGeneration"`
+	NarrativeConcurrency int          `json:"narrativeConcurrency"`
+	ReviewerInstructions string       `json:"reviewerInstructions"`
+	Exclude              []string     `json:"exclude"`
+	DiffSources          []DiffSource `json:"diffSources"`
+
+	// Sources records where each setting's value came from, by JSON name:
+	// a config file's path, a flag, or SourceDefault. Unset settings are
+	// absent.
+	Sources map[string]string `json:"-"`
+}
+
+// LLMProvider configures a built-in HTTP client for an LLM API, used instead
+// of llmCommand
+type LLMProvider struct {
+	Type      string `json:"type"`      // "anthropic" or "openai" (any OpenAI-compatible API)
+	BaseURL   string `json:"baseURL"`   // Defaults to the provider's public API
+	Model     string `json:"model"`     // Required
+	APIKeyEnv string `json:"apiKeyEnv"` // Environment variable holding the API key
+	MaxTokens int    `json:"maxTokens"` // Longest reply to allow
+}
+
+// DiffSource is an extra entry for the diff source picker
+type DiffSource struct {
+	Label   string   `json:"label"`
+	Command []string `json:"command"`
+}
+
+// DefaultMaxBatchBytes is the size budget of the hunks sent in one
+// classification request when maxBatchBytes is not configured
+const DefaultMaxBatchBytes = 200000
+
+// DefaultMaxRepairAttempts is the number of times hunks a classification left
+// out are sent back to the LLM when maxRepairAttempts is not configured
+const DefaultMaxRepairAttempts = 2
+
+// DefaultLLMCacheMaxBytes is the size limit of the cache of LLM
+// classifications when llmCacheMaxBytes is not configured
+const DefaultLLMCacheMaxBytes = 50000000
+
+// DefaultNarrativeConcurrency is the number of section narratives written at
+// once in two-pass generation when narrativeConcurrency is not configured
+const DefaultNarrativeConcurrency = 4
+
+// RepoConfigFile is the name of the config file at the root of a repository,
+// shared by everyone working in it
+const RepoConfigFile = ".diffstory.jsonc"
+
+// SourceDefault is the source of settings given their default value
+const SourceDefault = "default"
+
+// repoSettings are the settings a repository config may hold. Settings that
+// choose the LLM, and so run commands unprompted or decide where API keys are
+// sent, are left to each user, as a cloned repository should not decide them.
+// Diff sources only run when picked, with their command shown.
+var repoSettings = map[string]bool{
+	"defaultFilterLevel":   true,
+	"maxBatchBytes":        true,
+	"maxRepairAttempts":    true,
+	"twoPassGeneration":    true,
+	"narrativeConcurrency": true,
+	"reviewerInstructions": true,
+	"exclude":              true,
+	"diffSources":          true,
 }
 
 // Load reads config from XDG_CONFIG_HOME or ~/.config
 func Load() (*Config, error) {
+	return LoadForDir("")
+}
+
+// LoadForDir reads the config for workDir: the repository's .diffstory.jsonc,
+// if workDir is in a repository, overlaid by the user's config from
+// XDG_CONFIG_HOME or ~/.config. Each setting takes the value of the last file
+// that sets it. It returns nil if neither file exists.
+//
+// A repository's config is not the user's to fix, so a problem in it does
+// not stop diffstory: the file is ignored, and the config read without it is
+// returned along with a *RepoConfigError.
+func LoadForDir(workDir string) (*Config, error) {
+	repoPath := ""
+	if workDir != "" {
+		if root := RepoRoot(workDir); root != "" && fileExists(filepath.Join(root, RepoConfigFile)) {
+			repoPath = filepath.Join(root, RepoConfigFile)
+		}
+	}
+	userPath, err := userConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadFiles(repoPath, userPath)
+	var repoErr *RepoConfigError
+	if !errors.As(err, &repoErr) {
+		return cfg, err
+	}
+	if cfg, err = loadFiles("", userPath); err != nil {
+		return nil, err
+	}
+	return cfg, repoErr
+}
+
+// RepoConfigError is a problem in a repository's config file, which was
+// ignored because of it
+type RepoConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *RepoConfigError) Error() string {
+	return fmt.Sprintf("ignoring %s: %v", e.Path, e.Err)
+}
+
+func (e *RepoConfigError) Unwrap() error {
+	return e.Err
+}
+
+// loadFiles merges the settings of the repository config at repoPath and the
+// user config at userPath, either of which may be "". Problems in the
+// repository config are returned as a *RepoConfigError.
+func loadFiles(repoPath, userPath string) (*Config, error) {
+	var paths []string
+	for _, path := range []string{repoPath, userPath} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil // No config file found (not an error)
+	}
+
+	merged := make(map[string]json.RawMessage)
+	sources := make(map[string]string)
+	for _, path := range paths {
+		settings, err := readSettings(path)
+		if err != nil {
+			if path == repoPath {
+				return nil, &RepoConfigError{Path: path, Err: err}
+			}
+			return nil, err
+		}
+		for name, value := range settings {
+			if path == repoPath && !repoSettings[name] {
+				return nil, &RepoConfigError{Path: path, Err: fmt.Errorf("it can't set %s; set it in your own config instead", name)}
+			}
+			merged[name] = value
+			sources[name] = path
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	cfg.Sources = sources
+	for _, source := range cfg.DiffSources {
+		if source.Label == "" || len(source.Command) == 0 {
+			err := fmt.Errorf("diffSources entries need a label and a command (from %s)", sources["diffSources"])
+			if sources["diffSources"] == repoPath {
+				return nil, &RepoConfigError{Path: repoPath, Err: err}
+			}
+			return nil, err
+		}
+	}
+
+	cfg.applyDefaults()
+	return &cfg, nil
+}
+
+// Default returns the config used when no config file exists
+func Default() *Config {
+	cfg := &Config{Sources: make(map[string]string)}
+	cfg.applyDefaults()
+	return cfg
+}
+
+func (c *Config) applyDefaults() {
+	if len(c.DiffCommand) == 0 {
+		c.DiffCommand = []string{"git", "diff", "HEAD"}
+		c.Sources["diffCommand"] = SourceDefault
+	}
+	if c.DefaultFilterLevel == "" {
+		c.DefaultFilterLevel = "low"
+		c.Sources["defaultFilterLevel"] = SourceDefault
+	}
+	if c.MaxBatchBytes == 0 {
+		c.MaxBatchBytes = DefaultMaxBatchBytes
+		c.Sources["maxBatchBytes"] = SourceDefault
+	}
+	// 0 is a valid setting that turns repair off, so only an unset value
+	// takes the default
+	if _, set := c.Sources["maxRepairAttempts"]; !set {
+		c.MaxRepairAttempts = DefaultMaxRepairAttempts
+		c.Sources["maxRepairAttempts"] = SourceDefault
+	}
+	if c.LLMCacheMaxBytes == 0 {
+		c.LLMCacheMaxBytes = DefaultLLMCacheMaxBytes
+		c.Sources["llmCacheMaxBytes"] = SourceDefault
+	}
+	if c.NarrativeConcurrency == 0 {
+		c.NarrativeConcurrency = DefaultNarrativeConcurrency
+		c.Sources["narrativeConcurrency"] = SourceDefault
+	}
+}
+
+// userConfigPath returns the path of the user's config file, or "" if there
+// is none
+func userConfigPath() (string, error) {
 	for _, dir := range configDirs() {
 		jsonPath := filepath.Join(dir, "config.json")
 		jsoncPath := filepath.Join(dir, "config.jsonc")
@@ -25,41 +239,79 @@ func Load() (*Config, error) {
 		jsoncExists := fileExists(jsoncPath)
 
 		if jsonExists && jsoncExists {
-			return nil, fmt.Errorf("both config.json and config.jsonc exist in %s; please use only one", dir)
+			return "", fmt.Errorf("both config.json and config.jsonc exist in %s; please use only one", dir)
 		}
 
-		var path string
 		if jsonExists {
-			path = jsonPath
+			return jsonPath, nil
 		} else if jsoncExists {
-			path = jsoncPath
-		} else {
-			continue
+			return jsoncPath, nil
 		}
+	}
+	return "", nil
+}
 
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil, err
-		}
+// readSettings reads a config file's settings by name, checking that their
+// values have the right types
+func readSettings(path string) (map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
 
-		var cfg Config
-		cleanedData := stripComments(data)
-		if err := json.Unmarshal(cleanedData, &cfg); err != nil {
-			return nil, fmt.Errorf("invalid config at %s: %w", path, err)
-		}
+	cleanedData := stripComments(data)
+	var cfg Config
+	if err := json.Unmarshal(cleanedData, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config at %s: %w", path, err)
+	}
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(cleanedData, &settings); err != nil {
+		return nil, fmt.Errorf("invalid config at %s: %w", path, err)
+	}
+	return settings, nil
+}
 
-		// Apply defaults
-		if len(cfg.DiffCommand) == 0 {
-			cfg.DiffCommand = []string{"git", "diff", "HEAD"}
-		}
-		if cfg.DefaultFilterLevel == "" {
-			cfg.DefaultFilterLevel = "low"
-		}
+// Override sets a value given on the command line, recording source (such
+// as "-filter flag") as where it came from
+func (c *Config) Override(name, source string, apply func(*Config)) {
+	apply(c)
+	if c.Sources == nil {
+		c.Sources = make(map[string]string)
+	}
+	c.Sources[name] = source
+}
 
-		return &cfg, nil
+// PromptDirs returns the directories prompt templates are read from, in
+// order of precedence: the user's config directories, then the .diffstory
+// directory of the repository containing workDir, if any
+func PromptDirs(workDir string) []string {
+	var dirs []string
+	for _, dir := range configDirs() {
+		dirs = append(dirs, filepath.Join(dir, "prompts"))
 	}
+	if root := RepoRoot(workDir); root != "" {
+		dirs = append(dirs, filepath.Join(root, ".diffstory", "prompts"))
+	}
+	return dirs
+}
 
-	return nil, nil // No config file found (not an error)
+// RepoRoot returns the nearest directory at or above dir that holds a .git
+// entry, or "" outside a repository
+func RepoRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if fileExists(filepath.Join(dir, ".git")) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
 }
 
 func configDirs() []string {
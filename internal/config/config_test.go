@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -477,3 +479,297 @@ func TestLoad_ParsesDefaultFilterLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad_MaxBatchBytes(t *testing.T) {
+	tests := []struct {
+		name          string
+		configContent string
+		expected      int
+	}{
+		{"default", `{"llmCommand": ["claude"]}`, DefaultMaxBatchBytes},
+		{"configured", `{"maxBatchBytes": 5000}`, 5000},
+		{"unbatched", `{"maxBatchBytes": -1}`, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configDir := filepath.Join(tmpDir, "diffstory")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			configPath := filepath.Join(configDir, "config.json")
+			if err := os.WriteFile(configPath, []byte(tt.configContent), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.MaxBatchBytes != tt.expected {
+				t.Errorf("expected maxBatchBytes %d, got %d", tt.expected, cfg.MaxBatchBytes)
+			}
+		})
+	}
+}
+
+func TestRepoRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := RepoRoot(nested); got != root {
+		t.Errorf("expected %q, got %q", root, got)
+	}
+	if got := RepoRoot(t.TempDir()); got != "" {
+		t.Errorf("expected no root outside a repository, got %q", got)
+	}
+}
+
+func TestPromptDirs(t *testing.T) {
+	xdg := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Setenv("HOME", home)
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := PromptDirs(root)
+
+	expected := []string{
+		filepath.Join(xdg, "diffstory", "prompts"),
+		filepath.Join(home, ".config", "diffstory", "prompts"),
+		filepath.Join(root, ".diffstory", "prompts"),
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+// writeRepoConfig creates a repository holding content as its config file
+// and returns the repository's root
+func writeRepoConfig(t *testing.T, content string) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, RepoConfigFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestLoadForDir_UserConfigOverridesRepoConfig(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	userDir := filepath.Join(xdg, "diffstory")
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	userPath := filepath.Join(userDir, "config.jsonc")
+	if err := os.WriteFile(userPath, []byte(`{"llmCommand": ["claude"], "defaultFilterLevel": "high"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := writeRepoConfig(t, `{
+		// Shared by the team
+		"defaultFilterLevel": "medium",
+		"exclude": ["*.lock"],
+		"reviewerInstructions": "Focus on the API",
+	}`)
+	nested := filepath.Join(root, "sub")
+	if err := os.Mkdir(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadForDir(nested)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	repoPath := filepath.Join(root, RepoConfigFile)
+	if cfg.DefaultFilterLevel != "high" || cfg.Sources["defaultFilterLevel"] != userPath {
+		t.Errorf("expected the user's filter level, got %q from %q", cfg.DefaultFilterLevel, cfg.Sources["defaultFilterLevel"])
+	}
+	if len(cfg.Exclude) != 1 || cfg.Exclude[0] != "*.lock" || cfg.Sources["exclude"] != repoPath {
+		t.Errorf("expected the repository's exclude, got %v from %q", cfg.Exclude, cfg.Sources["exclude"])
+	}
+	if cfg.ReviewerInstructions != "Focus on the API" {
+		t.Errorf("expected the repository's instructions, got %q", cfg.ReviewerInstructions)
+	}
+	if cfg.Sources["llmCommand"] != userPath {
+		t.Errorf("expected llmCommand from the user config, got %q", cfg.Sources["llmCommand"])
+	}
+	if cfg.Sources["maxBatchBytes"] != SourceDefault {
+		t.Errorf("expected maxBatchBytes from the default, got %q", cfg.Sources["maxBatchBytes"])
+	}
+}
+
+func TestLoadForDir_RepoConfigWithoutUserConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	root := writeRepoConfig(t, `{"diffSources": [{"label": "Jujutsu change", "command": ["jj", "diff", "--git"]}]}`)
+
+	cfg, err := LoadForDir(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg == nil || len(cfg.DiffSources) != 1 || cfg.DiffSources[0].Label != "Jujutsu change" {
+		t.Fatalf("expected the repository's diff source, got %+v", cfg)
+	}
+	if cfg.DefaultFilterLevel != "low" {
+		t.Errorf("expected defaults applied, got filter level %q", cfg.DefaultFilterLevel)
+	}
+}
+
+func TestLoadForDir_RepoConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"llm command", `{"llmCommand": ["sh", "-c", "curl evil"]}`, "can't set llmCommand"},
+		{"llm provider", `{"llmProvider": {"type": "openai", "baseURL": "https://evil"}}`, "can't set llmProvider"},
+		{"wrong type", `{"exclude": "*.lock"}`, "invalid config at"},
+		{"diff source without command", `{"diffSources": [{"label": "Empty"}]}`, "need a label and a command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xdg := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", xdg)
+			t.Setenv("HOME", t.TempDir())
+			if err := os.MkdirAll(filepath.Join(xdg, "diffstory"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(xdg, "diffstory", "config.json"), []byte(`{"defaultFilterLevel": "high"}`), 0644); err != nil {
+				t.Fatal(err)
+			}
+			root := writeRepoConfig(t, tt.content)
+
+			cfg, err := LoadForDir(root)
+			var repoErr *RepoConfigError
+			if !errors.As(err, &repoErr) || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("expected a repository config error containing %q, got %v", tt.expected, err)
+			}
+			if cfg == nil || cfg.DefaultFilterLevel != "high" || len(cfg.Exclude) != 0 || len(cfg.DiffSources) != 0 {
+				t.Errorf("expected the user's config without the repository's, got %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadForDir_UserConfigErrorsStillFail(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(xdg, "diffstory"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(xdg, "diffstory", "config.json"), []byte(`{"exclude": "*.lock"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadForDir(writeRepoConfig(t, `{"llmCommand": ["evil"]}`))
+	var repoErr *RepoConfigError
+	if err == nil || errors.As(err, &repoErr) {
+		t.Errorf("expected the user's config error, got %v", err)
+	}
+}
+
+func TestConfig_Override(t *testing.T) {
+	cfg := &Config{DefaultFilterLevel: "low"}
+
+	cfg.Override("defaultFilterLevel", "-filter flag", func(c *Config) { c.DefaultFilterLevel = "high" })
+
+	if cfg.DefaultFilterLevel != "high" || cfg.Sources["defaultFilterLevel"] != "-filter flag" {
+		t.Errorf("expected the flag's value and source, got %q from %q", cfg.DefaultFilterLevel, cfg.Sources["defaultFilterLevel"])
+	}
+}
+
+func TestLoad_MaxRepairAttempts(t *testing.T) {
+	tests := []struct {
+		name          string
+		configContent string
+		expected      int
+	}{
+		{"default", `{"llmCommand": ["claude"]}`, DefaultMaxRepairAttempts},
+		{"configured", `{"maxRepairAttempts": 5}`, 5},
+		{"zero disables", `{"maxRepairAttempts": 0}`, 0},
+		{"negative disables", `{"maxRepairAttempts": -1}`, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configDir := filepath.Join(tmpDir, "diffstory")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			configPath := filepath.Join(configDir, "config.json")
+			if err := os.WriteFile(configPath, []byte(tt.configContent), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.MaxRepairAttempts != tt.expected {
+				t.Errorf("expected maxRepairAttempts %d, got %d", tt.expected, cfg.MaxRepairAttempts)
+			}
+		})
+	}
+}
+
+func TestLoad_LLMCacheMaxBytesDefault(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, "diffstory")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LLMCacheMaxBytes != DefaultLLMCacheMaxBytes || cfg.Sources["llmCacheMaxBytes"] != SourceDefault {
+		t.Errorf("expected the default cache limit, got %d from %q", cfg.LLMCacheMaxBytes, cfg.Sources["llmCacheMaxBytes"])
+	}
+}
+
+func TestLoad_TwoPassGeneration(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, "diffstory")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"twoPassGeneration": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.TwoPassGeneration {
+		t.Error("expected twoPassGeneration to be set")
+	}
+	if cfg.NarrativeConcurrency != DefaultNarrativeConcurrency || cfg.Sources["narrativeConcurrency"] != SourceDefault {
+		t.Errorf("expected the default narrative concurrency, got %d from %q", cfg.NarrativeConcurrency, cfg.Sources["narrativeConcurrency"])
+	}
+}
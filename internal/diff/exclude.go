@@ -0,0 +1,63 @@
+package diff
+
+import (
+	"path"
+	"strings"
+)
+
+// Exclude returns the hunks whose file matches none of the glob patterns, in
+// order. Renamed files are matched by their new path.
+func Exclude(hunks []ParsedHunk, patterns []string) []ParsedHunk {
+	if len(patterns) == 0 {
+		return hunks
+	}
+	var kept []ParsedHunk
+	for _, h := range hunks {
+		if !matchesAny(patterns, h.File) {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
+func matchesAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if MatchPath(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath reports whether file matches the glob pattern. Patterns use
+// path.Match syntax, plus "**" for any number of directories. A pattern
+// without a slash matches the file's name in any directory, so "*.lock"
+// matches "web/yarn.lock".
+func MatchPath(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pattern, file []string) bool {
+	if len(pattern) == 0 {
+		return len(file) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(file); i++ {
+			if matchSegments(pattern[1:], file[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(file) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], file[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], file[1:])
+}
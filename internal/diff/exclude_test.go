@@ -0,0 +1,54 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"*.lock", "yarn.lock", true},
+		{"*.lock", "web/yarn.lock", true},
+		{"*.lock", "web/yarn.lock.bak", false},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "src/vendor/a.go", false},
+		{"/vendor/**", "vendor/a.go", true},
+		{"**/testdata/*", "internal/tui/testdata/x.json", true},
+		{"**/testdata/*", "testdata/x.json", true},
+		{"**/testdata/*", "testdata/sub/x.json", false},
+		{"gen/*.pb.go", "gen/a.pb.go", true},
+		{"gen/*.pb.go", "gen/sub/a.pb.go", false},
+		{"[", "a", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	hunks := []ParsedHunk{
+		{ID: "main.go::1", File: "main.go"},
+		{ID: "go.sum::1", File: "go.sum"},
+		{ID: "new/gen.pb.go::1", File: "new/gen.pb.go", OldFile: "gen.pb.go"},
+		{ID: "gen/api.go::1", File: "gen/api.go", OldFile: "api.go"},
+		{ID: "api/api.go::1", File: "api/api.go", OldFile: "gen/api.go"},
+	}
+
+	var ids []string
+	for _, h := range Exclude(hunks, []string{"go.sum", "*.pb.go", "gen/**"}) {
+		ids = append(ids, h.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"main.go::1", "api/api.go::1"}) {
+		t.Errorf("expected main.go and the file renamed out of gen kept, got %v", ids)
+	}
+
+	if got := Exclude(hunks, nil); len(got) != len(hunks) {
+		t.Errorf("expected no patterns to keep every hunk, got %d", len(got))
+	}
+}
@@ -0,0 +1,197 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeaderRegex matches the header of a hunk, including the combined
+// "@@@ -a,b -c,d +e,f @@@" headers of merges, which have one "-" range per
+// parent and one more "@" than parents on each side
+var hunkHeaderRegex = regexp.MustCompile(`^(@{2,}) ((?:-\d+(?:,\d+)? )+)\+(\d+)(?:,(\d+))? (@{2,})`)
+
+// hunkHeader is a parsed hunk header. Parents is 1 for an ordinary hunk.
+type hunkHeader struct {
+	Parents   int
+	OldStarts []int
+	OldCounts []int
+	NewStart  int
+	NewCount  int
+}
+
+// parseHunkHeader parses a hunk header line, reporting false if line is not
+// one
+func parseHunkHeader(line string) (hunkHeader, bool) {
+	matches := hunkHeaderRegex.FindStringSubmatch(line)
+	if matches == nil || matches[1] != matches[5] {
+		return hunkHeader{}, false
+	}
+	h := hunkHeader{Parents: len(matches[1]) - 1}
+	for _, r := range strings.Fields(matches[2]) {
+		start, count, _ := strings.Cut(strings.TrimPrefix(r, "-"), ",")
+		n, _ := strconv.Atoi(start)
+		h.OldStarts = append(h.OldStarts, n)
+		h.OldCounts = append(h.OldCounts, rangeCount(count))
+	}
+	if len(h.OldStarts) != h.Parents {
+		return hunkHeader{}, false
+	}
+	h.NewStart, _ = strconv.Atoi(matches[3])
+	h.NewCount = rangeCount(matches[4])
+	return h, true
+}
+
+// Parents returns the number of parents a hunk compares against: 1 for an
+// ordinary hunk, 2 or more for a merge's combined diff, and 0 when hunkDiff
+// does not start with a hunk header
+func Parents(hunkDiff string) int {
+	first, _, _ := strings.Cut(hunkDiff, "\n")
+	if h, ok := parseHunkHeader(first); ok {
+		return h.Parents
+	}
+	return 0
+}
+
+// lineColumns splits the prefix columns, one per parent, from a hunk line.
+// Blank lines are context lines whose leading spaces were stripped.
+func lineColumns(line string, parents int) (string, bool) {
+	if line == "" {
+		return strings.Repeat(" ", parents), true
+	}
+	if len(line) < parents {
+		return "", false
+	}
+	columns := line[:parents]
+	for _, c := range columns {
+		if c != ' ' && c != '+' && c != '-' {
+			return "", false
+		}
+	}
+	return columns, true
+}
+
+// inParent reports whether a line with the given prefix columns is in parent
+// p. Lines removed in the result have "-" for each parent they came from;
+// other lines have "+" for each parent that lacked them.
+func inParent(columns string, p int) bool {
+	if strings.Contains(columns, "-") {
+		return columns[p] == '-'
+	}
+	return columns[p] == ' '
+}
+
+// LineNumber returns the file line number shown by line index (0-based,
+// counting the @@ header) of a hunk's diff: the new-file number for added
+// and context lines, and the old-file number for removed lines. In a merge's
+// combined diff, removed lines use the first parent they were removed from.
+// It returns 0 for header lines and for hunks without a parseable header.
+func LineNumber(hunkDiff string, index int) int {
+	var header hunkHeader
+	var oldLines []int
+	newLine := 0
+	inHunk := false
+	for i, line := range strings.Split(hunkDiff, "\n") {
+		if h, ok := parseHunkHeader(line); ok {
+			header = h
+			oldLines = append([]int(nil), h.OldStarts...)
+			newLine = h.NewStart
+			inHunk = true
+			if i == index {
+				return 0
+			}
+			continue
+		}
+		if !inHunk || strings.HasPrefix(line, `\`) {
+			if i == index {
+				return 0 // Also "\ No newline at end of file"
+			}
+			continue
+		}
+
+		columns, ok := lineColumns(line, header.Parents)
+		if !ok {
+			columns = strings.Repeat(" ", header.Parents)
+		}
+		number := newLine
+		if p := strings.IndexByte(columns, '-'); p >= 0 {
+			number = oldLines[p]
+		} else {
+			newLine++
+		}
+		for p := range oldLines {
+			if inParent(columns, p) {
+				oldLines[p]++
+			}
+		}
+		if i == index {
+			return number
+		}
+	}
+	return 0
+}
+
+// CheckHunk reports why hunkDiff is not a single well-formed unified diff
+// hunk: an @@ header followed by exactly the number of context, removed and
+// added lines the header declares. Combined hunks of merges are checked
+// against every parent's range. It returns nil for a well-formed hunk.
+func CheckHunk(hunkDiff string) error {
+	lines := strings.Split(strings.TrimSuffix(hunkDiff, "\n"), "\n")
+	header, ok := parseHunkHeader(lines[0])
+	if !ok {
+		return fmt.Errorf("does not start with an @@ hunk header")
+	}
+
+	oldCounts := make([]int, header.Parents)
+	newCount := 0
+	for i, line := range lines[1:] {
+		if strings.HasPrefix(line, "@@") {
+			return fmt.Errorf("line %d starts a second hunk", i+2)
+		}
+		if strings.HasPrefix(line, `\`) {
+			continue
+		}
+		columns, ok := lineColumns(line, header.Parents)
+		if !ok {
+			if header.Parents == 1 {
+				return fmt.Errorf("line %d does not start with ' ', '+', '-' or '\\'", i+2)
+			}
+			return fmt.Errorf("line %d does not start with %d columns of ' ', '+' or '-'", i+2, header.Parents)
+		}
+		for p := range oldCounts {
+			if inParent(columns, p) {
+				oldCounts[p]++
+			}
+		}
+		if !strings.Contains(columns, "-") {
+			newCount++
+		}
+	}
+
+	if header.Parents == 1 {
+		if oldCounts[0] != header.OldCounts[0] || newCount != header.NewCount {
+			return fmt.Errorf("header declares %d old and %d new lines, but the hunk has %d and %d", header.OldCounts[0], header.NewCount, oldCounts[0], newCount)
+		}
+		return nil
+	}
+	for p := range oldCounts {
+		if oldCounts[p] != header.OldCounts[p] {
+			return fmt.Errorf("header declares %d lines from parent %d, but the hunk has %d", header.OldCounts[p], p+1, oldCounts[p])
+		}
+	}
+	if newCount != header.NewCount {
+		return fmt.Errorf("header declares %d new lines, but the hunk has %d", header.NewCount, newCount)
+	}
+	return nil
+}
+
+// rangeCount parses the optional line count of a hunk header range, which
+// defaults to 1 when omitted
+func rangeCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
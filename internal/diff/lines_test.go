@@ -0,0 +1,126 @@
+package diff
+
+import "testing"
+
+func TestLineNumber(t *testing.T) {
+	hunk := "@@ -10,4 +20,4 @@ func main() {\n context\n-removed\n+added\n+added again\n context"
+
+	tests := []struct {
+		index int
+		want  int
+	}{
+		{0, 0},  // header
+		{1, 20}, // context uses the new-file number
+		{2, 11}, // removed uses the old-file number
+		{3, 21},
+		{4, 22},
+		{5, 23},
+		{9, 0}, // past the end
+	}
+	for _, tt := range tests {
+		if got := LineNumber(hunk, tt.index); got != tt.want {
+			t.Errorf("LineNumber(hunk, %d) = %d, want %d", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestLineNumber_NoHeader(t *testing.T) {
+	if got := LineNumber("+added", 0); got != 0 {
+		t.Errorf("expected 0 without a hunk header, got %d", got)
+	}
+}
+
+func TestCheckHunk(t *testing.T) {
+	tests := []struct {
+		name  string
+		hunk  string
+		valid bool
+	}{
+		{"well formed", "@@ -10,3 +10,4 @@ func main() {\n context\n-removed\n+added\n+added again\n context", true},
+		{"omitted counts", "@@ -1 +1 @@\n-old\n+new", true},
+		{"new file", "@@ -0,0 +1,2 @@\n+one\n+two", true},
+		{"no newline marker", "@@ -1 +1 @@\n-old\n\\ No newline at end of file\n+new", true},
+		{"blank context line", "@@ -1,3 +1,3 @@\n a\n\n-b\n+c", true},
+		{"trailing newline", "@@ -1 +1 @@\n-old\n+new\n", true},
+		{"missing header", "-old\n+new", false},
+		{"empty", "", false},
+		{"wrong counts", "@@ -1,5 +1,5 @@\n-old\n+new", false},
+		{"bad prefix", "@@ -1,2 +1,2 @@\n context\n*bad", false},
+		{"two hunks", "@@ -1 +1 @@\n-a\n+b\n@@ -5 +5 @@\n-c\n+d", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckHunk(tt.hunk)
+			if tt.valid && err != nil {
+				t.Errorf("expected valid hunk, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParents(t *testing.T) {
+	tests := []struct {
+		hunk string
+		want int
+	}{
+		{"@@ -1 +1 @@\n-a\n+b", 1},
+		{"@@@ -1,2 -1,2 +1,2 @@@\n  a\n++b", 2},
+		{"@@@@ -1 -1 -1 +1 @@@@\n+++x", 3},
+		{"@@@ -1 +1 @@@\n-a", 0}, // Range count disagrees with the @ count
+		{"rename from a\nrename to b", 0},
+	}
+	for _, tt := range tests {
+		if got := Parents(tt.hunk); got != tt.want {
+			t.Errorf("Parents(%q) = %d, want %d", tt.hunk, got, tt.want)
+		}
+	}
+}
+
+func TestLineNumber_Combined(t *testing.T) {
+	hunk := "@@@ -10,3 -20,2 +30,3 @@@\n  context\n- ours\n -theirs\n++resolved\n +from ours"
+
+	tests := []struct {
+		index int
+		want  int
+	}{
+		{0, 0},
+		{1, 30}, // context uses the new-file number
+		{2, 11}, // removed from the first parent
+		{3, 21}, // removed from the second parent
+		{4, 31},
+		{5, 32},
+	}
+	for _, tt := range tests {
+		if got := LineNumber(hunk, tt.index); got != tt.want {
+			t.Errorf("LineNumber(hunk, %d) = %d, want %d", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestCheckHunk_Combined(t *testing.T) {
+	tests := []struct {
+		name  string
+		hunk  string
+		valid bool
+	}{
+		{"resolution", "@@@ -1,2 -1,2 +1,2 @@@\n  a\n- ours\n -theirs\n++resolved", true},
+		{"line from one side", "@@@ -1,2 -1,1 +1,2 @@@\n  a\n +b", true},
+		{"wrong parent count", "@@@ -1,3 -1,2 +1,2 @@@\n  a\n++b", false},
+		{"wrong new count", "@@@ -1,1 -1,1 +1,3 @@@\n  a\n++b", false},
+		{"one column", "@@@ -1,1 -1,1 +1,1 @@@\n a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckHunk(tt.hunk)
+			if tt.valid && err != nil {
+				t.Errorf("expected valid hunk, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
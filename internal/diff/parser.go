@@ -5,19 +5,33 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/mchowning/diffstory/internal/model"
 )
 
-// ParsedHunk represents a single hunk from a unified diff
+// ParsedHunk represents a single hunk from a unified diff. Files changed
+// without any content hunks (pure renames, mode changes, empty new or deleted
+// files) are represented by a single hunk whose Diff holds the file's
+// extended header lines instead of an @@ hunk. Binary files are represented
+// the same way, with Binary set.
 type ParsedHunk struct {
-	ID        string // format: "file/path.go::lineNumber"
-	File      string
-	StartLine int
-	Diff      string // includes @@ header and content
+	ID          string // format: "file/path.go::lineNumber"
+	Fingerprint string // Content identity that survives the hunk moving; see model.HunkFingerprint
+	File        string // Path after the change, or the deleted path
+	OldFile     string // Path before the change, set for renames and copies
+	ChangeKind  string // model.Change* kind of the file change; "" for modifications
+	StartLine   int
+	Diff        string // includes @@ header and content
+	Binary      bool   // The file is binary, so Diff only says that it differs
+	OldBlob     string // Abbreviated blob hash before the change, from the index line
+	NewBlob     string // Abbreviated blob hash after the change, from the index line
+	OldSize     int64  // Size of a binary file before the change, if looked up
+	NewSize     int64  // Size of a binary file after the change, if looked up
 }
 
 var (
-	diffGitRegex    = regexp.MustCompile(`^diff --git a/.+ b/(.+)$`)
-	hunkHeaderRegex = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+	diffGitRegex  = regexp.MustCompile(`^diff --git a/.+ b/(.+)$`)
+	revisionRegex = regexp.MustCompile(`^(?:[0-9a-f]{12,40}|\d+)$`)
 )
 
 // Parse splits unified diff output into individual hunks
@@ -30,31 +44,357 @@ func Parse(diffOutput string) ([]ParsedHunk, error) {
 	fileDiffs := splitOnFileBoundaries(diffOutput)
 
 	for _, fileDiff := range fileDiffs {
-		if strings.Contains(fileDiff, "Binary files") {
+		header := parseFileHeader(fileDiff)
+		if header.path() == "" {
 			continue
 		}
 
-		filePath := extractFilePath(fileDiff)
-		if filePath == "" {
-			continue
+		var fileHunks []ParsedHunk
+		if !header.binary {
+			fileHunks = splitIntoHunks(fileDiff, header.path())
+		}
+		if len(fileHunks) == 0 && len(header.extended) > 0 {
+			fileHunks = []ParsedHunk{{
+				ID:     fmt.Sprintf("%s::%d", header.path(), 0),
+				File:   header.path(),
+				Diff:   strings.Join(header.extended, "\n"),
+				Binary: header.binary,
+			}}
+			if header.binary {
+				fileHunks[0].OldBlob, fileHunks[0].NewBlob = header.oldBlob, header.newBlob
+			}
+		}
+		if !header.git && header.kind == "" {
+			header.kind = inferChangeKind(fileHunks)
+		}
+		for i := range fileHunks {
+			fileHunks[i].ChangeKind = header.kind
+			if header.kind == model.ChangeRenamed || header.kind == model.ChangeCopied {
+				fileHunks[i].OldFile = header.oldPath
+			}
 		}
-
-		fileHunks := splitIntoHunks(fileDiff, filePath)
 		hunks = append(hunks, fileHunks...)
 	}
 
 	hunks = makeUniqueIDs(hunks)
+	hunks = addFingerprints(hunks)
 	return hunks, nil
 }
 
+// inferChangeKind recognises added and deleted files from their hunks, for
+// tools such as diff -N that give an empty file a timestamp instead of
+// /dev/null
+func inferChangeKind(hunks []ParsedHunk) string {
+	if len(hunks) != 1 {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(hunks[0].Diff, "@@ -0,0 "):
+		return model.ChangeAdded
+	case strings.Contains(strings.SplitN(hunks[0].Diff, "\n", 2)[0], " +0,0 @@"):
+		return model.ChangeDeleted
+	}
+	return ""
+}
+
+// fileHeader is what the lines before a file's first hunk say about it
+type fileHeader struct {
+	oldPath  string
+	newPath  string
+	kind     string
+	extended []string // Extended header lines, such as "rename from ..." and "new mode ..."
+	binary   bool
+	oldBlob  string
+	newBlob  string
+	git      bool // The header is git's, so changes of kind are always stated
+}
+
+// path is the file's current path, or its old path if it was deleted
+func (h fileHeader) path() string {
+	if h.kind == model.ChangeDeleted || h.newPath == "" {
+		return h.oldPath
+	}
+	return h.newPath
+}
+
+// parseFileHeader reads a file's paths and change kind from the header lines
+// before its first hunk. Git headers start with "diff --git" and may carry
+// extended lines; other tools (diff -u, hg, svn) give the paths only in
+// "---"/"+++" lines, possibly after an "Index:" or "diff ..." line. Paths in
+// "---"/"+++" and rename/copy lines take precedence, since unlike the
+// "diff --git" line they are unambiguous when paths contain spaces.
+func parseFileHeader(fileDiff string) fileHeader {
+	var h fileHeader
+	var modeChanged, git bool
+	var indexPath, commandOld, commandNew string
+	for _, line := range strings.Split(fileDiff, "\n") {
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "Property changes on: ") {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			git = true
+			h.oldPath, h.newPath = parseDiffGitPaths(strings.TrimPrefix(line, "diff --git "))
+		case strings.HasPrefix(line, "diff --cc "), strings.HasPrefix(line, "diff --combined "):
+			// A merge's combined diff names the file once
+			_, path, _ := strings.Cut(strings.TrimPrefix(line, "diff --"), " ")
+			git = true
+			h.oldPath, h.newPath = unquotePath(path), unquotePath(path)
+		case strings.HasPrefix(line, "diff "):
+			// diff -r and hg name the file on their command line, which is all
+			// there is for binary files
+			commandOld, commandNew = parseCommandPaths(strings.TrimPrefix(line, "diff "))
+		case strings.HasPrefix(line, "===="), strings.HasPrefix(line, "Only in "):
+			// svn's separator, and files diff -r could only list
+		case strings.HasPrefix(line, "Index: "):
+			indexPath = strings.TrimPrefix(line, "Index: ")
+		case strings.HasPrefix(line, "--- "):
+			if path, ok := parseHeaderPath(strings.TrimPrefix(line, "--- ")); ok {
+				h.oldPath = path
+			} else {
+				h.oldPath = ""
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if path, ok := parseHeaderPath(strings.TrimPrefix(line, "+++ ")); ok {
+				h.newPath = path
+			} else {
+				h.newPath = ""
+				if h.kind == "" {
+					h.kind = model.ChangeDeleted
+				}
+			}
+		case strings.HasPrefix(line, "index "):
+			// Blob hashes are noise to a reader, but locate binary contents
+			blobs, _, _ := strings.Cut(strings.TrimPrefix(line, "index "), " ")
+			h.oldBlob, h.newBlob, _ = strings.Cut(blobs, "..")
+			h.oldBlob, _, _ = strings.Cut(h.oldBlob, ",") // Merges list a blob per parent
+		case line == "GIT binary patch":
+			// The encoded patch that follows is of no use to a reader
+			h.binary = true
+			h.extended = append(h.extended, line)
+			return h.finish(git, headerPaths{indexPath, commandOld, commandNew}, modeChanged)
+		case line == "":
+		default:
+			h.extended = append(h.extended, line)
+			switch {
+			case strings.HasPrefix(line, "new file mode "):
+				h.kind = model.ChangeAdded
+			case strings.HasPrefix(line, "deleted file mode "):
+				h.kind = model.ChangeDeleted
+			case strings.HasPrefix(line, "rename from "):
+				h.kind, h.oldPath = model.ChangeRenamed, unquotePath(strings.TrimPrefix(line, "rename from "))
+			case strings.HasPrefix(line, "rename to "):
+				h.newPath = unquotePath(strings.TrimPrefix(line, "rename to "))
+			case strings.HasPrefix(line, "copy from "):
+				h.kind, h.oldPath = model.ChangeCopied, unquotePath(strings.TrimPrefix(line, "copy from "))
+			case strings.HasPrefix(line, "copy to "):
+				h.newPath = unquotePath(strings.TrimPrefix(line, "copy to "))
+			case strings.HasPrefix(line, "old mode "), strings.HasPrefix(line, "new mode "):
+				modeChanged = true
+			case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"),
+				strings.HasPrefix(line, "Binary file ") && strings.HasSuffix(line, " has changed"),
+				strings.HasPrefix(line, "Cannot display: file marked as a binary type"):
+				h.binary = true
+			}
+		}
+	}
+	return h.finish(git, headerPaths{indexPath, commandOld, commandNew}, modeChanged)
+}
+
+// headerPaths are the paths named outside "---"/"+++" lines by tools other
+// than git
+type headerPaths struct {
+	index      string // svn's "Index:" line
+	commandOld string // The command line of diff -r or hg
+	commandNew string
+}
+
+// finish settles a header's paths and kind once all its lines are read
+func (h fileHeader) finish(git bool, other headerPaths, modeChanged bool) fileHeader {
+	h.git = git
+	if git {
+		h.oldPath = strings.TrimPrefix(h.oldPath, "a/")
+		h.newPath = strings.TrimPrefix(h.newPath, "b/")
+	} else {
+		if h.oldPath == "" && h.newPath == "" && h.kind == "" {
+			h.oldPath, h.newPath = other.commandOld, other.commandNew
+		} else if h.oldPath == "" && h.kind == "" {
+			h.kind = model.ChangeAdded
+		}
+		h.oldPath, h.newPath = stripPathPrefixes(h.oldPath, h.newPath)
+		if other.index != "" {
+			// svn names each side relative to the working copy, as Index does
+			if h.oldPath != "" || h.kind == model.ChangeDeleted {
+				h.oldPath = other.index
+			}
+			if h.kind != model.ChangeDeleted {
+				h.newPath = other.index
+			}
+		}
+	}
+	if h.kind == "" && modeChanged {
+		h.kind = model.ChangeModeChanged
+	}
+	return h
+}
+
+// stripPathPrefixes drops the leading directory that tools other than git add
+// to tell the two sides apart: hg's a/ and b/, or the two trees compared by
+// diff -r. Paths that differ beyond their first component are kept whole.
+func stripPathPrefixes(oldPath, newPath string) (string, string) {
+	if oldPath == "" || newPath == "" {
+		if oldPath != "" {
+			return stripFirstDir(oldPath), ""
+		}
+		if newPath != "" {
+			return "", stripFirstDir(newPath)
+		}
+		return "", ""
+	}
+	oldDir, oldRest, oldOK := strings.Cut(oldPath, "/")
+	newDir, newRest, newOK := strings.Cut(newPath, "/")
+	if oldOK && newOK && oldDir != newDir && oldRest == newRest {
+		return oldRest, newRest
+	}
+	return oldPath, newPath
+}
+
+// stripFirstDir drops an a/ or b/ prefix from the one path of an added or
+// deleted file, where there is no other side to compare against
+func stripFirstDir(path string) string {
+	for _, prefix := range []string{"a/", "b/"} {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			return rest
+		}
+	}
+	return path
+}
+
+// parseDiffGitPaths splits the paths of a "diff --git" line. Quoted paths are
+// unambiguous; unquoted ones are split where both halves name the same file,
+// which holds for everything but renames and copies, whose paths are also
+// given on their own lines.
+func parseDiffGitPaths(paths string) (string, string) {
+	if strings.HasPrefix(paths, `"`) || strings.HasSuffix(paths, `"`) {
+		if oldPath, rest, ok := cutQuoted(paths); ok {
+			if newPath, _, ok := cutQuoted(strings.TrimPrefix(rest, " ")); ok {
+				return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+			}
+		}
+	}
+	if n := len(paths); n%2 == 1 {
+		half := n / 2
+		if strings.HasPrefix(paths, "a/") && paths[half:half+3] == " b/" && paths[2:half] == paths[half+3:] {
+			return paths[2:half], paths[half+3:]
+		}
+	}
+	if matches := diffGitRegex.FindStringSubmatch("diff --git " + paths); len(matches) > 1 {
+		old := strings.TrimPrefix(paths[:len(paths)-len(matches[1])-3], "a/")
+		return old, matches[1]
+	}
+	return "", ""
+}
+
+// cutQuoted splits a leading path from s, which is either a C-style quoted
+// string or runs to the next space
+func cutQuoted(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		path, rest, _ := strings.Cut(s, " ")
+		return path, rest, path != ""
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			path, err := strconv.Unquote(s[:i+1])
+			return path, s[i+1:], err == nil
+		}
+	}
+	return "", "", false
+}
+
+// parseHeaderPath reads the path of a "---" or "+++" line, with any prefix
+// left for the caller. It reports false for /dev/null and for the side svn
+// marks as not existing.
+func parseHeaderPath(s string) (string, bool) {
+	s, label, _ := strings.Cut(s, "\t") // Some tools append a timestamp or revision after a tab
+	if label == "(nonexistent)" || label == "(revision 0)" {
+		return "", false
+	}
+	path := unquotePath(s)
+	if path == "/dev/null" {
+		return "", false
+	}
+	return path, true
+}
+
+// parseCommandPaths reads the paths from the arguments of a diff -r or hg
+// "diff" command line: one path for hg, whose -r options name revisions, or
+// an old and a new path for diff -r
+func parseCommandPaths(args string) (string, string) {
+	fields := strings.Fields(args)
+	var paths []string
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "-r" && i+1 < len(fields) && revisionRegex.MatchString(fields[i+1]):
+			i++
+		case strings.HasPrefix(fields[i], "-"):
+		default:
+			paths = append(paths, fields[i])
+		}
+	}
+	switch len(paths) {
+	case 1:
+		return paths[0], paths[0]
+	case 2:
+		return paths[0], paths[1]
+	}
+	return "", ""
+}
+
+// unquotePath decodes a path git has C-style quoted because it contains
+// special or non-ASCII characters
+func unquotePath(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		if path, err := strconv.Unquote(s); err == nil {
+			return path
+		}
+	}
+	return s
+}
+
+// splitOnFileBoundaries splits a diff into one part per file. A file starts
+// at a command line ("diff --git", hg's "diff -r", diff -r's "diff -ruN"), at
+// svn's "Index:" line, or at a "---"/"+++" pair after another file's hunks.
+// Hunk line counts are followed, so a removed "-- comment" line, which reads
+// "--- comment", is not taken for the start of a file.
 func splitOnFileBoundaries(diff string) []string {
 	var files []string
 	var current strings.Builder
+	var counts hunkCounter
+	hasPaths := false // The current part has its "+++" line or a hunk
 
-	for _, line := range strings.Split(diff, "\n") {
-		if strings.HasPrefix(line, "diff --git") && current.Len() > 0 {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		if counts.consume(line) {
+			current.WriteString(line)
+			current.WriteString("\n")
+			continue
+		}
+
+		startsFile := strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "Index: ") ||
+			(hasPaths && strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "))
+		if startsFile && current.Len() > 0 {
 			files = append(files, current.String())
 			current.Reset()
+			hasPaths = false
+		}
+		if strings.HasPrefix(line, "+++ ") {
+			hasPaths = true
+		}
+		if counts.start(line) {
+			hasPaths = true
 		}
 		current.WriteString(line)
 		current.WriteString("\n")
@@ -66,13 +406,62 @@ func splitOnFileBoundaries(diff string) []string {
 	return files
 }
 
-func extractFilePath(fileDiff string) string {
-	for _, line := range strings.Split(fileDiff, "\n") {
-		if matches := diffGitRegex.FindStringSubmatch(line); len(matches) > 1 {
-			return matches[1]
+// hunkCounter tracks how many lines of each side remain in the current hunk
+type hunkCounter struct {
+	parents int
+	oldLeft []int
+	newLeft int
+}
+
+// start begins counting if line is a hunk header
+func (c *hunkCounter) start(line string) bool {
+	header, ok := parseHunkHeader(line)
+	if !ok {
+		return false
+	}
+	c.parents = header.Parents
+	c.oldLeft = append([]int(nil), header.OldCounts...)
+	c.newLeft = header.NewCount
+	return true
+}
+
+// consume reports whether line belongs to the hunk being counted
+func (c *hunkCounter) consume(line string) bool {
+	if c.done() {
+		return false
+	}
+	if strings.HasPrefix(line, `\`) {
+		return true
+	}
+	columns, ok := lineColumns(line, c.parents)
+	if !ok {
+		c.oldLeft, c.newLeft = nil, 0
+		return false
+	}
+	for p := range c.oldLeft {
+		if inParent(columns, p) {
+			c.oldLeft[p]--
 		}
 	}
-	return ""
+	if !strings.Contains(columns, "-") {
+		c.newLeft--
+	}
+	return true
+}
+
+func (c *hunkCounter) done() bool {
+	for _, n := range c.oldLeft {
+		if n > 0 {
+			return false
+		}
+	}
+	return c.newLeft <= 0
+}
+
+// isHunkContent reports whether line can appear in a hunk body. Blank lines
+// are context lines whose leading space was stripped.
+func isHunkContent(line string) bool {
+	return line == "" || strings.ContainsRune(" +-\\", rune(line[0]))
 }
 
 func splitIntoHunks(fileDiff string, filePath string) []ParsedHunk {
@@ -81,20 +470,27 @@ func splitIntoHunks(fileDiff string, filePath string) []ParsedHunk {
 	var currentStartLine int
 	inHunk := false
 
-	for _, line := range strings.Split(fileDiff, "\n") {
-		if matches := hunkHeaderRegex.FindStringSubmatch(line); len(matches) > 1 {
-			if inHunk && currentHunk.Len() > 0 {
-				hunks = append(hunks, ParsedHunk{
-					ID:        fmt.Sprintf("%s::%d", filePath, currentStartLine),
-					File:      filePath,
-					StartLine: currentStartLine,
-					Diff:      strings.TrimSuffix(currentHunk.String(), "\n"),
-				})
-			}
+	flush := func() {
+		if inHunk && currentHunk.Len() > 0 {
+			hunks = append(hunks, ParsedHunk{
+				ID:        fmt.Sprintf("%s::%d", filePath, currentStartLine),
+				File:      filePath,
+				StartLine: currentStartLine,
+				Diff:      strings.TrimSuffix(currentHunk.String(), "\n"),
+			})
+		}
+		currentHunk.Reset()
+	}
 
-			currentHunk.Reset()
-			currentStartLine, _ = strconv.Atoi(matches[1])
+	for _, line := range strings.Split(fileDiff, "\n") {
+		if header, ok := parseHunkHeader(line); ok {
+			flush()
+			currentStartLine = header.NewStart
 			inHunk = true
+		} else if inHunk && !isHunkContent(line) {
+			// Trailing lines such as svn's property changes are not part of it
+			flush()
+			inHunk = false
 		}
 
 		if inHunk {
@@ -102,15 +498,7 @@ func splitIntoHunks(fileDiff string, filePath string) []ParsedHunk {
 			currentHunk.WriteString("\n")
 		}
 	}
-
-	if inHunk && currentHunk.Len() > 0 {
-		hunks = append(hunks, ParsedHunk{
-			ID:        fmt.Sprintf("%s::%d", filePath, currentStartLine),
-			File:      filePath,
-			StartLine: currentStartLine,
-			Diff:      strings.TrimSuffix(currentHunk.String(), "\n"),
-		})
-	}
+	flush()
 
 	return hunks
 }
@@ -126,3 +514,13 @@ func makeUniqueIDs(hunks []ParsedHunk) []ParsedHunk {
 	}
 	return hunks
 }
+
+// addFingerprints sets each hunk's content fingerprint. Identical changes in
+// one file are told apart by their order, like IDs.
+func addFingerprints(hunks []ParsedHunk) []ParsedHunk {
+	counter := model.FingerprintCounter{}
+	for i := range hunks {
+		hunks[i].Fingerprint = counter.Next(model.HunkFingerprint(hunks[i].File, hunks[i].Diff))
+	}
+	return hunks
+}
@@ -2,6 +2,7 @@ package diff
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -121,8 +122,9 @@ index 1234567..abcdefg 100644
 	}
 }
 
-func TestParse_BinaryFilesAreSkipped(t *testing.T) {
+func TestParse_BinaryFilesBecomeHunks(t *testing.T) {
 	diff := `diff --git a/image.png b/image.png
+index 1234567..89abcde 100644
 Binary files a/image.png and b/image.png differ
 diff --git a/main.go b/main.go
 index 1234567..abcdefg 100644
@@ -131,16 +133,68 @@ index 1234567..abcdefg 100644
 @@ -1,3 +1,4 @@
  package main
 +// comment
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+
+	binary := hunks[0]
+	if binary.File != "image.png" || !binary.Binary || binary.ID != "image.png::0" {
+		t.Errorf("unexpected binary hunk: %+v", binary)
+	}
+	if binary.Diff != "Binary files a/image.png and b/image.png differ" {
+		t.Errorf("unexpected binary diff: %q", binary.Diff)
+	}
+	if binary.OldBlob != "1234567" || binary.NewBlob != "89abcde" {
+		t.Errorf("expected blob hashes from the index line, got %q..%q", binary.OldBlob, binary.NewBlob)
+	}
+	if hunks[1].File != "main.go" || hunks[1].Binary || hunks[1].OldBlob != "" {
+		t.Errorf("unexpected text hunk: %+v", hunks[1])
+	}
+}
+
+func TestParse_NewBinaryFileWithPatch(t *testing.T) {
+	diff := `diff --git a/font.woff2 b/font.woff2
+new file mode 100644
+index 0000000000000000000000000000000000000000..1234567890abcdef1234567890abcdef12345678
+GIT binary patch
+literal 12
+@@zcmV-
+literal 0
+HcmV?d00001
 `
 	hunks, err := Parse(diff)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if len(hunks) != 1 {
-		t.Fatalf("expected 1 hunk (binary skipped), got %d", len(hunks))
+		t.Fatalf("expected 1 hunk, got %d: %+v", len(hunks), hunks)
+	}
+	h := hunks[0]
+	if !h.Binary || h.ChangeKind != "added" || h.Diff != "new file mode 100644\nGIT binary patch" {
+		t.Errorf("unexpected hunk: %+v", h)
+	}
+}
+
+func TestParse_TextMentioningBinaryFilesIsNotBinary(t *testing.T) {
+	diff := `diff --git a/notes.txt b/notes.txt
+index 1234567..abcdefg 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1,2 @@
+ intro
++Binary files a/x and b/x differ
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if hunks[0].File != "main.go" {
-		t.Errorf("expected file 'main.go', got %q", hunks[0].File)
+	if len(hunks) != 1 || hunks[0].Binary {
+		t.Fatalf("expected one text hunk, got %+v", hunks)
 	}
 }
 
@@ -316,3 +370,406 @@ func TestParse_Performance_LargeDiff(t *testing.T) {
 		t.Errorf("parsing took %v, expected < 1 second", elapsed)
 	}
 }
+
+func TestParse_ChangeKinds(t *testing.T) {
+	diff := `diff --git a/added.go b/added.go
+new file mode 100644
+index 0000000..1234567
+--- /dev/null
++++ b/added.go
+@@ -0,0 +1 @@
++package added
+diff --git a/removed.go b/removed.go
+deleted file mode 100644
+index 1234567..0000000
+--- a/removed.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package removed
+diff --git a/old.go b/new.go
+similarity index 90%
+rename from old.go
+rename to new.go
+index 1234567..abcdefg 100644
+--- a/old.go
++++ b/new.go
+@@ -1,2 +1,2 @@
+ package pkg
+-var a = 1
++var a = 2
+diff --git a/src.go b/copy.go
+similarity index 100%
+copy from src.go
+copy to copy.go
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		file, oldFile, kind string
+	}{
+		{"added.go", "", "added"},
+		{"removed.go", "", "deleted"},
+		{"new.go", "old.go", "renamed"},
+		{"copy.go", "src.go", "copied"},
+		{"run.sh", "", "mode-changed"},
+	}
+	if len(hunks) != len(want) {
+		t.Fatalf("expected %d hunks, got %d: %+v", len(want), len(hunks), hunks)
+	}
+	for i, w := range want {
+		h := hunks[i]
+		if h.File != w.file || h.OldFile != w.oldFile || h.ChangeKind != w.kind {
+			t.Errorf("hunk %d = %q/%q/%q, want %q/%q/%q", i, h.File, h.OldFile, h.ChangeKind, w.file, w.oldFile, w.kind)
+		}
+	}
+}
+
+func TestParse_PureRenameBecomesHeaderHunk(t *testing.T) {
+	diff := `diff --git a/old name.go b/new name.go
+similarity index 100%
+rename from old name.go
+rename to new name.go
+diff --git a/main.go b/main.go
+index 1234567..abcdefg 100644
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-a
++b
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+
+	rename := hunks[0]
+	if rename.File != "new name.go" || rename.OldFile != "old name.go" {
+		t.Errorf("expected rename from 'old name.go' to 'new name.go', got %q -> %q", rename.OldFile, rename.File)
+	}
+	if rename.ID != "new name.go::0" || rename.StartLine != 0 {
+		t.Errorf("unexpected ID/start line: %q/%d", rename.ID, rename.StartLine)
+	}
+	if rename.Diff != "similarity index 100%\nrename from old name.go\nrename to new name.go" {
+		t.Errorf("expected the extended header as the diff, got %q", rename.Diff)
+	}
+	if hunks[1].ChangeKind != "" || hunks[1].OldFile != "" {
+		t.Errorf("expected a plain modification, got %+v", hunks[1])
+	}
+}
+
+func TestParse_EmptyNewFile(t *testing.T) {
+	diff := `diff --git a/empty.txt b/empty.txt
+new file mode 100644
+index 0000000..e69de29
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hunks) != 1 {
+		t.Fatalf("expected 1 hunk, got %d", len(hunks))
+	}
+	if hunks[0].File != "empty.txt" || hunks[0].ChangeKind != "added" || hunks[0].Diff != "new file mode 100644" {
+		t.Errorf("unexpected hunk: %+v", hunks[0])
+	}
+}
+
+func TestParse_QuotedPaths(t *testing.T) {
+	diff := `diff --git "a/caf\303\251.go" "b/caf\303\251.go"
+index 1234567..abcdefg 100644
+--- "a/caf\303\251.go"
++++ "b/caf\303\251.go"
+@@ -1 +1 @@
+-a
++b
+diff --git "a/tab\there.go" "b/tab\there.go"
+new file mode 100644
+index 0000000..e69de29
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+	if hunks[0].File != "café.go" || hunks[0].ID != "café.go::1" {
+		t.Errorf("expected unquoted non-ASCII path, got %q (%q)", hunks[0].File, hunks[0].ID)
+	}
+	if hunks[1].File != "tab\there.go" {
+		t.Errorf("expected path from quoted diff --git line, got %q", hunks[1].File)
+	}
+}
+
+func TestParse_PathsWithSpaces(t *testing.T) {
+	diff := `diff --git a/dir b/file.go b/dir b/file.go
+index 1234567..abcdefg 100644
+--- a/dir b/file.go
++++ b/dir b/file.go
+@@ -1 +1 @@
+-a
++b
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hunks) != 1 || hunks[0].File != "dir b/file.go" {
+		t.Fatalf("expected one hunk for 'dir b/file.go', got %+v", hunks)
+	}
+}
+
+func TestParseDiffGitPaths(t *testing.T) {
+	tests := []struct {
+		in, old, new string
+	}{
+		{"a/main.go b/main.go", "main.go", "main.go"},
+		{"a/x b/y b/x b/y", "x b/y", "x b/y"},
+		{"a/old.go b/new.go", "old.go", "new.go"},
+		{`"a/sp ace.go" "b/sp ace.go"`, "sp ace.go", "sp ace.go"},
+		{`a/plain.go "b/caf\303\251.go"`, "plain.go", "café.go"},
+	}
+	for _, tt := range tests {
+		old, new := parseDiffGitPaths(tt.in)
+		if old != tt.old || new != tt.new {
+			t.Errorf("parseDiffGitPaths(%q) = %q, %q; want %q, %q", tt.in, old, new, tt.old, tt.new)
+		}
+	}
+}
+
+// hunkSummary renders the parts of hunks the non-git format tests compare
+func hunkSummary(hunks []ParsedHunk) []string {
+	var out []string
+	for _, h := range hunks {
+		out = append(out, fmt.Sprintf("%s|%s|%d|%d", h.File, h.ChangeKind, h.StartLine, strings.Count(strings.TrimRight(h.Diff, "\n"), "\n")+1))
+	}
+	return out
+}
+
+func TestParse_NonGitFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want []string
+	}{
+		{
+			name: "diff -u on two files",
+			diff: "--- old.txt\t2024-01-02 10:00:00.000000000 +0000\n" +
+				"+++ new.txt\t2024-01-02 11:00:00.000000000 +0000\n" +
+				"@@ -1,2 +1,2 @@\n line\n-old\n+new\n",
+			want: []string{"new.txt||1|4"},
+		},
+		{
+			name: "diff -ruN on two trees",
+			diff: "diff -ruN before/main.go after/main.go\n" +
+				"--- before/main.go\t2024-01-02 10:00:00.000000000 +0000\n" +
+				"+++ after/main.go\t2024-01-02 11:00:00.000000000 +0000\n" +
+				"@@ -1 +1 @@\n-a\n+b\n" +
+				"@@ -10 +10 @@\n-c\n+d\n" +
+				"diff -ruN before/pkg/new.go after/pkg/new.go\n" +
+				"--- before/pkg/new.go\t1970-01-01 00:00:00.000000000 +0000\n" +
+				"+++ after/pkg/new.go\t2024-01-02 11:00:00.000000000 +0000\n" +
+				"@@ -0,0 +1,2 @@\n+package pkg\n+\n" +
+				"diff -ruN before/gone.go after/gone.go\n" +
+				"--- before/gone.go\t2024-01-02 10:00:00.000000000 +0000\n" +
+				"+++ after/gone.go\t1970-01-01 00:00:00.000000000 +0000\n" +
+				"@@ -1 +0,0 @@\n-package gone\n" +
+				"Only in before: notes.txt\n",
+			want: []string{"main.go||1|3", "main.go||10|3", "pkg/new.go|added|1|3", "gone.go|deleted|0|2"},
+		},
+		{
+			name: "concatenated patches without command lines",
+			diff: "--- a/one.go\n+++ b/one.go\n@@ -1 +1 @@\n-x\n+y\n" +
+				"--- a/two.go\n+++ b/two.go\n@@ -1 +1 @@\n-x\n+y\n",
+			want: []string{"one.go||1|3", "two.go||1|3"},
+		},
+		{
+			name: "removed and added lines that look like headers",
+			diff: "--- a/query.sql\n+++ b/query.sql\n@@ -1,2 +1,2 @@\n--- old comment\n+++ new comment\n select 1;\n",
+			want: []string{"query.sql||1|4"},
+		},
+		{
+			name: "hg diff",
+			diff: "diff -r 1a2b3c4d5e6f src/app.py\n" +
+				"--- a/src/app.py\tMon Jan 01 10:00:00 2024 +0000\n" +
+				"+++ b/src/app.py\tTue Jan 02 10:00:00 2024 +0000\n" +
+				"@@ -3,1 +3,1 @@\n-a\n+b\n" +
+				"diff -r 1a2b3c4d5e6f src/added.py\n" +
+				"--- /dev/null\tThu Jan 01 00:00:00 1970 +0000\n" +
+				"+++ b/src/added.py\tTue Jan 02 10:00:00 2024 +0000\n" +
+				"@@ -0,0 +1,1 @@\n+new\n" +
+				"diff -r 1a2b3c4d5e6f src/removed.py\n" +
+				"--- a/src/removed.py\tMon Jan 01 10:00:00 2024 +0000\n" +
+				"+++ /dev/null\tThu Jan 01 00:00:00 1970 +0000\n" +
+				"@@ -1,1 +0,0 @@\n-old\n",
+			want: []string{"src/app.py||3|3", "src/added.py|added|1|2", "src/removed.py|deleted|0|2"},
+		},
+		{
+			name: "svn diff",
+			diff: "Index: trunk/main.c\n" +
+				"===================================================================\n" +
+				"--- trunk/main.c\t(revision 41)\n" +
+				"+++ trunk/main.c\t(working copy)\n" +
+				"@@ -1 +1 @@\n-int a;\n+int b;\n" +
+				"Index: trunk/new.c\n" +
+				"===================================================================\n" +
+				"--- trunk/new.c\t(nonexistent)\n" +
+				"+++ trunk/new.c\t(working copy)\n" +
+				"@@ -0,0 +1 @@\n+int c;\n" +
+				"Index: trunk/old.c\n" +
+				"===================================================================\n" +
+				"--- trunk/old.c\t(revision 41)\n" +
+				"+++ trunk/old.c\t(nonexistent)\n" +
+				"@@ -1 +0,0 @@\n-int d;\n" +
+				"\n" +
+				"Property changes on: trunk/old.c\n" +
+				"___________________________________________________________________\n" +
+				"Deleted: svn:eol-style\n" +
+				"## -1 +0,0 ##\n" +
+				"-native\n",
+			want: []string{"trunk/main.c||1|3", "trunk/new.c|added|1|2", "trunk/old.c|deleted|0|2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks, err := Parse(tt.diff)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := hunkSummary(hunks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_NonGitBinaryFiles(t *testing.T) {
+	diff := "diff -r 1a2b3c4d5e6f logo.png\n" +
+		"Binary file logo.png has changed\n" +
+		"Index: trunk/icon.png\n" +
+		"===================================================================\n" +
+		"Cannot display: file marked as a binary type.\n" +
+		"svn:mime-type = application/octet-stream\n" +
+		"diff -ruN before/font.ttf after/font.ttf\n" +
+		"Binary files before/font.ttf and after/font.ttf differ\n"
+
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var files []string
+	for _, h := range hunks {
+		if !h.Binary {
+			t.Errorf("expected %s to be binary", h.File)
+		}
+		files = append(files, h.File)
+	}
+	if want := []string{"logo.png", "trunk/icon.png", "font.ttf"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %q, want %q", files, want)
+	}
+}
+
+func TestParseCommandPaths(t *testing.T) {
+	tests := []struct {
+		in, old, new string
+	}{
+		{"-r 1a2b3c4d5e6f src/app.py", "src/app.py", "src/app.py"},
+		{"-r 1a2b3c4d5e6f -r 6f5e4d3c2b1a app.py", "app.py", "app.py"},
+		{"-ruN before/main.go after/main.go", "before/main.go", "after/main.go"},
+		{"-r -u a b", "a", "b"},
+	}
+	for _, tt := range tests {
+		old, new := parseCommandPaths(tt.in)
+		if old != tt.old || new != tt.new {
+			t.Errorf("parseCommandPaths(%q) = %q, %q; want %q, %q", tt.in, old, new, tt.old, tt.new)
+		}
+	}
+}
+
+func TestParse_CombinedDiff(t *testing.T) {
+	diff := `diff --cc config.go
+index 1111111,2222222..3333333
+--- a/config.go
++++ b/config.go
+@@@ -1,5 -1,5 +1,4 @@@
+  package config
+  
+- const Timeout = 10
+ -const Timeout = 30
+++const Timeout = 20
+--- comment from ours
+  var x = 1
+diff --git a/other.go b/other.go
+index 4444444..5555555 100644
+--- a/other.go
++++ b/other.go
+@@ -1 +1 @@
+-a
++b
+`
+	hunks, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d: %+v", len(hunks), hunks)
+	}
+	merge := hunks[0]
+	if merge.File != "config.go" || merge.ID != "config.go::1" || merge.StartLine != 1 {
+		t.Errorf("unexpected combined hunk: %+v", merge)
+	}
+	if !strings.HasPrefix(merge.Diff, "@@@ ") || !strings.Contains(merge.Diff, "--- comment from ours") {
+		t.Errorf("expected the whole combined hunk, got %q", merge.Diff)
+	}
+	if err := CheckHunk(merge.Diff); err != nil {
+		t.Errorf("expected a well-formed combined hunk: %v", err)
+	}
+	if hunks[1].File != "other.go" {
+		t.Errorf("expected other.go after the combined diff, got %q", hunks[1].File)
+	}
+}
+
+func TestParse_Fingerprints(t *testing.T) {
+	before := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -10 +10 @@
+-x
++y
+@@ -20 +20 @@
+-x
++y
+`
+	after := strings.ReplaceAll(strings.ReplaceAll(before, "-10 +10", "-13 +13"), "-20 +20", "-23 +23")
+
+	first, err := Parse(before)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := Parse(after)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if first[0].Fingerprint == "" || first[0].Fingerprint == first[1].Fingerprint {
+		t.Errorf("expected distinct fingerprints for identical changes, got %q and %q", first[0].Fingerprint, first[1].Fingerprint)
+	}
+	for i := range first {
+		if first[i].ID == second[i].ID {
+			t.Errorf("expected IDs to change when hunks move, both %q", first[i].ID)
+		}
+		if first[i].Fingerprint != second[i].Fingerprint {
+			t.Errorf("hunk %d fingerprint changed when it moved: %q → %q", i, first[i].Fingerprint, second[i].Fingerprint)
+		}
+	}
+}
@@ -39,6 +39,26 @@ func ColorizeDiffLine(line string) string {
 	}
 }
 
+// ColorizeCombinedDiffLine colors a line of a merge's combined diff, which
+// has one prefix column per parent: lines removed from any parent are shown
+// as deletions, and lines added relative to any parent as additions.
+func ColorizeCombinedDiffLine(line string, parents int) string {
+	if strings.HasPrefix(line, "@") {
+		return hunkHeaderStyle.Render(line)
+	}
+	columns := line[:min(parents, len(line))]
+	switch {
+	case strings.Contains(columns, "-"):
+		return deletionStyle.Render(line)
+	case strings.Contains(columns, "+"):
+		return additionStyle.Render(line)
+	case line == "":
+		return line
+	default:
+		return contextStyle.Render(line)
+	}
+}
+
 // ColorizeDiff applies color styling to all lines in a diff.
 func ColorizeDiff(diff string) string {
 	if diff == "" {
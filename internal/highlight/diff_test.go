@@ -114,3 +114,41 @@ func TestColorizeDiff_EmptyDiff(t *testing.T) {
 		t.Errorf("expected empty string, got %q", result)
 	}
 }
+
+func TestColorizeCombinedDiffLine(t *testing.T) {
+	// stylePrefix is the escape sequence a rendered line starts with
+	stylePrefix := func(rendered, line string) string {
+		prefix, _, _ := strings.Cut(rendered, line)
+		return prefix
+	}
+	addition := stylePrefix(highlight.ColorizeDiffLine("+x"), "+x")
+	deletion := stylePrefix(highlight.ColorizeDiffLine("-x"), "-x")
+	context := stylePrefix(highlight.ColorizeDiffLine(" x"), " x")
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"++resolved", addition},
+		{" +from first parent", addition},
+		{"+ from second parent", addition},
+		{"- dropped from first parent", deletion},
+		{"--dropped from both", deletion},
+		{"  unchanged", context},
+		{"+-x", deletion},
+	}
+	for _, tt := range tests {
+		got := highlight.ColorizeCombinedDiffLine(tt.line, 2)
+		if !strings.Contains(got, tt.line) {
+			t.Errorf("expected result to contain original line %q", tt.line)
+		}
+		if prefix := stylePrefix(got, tt.line); prefix != tt.want {
+			t.Errorf("line %q styled %q, want %q", tt.line, prefix, tt.want)
+		}
+	}
+
+	header := "@@@ -1,2 -1,2 +1,3 @@@"
+	if got := highlight.ColorizeCombinedDiffLine(header, 2); got != highlight.ColorizeDiffLine(header) {
+		t.Errorf("expected combined header to be styled like an ordinary one, got %q", got)
+	}
+}
@@ -0,0 +1,278 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxTokens limits the length of replies from HTTP providers when no
+// limit is configured
+const DefaultMaxTokens = 16000
+
+// Default base URLs of the HTTP APIs
+const (
+	AnthropicBaseURL = "https://api.anthropic.com"
+	OpenAIBaseURL    = "https://api.openai.com/v1"
+)
+
+// anthropicVersion is the Messages API version requests are written against
+const anthropicVersion = "2023-06-01"
+
+// DefaultTimeout limits how long a request to an HTTP provider may take when
+// no client is given. Classifying a large diff can take minutes, but a
+// server that never answers should not hang generation.
+const DefaultTimeout = 10 * time.Minute
+
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// replyName names the structured reply in requests that carry a schema
+const replyName = "reply"
+
+// Anthropic calls the Anthropic Messages API
+type Anthropic struct {
+	BaseURL   string // Defaults to AnthropicBaseURL
+	Model     string
+	APIKey    string
+	MaxTokens int          // Defaults to DefaultMaxTokens
+	Client    *http.Client // Defaults to a client with DefaultTimeout
+}
+
+// Name returns the provider and model
+func (a Anthropic) Name() string {
+	return "anthropic:" + a.Model
+}
+
+// chatMessage is a message of the conversation sent to either API
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	Messages   []chatMessage        `json:"messages"`
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// anthropicTool is a tool the model may call; its input is the structured
+// reply
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+// Complete sends the prompt as a single user message and returns the text of
+// the reply. With a schema, the model is made to answer by calling a tool
+// whose input follows it, and the tool's input is returned instead.
+func (a Anthropic) Complete(ctx context.Context, req Request) (string, error) {
+	maxTokens := a.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	body := anthropicRequest{
+		Model:     a.Model,
+		MaxTokens: maxTokens,
+		Messages:  []chatMessage{{Role: "user", Content: req.Prompt}},
+	}
+	if req.Schema != "" {
+		body.Tools = []anthropicTool{{Name: replyName, Description: "Give the reply", InputSchema: json.RawMessage(req.Schema)}}
+		body.ToolChoice = &anthropicToolChoice{Type: "tool", Name: replyName}
+	}
+	headers := map[string]string{
+		"x-api-key":         a.APIKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	var resp anthropicResponse
+	if err := postJSON(ctx, a.Client, orDefault(a.BaseURL, AnthropicBaseURL)+"/v1/messages", headers, body, &resp); err != nil {
+		return "", err
+	}
+	if resp.StopReason == "max_tokens" {
+		return "", fmt.Errorf("reply was cut off at %d tokens; raise maxTokens", maxTokens)
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "tool_use":
+			return string(block.Input), nil
+		case "text":
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String(), nil
+}
+
+// OpenAI calls an OpenAI-compatible chat completions API, as served by
+// OpenAI and by local servers such as Ollama and llama.cpp
+type OpenAI struct {
+	BaseURL   string // Defaults to OpenAIBaseURL; local servers usually end in /v1
+	Model     string
+	APIKey    string       // Optional for local servers
+	MaxTokens int          // Left to the server when zero
+	Client    *http.Client // Defaults to a client with DefaultTimeout
+}
+
+// Name returns the provider and model
+func (o OpenAI) Name() string {
+	return "openai:" + o.Model
+}
+
+type openAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []chatMessage         `json:"messages"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// openAIResponseFormat asks for a reply that follows a JSON Schema
+type openAIResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+	} `json:"json_schema"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// Complete sends the prompt as a single user message and returns the text of
+// the first choice. With a schema, the reply is asked to follow it; servers
+// that reject the request for that, as many local ones do, are asked again
+// without it, leaving the prompt to describe the reply.
+func (o OpenAI) Complete(ctx context.Context, req Request) (string, error) {
+	body := openAIRequest{
+		Model:     o.Model,
+		Messages:  []chatMessage{{Role: "user", Content: req.Prompt}},
+		MaxTokens: o.MaxTokens,
+	}
+	if req.Schema != "" {
+		format := &openAIResponseFormat{Type: "json_schema"}
+		format.JSONSchema.Name = replyName
+		format.JSONSchema.Schema = json.RawMessage(req.Schema)
+		body.ResponseFormat = format
+	}
+	headers := map[string]string{}
+	if o.APIKey != "" {
+		headers["Authorization"] = "Bearer " + o.APIKey
+	}
+
+	url := orDefault(o.BaseURL, OpenAIBaseURL) + "/chat/completions"
+	var resp openAIResponse
+	err := postJSON(ctx, o.Client, url, headers, body, &resp)
+	var statusErr *statusError
+	if body.ResponseFormat != nil && errors.As(err, &statusErr) && statusErr.Code == http.StatusBadRequest {
+		body.ResponseFormat = nil
+		err = postJSON(ctx, o.Client, url, headers, body, &resp)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("reply has no choices")
+	}
+	if resp.Choices[0].FinishReason == "length" {
+		return "", fmt.Errorf("reply was cut off at the token limit; raise maxTokens")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// apiError is the error body both APIs return with a failing status
+type apiError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// statusError is a failing status from either API, with the API's message
+type statusError struct {
+	Code    int
+	Status  string
+	Message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// postJSON posts body as JSON to url and decodes the response into out,
+// turning error statuses into errors that carry the API's message
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out any) error {
+	if client == nil {
+		client = defaultClient
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(respBody))
+		var apiErr apiError
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		return &statusError{Code: resp.StatusCode, Status: resp.Status, Message: message}
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return strings.TrimRight(value, "/")
+}
@@ -0,0 +1,286 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubServer answers every request with status and body, recording the last
+// request's path, headers and decoded JSON body
+type stubServer struct {
+	*httptest.Server
+	path    string
+	headers http.Header
+	body    map[string]any
+}
+
+func newStubServer(t *testing.T, status int, body string) *stubServer {
+	t.Helper()
+	s := &stubServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.path = r.URL.Path
+		s.headers = r.Header.Clone()
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &s.body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestAnthropic_Complete(t *testing.T) {
+	server := newStubServer(t, http.StatusOK,
+		`{"content":[{"type":"text","text":"{\"title\":"},{"type":"text","text":"\"T\"}"}],"stop_reason":"end_turn"}`)
+	a := Anthropic{BaseURL: server.URL + "/", Model: "claude-test", APIKey: "secret", MaxTokens: 1000}
+
+	output, err := a.Complete(context.Background(), Request{Prompt: "classify"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if output != `{"title":"T"}` {
+		t.Errorf("expected the text blocks joined, got %q", output)
+	}
+	if server.path != "/v1/messages" {
+		t.Errorf("expected a request to /v1/messages, got %q", server.path)
+	}
+	if server.headers.Get("x-api-key") != "secret" || server.headers.Get("anthropic-version") == "" {
+		t.Errorf("expected API key and version headers, got %v", server.headers)
+	}
+	if server.body["model"] != "claude-test" || server.body["max_tokens"] != float64(1000) {
+		t.Errorf("expected model and max_tokens in the body, got %v", server.body)
+	}
+	messages := server.body["messages"].([]any)
+	if msg := messages[0].(map[string]any); msg["role"] != "user" || msg["content"] != "classify" {
+		t.Errorf("expected the prompt as a user message, got %v", messages)
+	}
+}
+
+func TestAnthropic_SchemaIsAToolInput(t *testing.T) {
+	server := newStubServer(t, http.StatusOK,
+		`{"content":[{"type":"tool_use","id":"t1","name":"reply","input":{"title":"T"}}],"stop_reason":"tool_use"}`)
+	a := Anthropic{BaseURL: server.URL, Model: "claude-test"}
+
+	output, err := a.Complete(context.Background(), Request{Prompt: "classify", Schema: `{"type":"object"}`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if output != `{"title":"T"}` {
+		t.Errorf("expected the tool input, got %q", output)
+	}
+	tools, _ := server.body["tools"].([]any)
+	if len(tools) != 1 {
+		t.Fatalf("expected one tool, got %v", server.body["tools"])
+	}
+	tool := tools[0].(map[string]any)
+	if schema, _ := tool["input_schema"].(map[string]any); schema["type"] != "object" {
+		t.Errorf("expected the schema as the tool's input_schema, got %v", tool)
+	}
+	if choice, _ := server.body["tool_choice"].(map[string]any); choice["type"] != "tool" || choice["name"] != tool["name"] {
+		t.Errorf("expected the tool to be required, got %v", server.body["tool_choice"])
+	}
+}
+
+func TestAnthropic_DefaultsMaxTokens(t *testing.T) {
+	server := newStubServer(t, http.StatusOK, `{"content":[],"stop_reason":"end_turn"}`)
+	a := Anthropic{BaseURL: server.URL, Model: "claude-test"}
+
+	if _, err := a.Complete(context.Background(), Request{Prompt: "p"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if server.body["max_tokens"] != float64(DefaultMaxTokens) {
+		t.Errorf("expected max_tokens %d, got %v", DefaultMaxTokens, server.body["max_tokens"])
+	}
+}
+
+func TestAnthropic_TruncatedReplyIsAnError(t *testing.T) {
+	server := newStubServer(t, http.StatusOK, `{"content":[{"type":"text","text":"{\"ti"}],"stop_reason":"max_tokens"}`)
+	a := Anthropic{BaseURL: server.URL, Model: "claude-test", MaxTokens: 10}
+
+	_, err := a.Complete(context.Background(), Request{Prompt: "p"})
+
+	if err == nil || !strings.Contains(err.Error(), "maxTokens") {
+		t.Errorf("expected an error suggesting maxTokens, got %v", err)
+	}
+}
+
+func TestAnthropic_ReportsAPIErrors(t *testing.T) {
+	server := newStubServer(t, http.StatusUnauthorized,
+		`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	a := Anthropic{BaseURL: server.URL, Model: "claude-test"}
+
+	_, err := a.Complete(context.Background(), Request{Prompt: "p"})
+
+	if err == nil || !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "invalid x-api-key") {
+		t.Errorf("expected the status and API message, got %v", err)
+	}
+}
+
+func TestOpenAI_Complete(t *testing.T) {
+	server := newStubServer(t, http.StatusOK,
+		`{"choices":[{"message":{"role":"assistant","content":"{\"title\":\"T\"}"},"finish_reason":"stop"}]}`)
+	o := OpenAI{BaseURL: server.URL + "/v1", Model: "llama3", APIKey: "secret"}
+
+	output, err := o.Complete(context.Background(), Request{Prompt: "classify"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if output != `{"title":"T"}` {
+		t.Errorf("expected the first choice's content, got %q", output)
+	}
+	if server.path != "/v1/chat/completions" {
+		t.Errorf("expected a request to /v1/chat/completions, got %q", server.path)
+	}
+	if server.headers.Get("Authorization") != "Bearer secret" {
+		t.Errorf("expected a bearer token, got %q", server.headers.Get("Authorization"))
+	}
+	if _, ok := server.body["max_tokens"]; ok {
+		t.Errorf("expected max_tokens left to the server, got %v", server.body)
+	}
+}
+
+func TestOpenAI_SchemaIsTheResponseFormat(t *testing.T) {
+	server := newStubServer(t, http.StatusOK, `{"choices":[{"message":{"content":"{}"},"finish_reason":"stop"}]}`)
+	o := OpenAI{BaseURL: server.URL, Model: "llama3"}
+
+	if _, err := o.Complete(context.Background(), Request{Prompt: "p", Schema: `{"type":"object"}`}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	format, _ := server.body["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Fatalf("expected a json_schema response format, got %v", server.body["response_format"])
+	}
+	jsonSchema, _ := format["json_schema"].(map[string]any)
+	if schema, _ := jsonSchema["schema"].(map[string]any); jsonSchema["name"] == "" || schema["type"] != "object" {
+		t.Errorf("expected the named schema, got %v", jsonSchema)
+	}
+}
+
+func TestOpenAI_RetriesWithoutResponseFormatWhenRejected(t *testing.T) {
+	var formats []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		formats = append(formats, body["response_format"])
+		if body["response_format"] != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"response_format json_schema is not supported"}}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"{\"title\":\"T\"}"},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(server.Close)
+	o := OpenAI{BaseURL: server.URL, Model: "llama3"}
+
+	output, err := o.Complete(context.Background(), Request{Prompt: "p", Schema: `{"type":"object"}`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if output != `{"title":"T"}` {
+		t.Errorf("expected the reply to the plain request, got %q", output)
+	}
+	if len(formats) != 2 || formats[0] == nil || formats[1] != nil {
+		t.Errorf("expected a request with a response format, then one without, got %v", formats)
+	}
+}
+
+func TestOpenAI_OtherErrorsAreNotRetried(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":{"message":"bad key"}}`)
+	}))
+	t.Cleanup(server.Close)
+	o := OpenAI{BaseURL: server.URL, Model: "gpt"}
+
+	_, err := o.Complete(context.Background(), Request{Prompt: "p", Schema: `{"type":"object"}`})
+
+	if err == nil || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("expected the API error, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected one request, got %d", requests)
+	}
+}
+
+func TestHTTPProviders_OmitStructuredOutputWithoutSchema(t *testing.T) {
+	anthropic := newStubServer(t, http.StatusOK, `{}`)
+	openAI := newStubServer(t, http.StatusOK, `{}`)
+
+	Anthropic{BaseURL: anthropic.URL}.Complete(context.Background(), Request{Prompt: "p"})
+	if _, ok := anthropic.body["tools"]; ok {
+		t.Errorf("expected no tools without a schema, got %v", anthropic.body)
+	}
+	OpenAI{BaseURL: openAI.URL}.Complete(context.Background(), Request{Prompt: "p"})
+	if _, ok := openAI.body["response_format"]; ok {
+		t.Errorf("expected no response format without a schema, got %v", openAI.body)
+	}
+}
+
+func TestOpenAI_LocalServerWithoutKey(t *testing.T) {
+	server := newStubServer(t, http.StatusOK, `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+	o := OpenAI{BaseURL: server.URL, Model: "llama3", MaxTokens: 500}
+
+	if _, err := o.Complete(context.Background(), Request{Prompt: "p"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if auth := server.headers.Get("Authorization"); auth != "" {
+		t.Errorf("expected no Authorization header, got %q", auth)
+	}
+	if server.body["max_tokens"] != float64(500) {
+		t.Errorf("expected max_tokens 500, got %v", server.body["max_tokens"])
+	}
+}
+
+func TestOpenAI_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{"api error", http.StatusNotFound, `{"error":{"message":"model \"nope\" not found"}}`, `model "nope" not found`},
+		{"plain error", http.StatusBadGateway, "upstream down", "upstream down"},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "no choices"},
+		{"truncated", http.StatusOK, `{"choices":[{"message":{"content":"{"},"finish_reason":"length"}]}`, "maxTokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubServer(t, tt.status, tt.body)
+			o := OpenAI{BaseURL: server.URL, Model: "nope"}
+
+			_, err := o.Complete(context.Background(), Request{Prompt: "p"})
+
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestHTTPProviders_StopWhenCancelled(t *testing.T) {
+	server := newStubServer(t, http.StatusOK, `{}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, p := range []Provider{Anthropic{BaseURL: server.URL}, OpenAI{BaseURL: server.URL}} {
+		if _, err := p.Complete(ctx, Request{Prompt: "p"}); err == nil {
+			t.Errorf("%s: expected an error once cancelled", p.Name())
+		}
+	}
+}
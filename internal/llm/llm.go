@@ -0,0 +1,146 @@
+// Package llm sends prompts to the LLM that classifies diffs, either by
+// running a command-line tool or by calling an HTTP API directly.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Provider completes prompts with an LLM
+type Provider interface {
+	// Complete sends the request's prompt and returns the LLM's reply text
+	Complete(ctx context.Context, req Request) (string, error)
+	// Name describes the provider for logs and messages
+	Name() string
+}
+
+// Request is one prompt sent to a provider
+type Request struct {
+	Prompt string
+	Schema string // JSON Schema of the expected reply, for providers that support structured output
+	Dir    string // Working directory for providers that run a command
+}
+
+// schemaPlaceholder in a command argument is replaced with the request's
+// schema, for commands that accept one for structured output
+const schemaPlaceholder = "{schema}"
+
+// Delivery is how a command receives the prompt and the hunks it classifies
+type Delivery string
+
+const (
+	// DeliverFile writes the hunks to a file in a private temporary
+	// directory, which the prompt names, and passes the prompt as the final
+	// argument, keeping the argument short. The command must be able to read
+	// files outside its working directory.
+	DeliverFile Delivery = "file"
+	// DeliverArgument includes the hunks in the prompt, passed as the final
+	// argument, for tools that cannot read files
+	DeliverArgument Delivery = "argument"
+	// DeliverStdin includes the hunks in the prompt, written to standard
+	// input. It is the default: it has no argument length limit and needs no
+	// access to files, which claude -p is not given outside its working
+	// directory.
+	DeliverStdin Delivery = "stdin"
+)
+
+// ParseDelivery checks a configured delivery, where "" means DeliverStdin
+func ParseDelivery(s string) (Delivery, error) {
+	switch d := Delivery(s); d {
+	case "":
+		return DeliverStdin, nil
+	case DeliverFile, DeliverArgument, DeliverStdin:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unknown prompt delivery %q (use \"file\", \"argument\" or \"stdin\")", s)
+	}
+}
+
+// ReadsInputFile reports whether p is given its hunks in a file rather than
+// in the prompt. HTTP APIs can only be sent the prompt.
+func ReadsInputFile(p Provider) bool {
+	c, ok := p.(Command)
+	return ok && c.Delivery == DeliverFile
+}
+
+// Identity describes everything about p that can change its replies, for
+// caching them: a command's whole command line, or an API's URL, model and
+// reply limit. API keys are left out.
+func Identity(p Provider) string {
+	switch p := p.(type) {
+	case Command:
+		return "command\x00" + strings.Join(p.Args, "\x00")
+	case Anthropic:
+		return fmt.Sprintf("anthropic\x00%s\x00%s\x00%d", orDefault(p.BaseURL, AnthropicBaseURL), p.Model, p.MaxTokens)
+	case OpenAI:
+		return fmt.Sprintf("openai\x00%s\x00%s\x00%d", orDefault(p.BaseURL, OpenAIBaseURL), p.Model, p.MaxTokens)
+	default:
+		return p.Name()
+	}
+}
+
+// Describe returns p as recorded in a review: a command's command line, or
+// an API's name and model, with its URL when it is not the public one
+func Describe(p Provider) string {
+	switch p := p.(type) {
+	case Command:
+		return strings.Join(p.Args, " ")
+	case Anthropic:
+		if url := orDefault(p.BaseURL, AnthropicBaseURL); url != AnthropicBaseURL {
+			return p.Name() + " at " + url
+		}
+	case OpenAI:
+		if url := orDefault(p.BaseURL, OpenAIBaseURL); url != OpenAIBaseURL {
+			return p.Name() + " at " + url
+		}
+	}
+	return p.Name()
+}
+
+// Command runs a command-line LLM tool, passing the prompt as its final
+// argument or on standard input
+type Command struct {
+	Args     []string
+	Delivery Delivery // Defaults to DeliverStdin
+}
+
+// Name returns the command's program name
+func (c Command) Name() string {
+	return c.Args[0]
+}
+
+// Complete runs the command in req.Dir and returns its standard output
+func (c Command) Complete(ctx context.Context, req Request) (string, error) {
+	args := withSchemaArgs(c.Args[1:], req.Schema)
+	stdin := c.Delivery == "" || c.Delivery == DeliverStdin
+	if !stdin {
+		args = append(args, req.Prompt)
+	}
+	cmd := exec.CommandContext(ctx, c.Args[0], args...)
+	cmd.Dir = req.Dir
+	if stdin {
+		cmd.Stdin = strings.NewReader(req.Prompt)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// withSchemaArgs copies args, substituting schemaJSON for schemaPlaceholder
+func withSchemaArgs(args []string, schemaJSON string) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = strings.ReplaceAll(arg, schemaPlaceholder, schemaJSON)
+	}
+	return result
+}
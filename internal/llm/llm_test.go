@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWithSchemaArgs_SubstitutesPlaceholder(t *testing.T) {
+	args := []string{"-p", "--json-schema", "{schema}", "--label={schema}"}
+
+	got := withSchemaArgs(args, `{"type":"object"}`)
+
+	want := []string{"-p", "--json-schema", `{"type":"object"}`, `--label={"type":"object"}`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("withSchemaArgs = %q, want %q", got, want)
+	}
+	if args[2] != "{schema}" {
+		t.Error("withSchemaArgs should not modify its input")
+	}
+}
+
+func TestCommand_PassesPromptAsFinalArgument(t *testing.T) {
+	dir := t.TempDir()
+	c := Command{Args: []string{"sh", "-c", `echo "$PWD $1 $2"`, "llm", "{schema}"}, Delivery: DeliverArgument}
+
+	output, err := c.Complete(context.Background(), Request{Prompt: "the prompt", Schema: "S", Dir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.TrimSpace(output) != dir+" S the prompt" {
+		t.Errorf("expected the schema and prompt run in %s, got %q", dir, output)
+	}
+}
+
+func TestCommand_ReportsStderrOnFailure(t *testing.T) {
+	c := Command{Args: []string{"sh", "-c", "echo quota exceeded >&2; exit 3", "llm"}}
+
+	_, err := c.Complete(context.Background(), Request{Prompt: "p", Dir: t.TempDir()})
+
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("expected the command's stderr in the error, got %v", err)
+	}
+}
+
+func TestCommand_StdinDelivery(t *testing.T) {
+	c := Command{Args: []string{"sh", "-c", `echo "args: $#"; cat`, "llm"}, Delivery: DeliverStdin}
+
+	output, err := c.Complete(context.Background(), Request{Prompt: "the prompt", Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if output != "args: 0\nthe prompt" {
+		t.Errorf("expected the prompt on stdin and no extra argument, got %q", output)
+	}
+}
+
+func TestParseDelivery(t *testing.T) {
+	for input, expected := range map[string]Delivery{"": DeliverStdin, "file": DeliverFile, "argument": DeliverArgument, "stdin": DeliverStdin} {
+		if got, err := ParseDelivery(input); err != nil || got != expected {
+			t.Errorf("ParseDelivery(%q) = %q, %v; want %q", input, got, err, expected)
+		}
+	}
+	if _, err := ParseDelivery("pipe"); err == nil || !strings.Contains(err.Error(), `"pipe"`) {
+		t.Errorf("expected an error naming the unknown delivery, got %v", err)
+	}
+}
+
+func TestReadsInputFile(t *testing.T) {
+	tests := []struct {
+		provider Provider
+		expected bool
+	}{
+		{Command{Args: []string{"claude"}}, false},
+		{Command{Args: []string{"claude"}, Delivery: DeliverFile}, true},
+		{Command{Args: []string{"llm"}, Delivery: DeliverArgument}, false},
+		{Command{Args: []string{"llm"}, Delivery: DeliverStdin}, false},
+		{Anthropic{}, false},
+		{OpenAI{}, false},
+	}
+	for _, tt := range tests {
+		if got := ReadsInputFile(tt.provider); got != tt.expected {
+			t.Errorf("ReadsInputFile(%#v) = %v, want %v", tt.provider, got, tt.expected)
+		}
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	same := [][2]Provider{
+		{Command{Args: []string{"claude", "-p"}}, Command{Args: []string{"claude", "-p"}, Delivery: DeliverStdin}},
+		{Anthropic{Model: "m", APIKey: "one"}, Anthropic{BaseURL: AnthropicBaseURL + "/", Model: "m", APIKey: "two"}},
+	}
+	for _, pair := range same {
+		if Identity(pair[0]) != Identity(pair[1]) {
+			t.Errorf("expected %#v and %#v to share an identity", pair[0], pair[1])
+		}
+	}
+
+	different := [][2]Provider{
+		{Command{Args: []string{"claude", "-p"}}, Command{Args: []string{"claude", "-p", "--model", "opus"}}},
+		{Anthropic{Model: "m"}, Anthropic{Model: "n"}},
+		{Anthropic{Model: "m"}, OpenAI{Model: "m"}},
+		{OpenAI{Model: "m"}, OpenAI{Model: "m", BaseURL: "http://localhost:11434/v1"}},
+	}
+	for _, pair := range different {
+		if Identity(pair[0]) == Identity(pair[1]) {
+			t.Errorf("expected %#v and %#v to differ", pair[0], pair[1])
+		}
+	}
+	if strings.Contains(Identity(Anthropic{Model: "m", APIKey: "secret"}), "secret") {
+		t.Error("expected the API key left out")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		provider Provider
+		want     string
+	}{
+		{Command{Args: []string{"claude", "-p"}}, "claude -p"},
+		{Anthropic{Model: "m", APIKey: "secret"}, "anthropic:m"},
+		{OpenAI{Model: "llama3.1", BaseURL: "http://localhost:11434/v1"}, "openai:llama3.1 at http://localhost:11434/v1"},
+	}
+	for _, tt := range tests {
+		if got := Describe(tt.provider); got != tt.want {
+			t.Errorf("Describe(%#v) = %q, want %q", tt.provider, got, tt.want)
+		}
+	}
+}
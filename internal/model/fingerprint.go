@@ -0,0 +1,205 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fingerprintLength is the number of hex characters kept from a hunk's
+// content hash
+const fingerprintLength = 16
+
+// HunkFingerprint returns a content-based identity for a hunk: a hash of its
+// file and its added and removed lines, ignoring trailing whitespace. Unlike a
+// hunk's ID it does not depend on where the hunk starts, so it still matches
+// when edits elsewhere in the file shift the hunk, or its context lines change.
+// Hunks without an @@ header (renames, mode changes, binary files) are hashed
+// by their header lines, leaving out index lines, whose blob hashes change
+// with unrelated edits.
+func HunkFingerprint(file, hunkDiff string) string {
+	h := sha256.New()
+	h.Write([]byte(file))
+	h.Write([]byte{0})
+	for _, line := range fingerprintLines(hunkDiff) {
+		h.Write([]byte(strings.TrimRight(line, " \t\r")))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:fingerprintLength]
+}
+
+// fingerprintLines returns the lines of a hunk that identify it
+func fingerprintLines(hunkDiff string) []string {
+	lines := strings.Split(strings.TrimRight(hunkDiff, "\n"), "\n")
+	var kept []string
+	if !strings.HasPrefix(lines[0], "@@") {
+		for _, line := range lines {
+			if !strings.HasPrefix(line, "index ") {
+				kept = append(kept, line)
+			}
+		}
+		return kept
+	}
+
+	// Combined diffs of merges have one more "@" than parents, and one
+	// prefix column per parent
+	parents := len(lines[0]) - len(strings.TrimLeft(lines[0], "@")) - 1
+	for _, line := range lines[1:] {
+		columns := line[:min(parents, len(line))]
+		if strings.ContainsAny(columns, "+-") {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// FingerprintCounter tells identical changes in one file apart by their
+// order, like hunk IDs: the first keeps its fingerprint and later ones get a
+// #n suffix
+type FingerprintCounter map[string]int
+
+// Next returns the fingerprint of the next hunk whose content hashes to fp
+func (c FingerprintCounter) Next(fp string) string {
+	count := c[fp]
+	c[fp]++
+	if count == 0 {
+		return fp
+	}
+	return fmt.Sprintf("%s#%d", fp, count+1)
+}
+
+// ContentFingerprint returns the hunk's stored fingerprint, computing it for
+// hunks from reviews written before fingerprints were recorded. Such a hunk
+// cannot be told apart from an identical one this way; FillFingerprints
+// does that for a whole review.
+func (h Hunk) ContentFingerprint() string {
+	if h.Fingerprint != "" {
+		return h.Fingerprint
+	}
+	return HunkFingerprint(h.File, h.Diff)
+}
+
+// FillFingerprints records a fingerprint on every hunk of r that lacks one,
+// as hunks of reviews written before fingerprints were recorded do.
+// Identical changes in a file are told apart by the order of their start
+// lines, as the diff parser tells them apart by their order in the diff.
+func (r *Review) FillFingerprints() {
+	var missing []*Hunk
+	for ci := range r.Chapters {
+		for si := range r.Chapters[ci].Sections {
+			hunks := r.Chapters[ci].Sections[si].Hunks
+			for hi := range hunks {
+				if hunks[hi].Fingerprint == "" {
+					missing = append(missing, &hunks[hi])
+				}
+			}
+		}
+	}
+	sort.SliceStable(missing, func(i, j int) bool {
+		if missing[i].File != missing[j].File {
+			return missing[i].File < missing[j].File
+		}
+		return missing[i].StartLine < missing[j].StartLine
+	})
+	counter := FingerprintCounter{}
+	for _, h := range missing {
+		h.Fingerprint = counter.Next(HunkFingerprint(h.File, h.Diff))
+	}
+}
+
+// WithFingerprints returns a copy of r whose hunks all have fingerprints,
+// leaving r's hunks as they are
+func (r Review) WithFingerprints() Review {
+	chapters := make([]Chapter, len(r.Chapters))
+	for ci, ch := range r.Chapters {
+		ch.Sections = append([]Section(nil), ch.Sections...)
+		for si := range ch.Sections {
+			ch.Sections[si].Hunks = append([]Hunk(nil), ch.Sections[si].Hunks...)
+		}
+		chapters[ci] = ch
+	}
+	r.Chapters = chapters
+	r.FillFingerprints()
+	return r
+}
+
+// CarryOver copies reader state from a previous review of the same changes
+// onto r, following hunks by fingerprint so the state survives shifted line
+// numbers and a different classification: hunks reviewed before (directly
+// or through their section) stay reviewed, and notes move to the hunk they
+// were written on, found by its fingerprint (or, for notes written before
+// notes recorded one, its identity). Notes on line-level positions keep
+// their line when the same text is still in the hunk, and otherwise annotate
+// the whole hunk. Notes whose hunk is gone are kept, so nothing the reader
+// wrote is lost, but orphaned: without a hunk ID, so they cannot attach to
+// an unrelated hunk that now starts where theirs did. It returns the number
+// of reviewed marks and notes that found their hunk.
+func (r *Review) CarryOver(previous Review) int {
+	previous = previous.WithFingerprints()
+	r.FillFingerprints()
+	reviewedBefore := make(map[string]bool)
+	byIdentity := make(map[string]Hunk)
+	byFingerprint := make(map[string]Hunk)
+	for _, s := range previous.AllSections() {
+		for _, h := range s.Hunks {
+			reviewedBefore[h.ContentFingerprint()] = h.Reviewed || s.Reviewed
+			byIdentity[h.Identity()] = h
+			byFingerprint[h.ContentFingerprint()] = h
+		}
+	}
+
+	current := make(map[string]Hunk)
+	carried := 0
+	for ci := range r.Chapters {
+		for si := range r.Chapters[ci].Sections {
+			hunks := r.Chapters[ci].Sections[si].Hunks
+			for hi := range hunks {
+				fp := hunks[hi].ContentFingerprint()
+				current[fp] = hunks[hi]
+				if reviewedBefore[fp] && !hunks[hi].Reviewed {
+					hunks[hi].Reviewed = true
+					carried++
+				}
+			}
+		}
+	}
+
+	for _, n := range previous.Notes {
+		if n.Orphaned {
+			r.Notes = append(r.Notes, n)
+			continue
+		}
+		old, ok := byFingerprint[n.Fingerprint]
+		if !ok {
+			old, ok = byIdentity[n.HunkID]
+		}
+		if h, found := current[old.ContentFingerprint()]; ok && found {
+			n.HunkID = h.Identity()
+			n.Fingerprint = h.ContentFingerprint()
+			n.File = h.File
+			n.Line = matchingLine(old.Diff, h.Diff, n.Line)
+			carried++
+		} else {
+			n.HunkID, n.Line, n.Orphaned = "", 0, true
+		}
+		r.Notes = append(r.Notes, n)
+	}
+	return carried
+}
+
+// matchingLine finds the 1-based line of newDiff with the text line had in
+// oldDiff, or 0 when line is 0 or the text is gone
+func matchingLine(oldDiff, newDiff string, line int) int {
+	oldLines := strings.Split(oldDiff, "\n")
+	if line <= 0 || line > len(oldLines) {
+		return 0
+	}
+	for i, text := range strings.Split(newDiff, "\n") {
+		if text == oldLines[line-1] {
+			return i + 1
+		}
+	}
+	return 0
+}
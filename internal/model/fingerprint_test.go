@@ -0,0 +1,203 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestHunkFingerprint_IgnoresPosition(t *testing.T) {
+	before := "@@ -10,3 +10,3 @@ func main() {\n context\n-old\n+new"
+	shifted := "@@ -42,3 +45,3 @@ func run() {\n other context\n-old\n+new  "
+
+	if HunkFingerprint("a.go", before) != HunkFingerprint("a.go", shifted) {
+		t.Error("expected the same fingerprint when only position, context and trailing whitespace differ")
+	}
+	if HunkFingerprint("a.go", before) == HunkFingerprint("b.go", before) {
+		t.Error("expected different files to give different fingerprints")
+	}
+	if HunkFingerprint("a.go", before) == HunkFingerprint("a.go", "@@ -10,3 +10,3 @@\n context\n-old\n+newer") {
+		t.Error("expected different changes to give different fingerprints")
+	}
+	if got := len(HunkFingerprint("a.go", before)); got != fingerprintLength {
+		t.Errorf("fingerprint length = %d, want %d", got, fingerprintLength)
+	}
+}
+
+func TestHunkFingerprint_HeaderOnlyIgnoresIndex(t *testing.T) {
+	a := "old mode 100644\nnew mode 100755\nindex 1111111..2222222"
+	b := "old mode 100644\nnew mode 100755\nindex 3333333..4444444"
+	if HunkFingerprint("run.sh", a) != HunkFingerprint("run.sh", b) {
+		t.Error("expected index lines to be ignored")
+	}
+}
+
+func TestHunkFingerprint_Combined(t *testing.T) {
+	a := "@@@ -1,2 -1,2 +1,2 @@@\n  context\n- ours\n -theirs\n++resolved"
+	b := "@@@ -7,2 -9,2 +8,2 @@@\n  moved\n- ours\n -theirs\n++resolved"
+	if HunkFingerprint("a.go", a) != HunkFingerprint("a.go", b) {
+		t.Error("expected combined context lines to be ignored")
+	}
+}
+
+func TestContentFingerprint(t *testing.T) {
+	h := Hunk{File: "a.go", Diff: "@@ -1 +1 @@\n-a\n+b"}
+	if h.ContentFingerprint() != HunkFingerprint("a.go", h.Diff) {
+		t.Error("expected a computed fingerprint for hunks without one")
+	}
+	h.Fingerprint = "stored"
+	if h.ContentFingerprint() != "stored" {
+		t.Error("expected the stored fingerprint")
+	}
+}
+
+func TestCarryOver(t *testing.T) {
+	diffA := "@@ -1,2 +1,2 @@\n ctx\n-a\n+b"
+	diffB := "@@ -5 +5 @@\n-c\n+d"
+	diffC := "@@ -9 +9 @@\n-e\n+f"
+	previous := Review{
+		Chapters: []Chapter{{Sections: []Section{
+			{ID: "s1", Hunks: []Hunk{{ID: "a.go::1", File: "a.go", Diff: diffA, Reviewed: true}}},
+			{ID: "s2", Reviewed: true, Hunks: []Hunk{{ID: "a.go::5", File: "a.go", Diff: diffB}}},
+			{ID: "s3", Hunks: []Hunk{{ID: "a.go::9", File: "a.go", Diff: diffC}}},
+		}}},
+		Notes: []Note{
+			{HunkID: "a.go::1", File: "a.go", Line: 4, Text: "on +b"},
+			{HunkID: "a.go::5", File: "a.go", Text: "whole hunk"},
+			{HunkID: "gone.go::1", File: "gone.go", Text: "orphan"},
+		},
+	}
+
+	// Lines were added above every hunk, and they were classified differently
+	shiftedA := "@@ -11,3 +11,3 @@\n ctx\n more context\n-a\n+b"
+	next := Review{
+		Chapters: []Chapter{{Sections: []Section{
+			{ID: "n1", Hunks: []Hunk{
+				{ID: "a.go::11", File: "a.go", Diff: shiftedA},
+				{ID: "a.go::15", File: "a.go", Diff: "@@ -15 +15 @@\n-c\n+d"},
+			}},
+			{ID: "n2", Hunks: []Hunk{{ID: "a.go::19", File: "a.go", Diff: "@@ -19 +19 @@\n-e\n+f"}}},
+		}}},
+	}
+
+	carried := next.CarryOver(previous)
+
+	hunks := next.Chapters[0].Sections[0].Hunks
+	if !hunks[0].Reviewed || !hunks[1].Reviewed {
+		t.Errorf("expected reviewed hunks to stay reviewed, got %+v", hunks)
+	}
+	if next.Chapters[0].Sections[1].Hunks[0].Reviewed {
+		t.Error("expected an unreviewed hunk to stay unreviewed")
+	}
+	if carried != 4 {
+		t.Errorf("carried = %d, want 4", carried)
+	}
+
+	if len(next.Notes) != 3 {
+		t.Fatalf("expected all 3 notes to be kept, got %+v", next.Notes)
+	}
+	if n := next.Notes[0]; n.HunkID != "a.go::11" || n.Line != 5 {
+		t.Errorf("expected the line note to follow its text, got %+v", n)
+	}
+	if n := next.Notes[1]; n.HunkID != "a.go::15" || n.Line != 0 {
+		t.Errorf("expected the hunk note to follow its hunk, got %+v", n)
+	}
+	if n := next.Notes[2]; n.HunkID != "" || !n.Orphaned || n.Text != "orphan" {
+		t.Errorf("expected the note on a gone hunk to be kept, orphaned, got %+v", n)
+	}
+}
+
+func TestCarryOver_OrphanedNoteDoesNotAttachToNewHunkAtItsPosition(t *testing.T) {
+	oldDiff := "@@ -3 +3 @@\n-old\n+changed"
+	previous := Review{
+		Chapters: []Chapter{{Sections: []Section{{ID: "s", Hunks: []Hunk{{ID: "a.go::3", File: "a.go", Diff: oldDiff}}}}}},
+		Notes: []Note{
+			{HunkID: "a.go::3", File: "a.go", Line: 2, Text: "about old", Fingerprint: HunkFingerprint("a.go", oldDiff)},
+		},
+	}
+	// An unrelated change now starts at the same line
+	next := Review{
+		Chapters: []Chapter{{Sections: []Section{{ID: "s", Hunks: []Hunk{{ID: "a.go::3", File: "a.go", Diff: "@@ -3 +3 @@\n-x\n+unrelated"}}}}}},
+	}
+
+	if carried := next.CarryOver(previous); carried != 0 {
+		t.Errorf("carried = %d, want 0", carried)
+	}
+	if n := next.Notes[0]; n.HunkID != "" || n.Line != 0 || !n.Orphaned {
+		t.Fatalf("expected the note to be orphaned, got %+v", n)
+	}
+
+	// Orphaned notes stay orphaned through later regenerations
+	again := Review{Chapters: next.Chapters}
+	again.CarryOver(next)
+	if n := again.Notes[0]; n.HunkID != "" || !n.Orphaned {
+		t.Errorf("expected the note to stay orphaned, got %+v", n)
+	}
+}
+
+func TestCarryOver_NotesFollowFingerprint(t *testing.T) {
+	// Two hunks with the same identity: the note belongs to the one its
+	// fingerprint names
+	noted := "@@ -3 +3 @@\n-a\n+b"
+	previous := Review{
+		Chapters: []Chapter{{Sections: []Section{{ID: "s", Hunks: []Hunk{{ID: "a.go::3", File: "a.go", Diff: noted}}}}}},
+		Notes:    []Note{{HunkID: "a.go::3", File: "a.go", Text: "on b", Fingerprint: HunkFingerprint("a.go", noted)}},
+	}
+	next := Review{
+		Chapters: []Chapter{{Sections: []Section{{ID: "s", Hunks: []Hunk{
+			{ID: "a.go::3", File: "a.go", Diff: "@@ -3 +3 @@\n-x\n+y"},
+			{ID: "a.go::20", File: "a.go", Diff: "@@ -20 +20 @@\n-a\n+b"},
+		}}}}},
+	}
+
+	next.CarryOver(previous)
+	if n := next.Notes[0]; n.HunkID != "a.go::20" || n.Orphaned {
+		t.Errorf("expected the note to follow its hunk's content, got %+v", n)
+	}
+}
+
+func TestCarryOver_LegacyDuplicateHunksStayApart(t *testing.T) {
+	// A review written before fingerprints were recorded, with the same
+	// change twice in one file, listed out of diff order
+	same := "@@ -10 +10 @@\n-a\n+b"
+	previous := Review{
+		Chapters: []Chapter{{Sections: []Section{
+			{ID: "s1", Hunks: []Hunk{{ID: "a.go::30", File: "a.go", StartLine: 30, Diff: same, Reviewed: true}}},
+			{ID: "s2", Hunks: []Hunk{{ID: "a.go::10", File: "a.go", StartLine: 10, Diff: same}}},
+		}}},
+		Notes: []Note{{HunkID: "a.go::30", File: "a.go", Text: "second"}},
+	}
+	fp := HunkFingerprint("a.go", same)
+	next := Review{
+		Chapters: []Chapter{{Sections: []Section{{ID: "s", Hunks: []Hunk{
+			{ID: "a.go::12", File: "a.go", StartLine: 12, Diff: same, Fingerprint: fp},
+			{ID: "a.go::32", File: "a.go", StartLine: 32, Diff: same, Fingerprint: fp + "#2"},
+		}}}}},
+	}
+
+	next.CarryOver(previous)
+	hunks := next.Chapters[0].Sections[0].Hunks
+	if hunks[0].Reviewed || !hunks[1].Reviewed {
+		t.Errorf("expected only the second hunk reviewed, got %v and %v", hunks[0].Reviewed, hunks[1].Reviewed)
+	}
+	if n := next.Notes[0]; n.HunkID != "a.go::32" || n.Fingerprint != fp+"#2" {
+		t.Errorf("expected the note on the second hunk, got %+v", n)
+	}
+	if previous.Chapters[0].Sections[0].Hunks[0].Fingerprint != "" {
+		t.Error("expected the previous review's hunks to be left as they were")
+	}
+}
+
+func TestFillFingerprints_MatchesTheParser(t *testing.T) {
+	same := "@@ -1 +1 @@\n-a\n+b"
+	r := Review{Chapters: []Chapter{{Sections: []Section{{Hunks: []Hunk{
+		{File: "a.go", StartLine: 20, Diff: same},
+		{File: "a.go", StartLine: 5, Diff: same},
+		{File: "a.go", StartLine: 9, Diff: "@@ -9 +9 @@\n-c\n+d", Fingerprint: "stored"},
+	}}}}}}
+
+	r.FillFingerprints()
+	hunks := r.Chapters[0].Sections[0].Hunks
+	fp := HunkFingerprint("a.go", same)
+	if hunks[1].Fingerprint != fp || hunks[0].Fingerprint != fp+"#2" || hunks[2].Fingerprint != "stored" {
+		t.Errorf("expected identical hunks numbered by start line, got %q, %q, %q", hunks[0].Fingerprint, hunks[1].Fingerprint, hunks[2].Fingerprint)
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
@@ -11,6 +12,15 @@ const (
 	ImportanceLow    = "low"
 )
 
+// Kinds of file change recorded on hunks. Ordinary modifications have no kind.
+const (
+	ChangeAdded       = "added"
+	ChangeDeleted     = "deleted"
+	ChangeRenamed     = "renamed"
+	ChangeCopied      = "copied"
+	ChangeModeChanged = "mode-changed"
+)
+
 func ValidImportance(s string) bool {
 	switch s {
 	case ImportanceHigh, ImportanceMedium, ImportanceLow:
@@ -34,10 +44,60 @@ func NormalizeImportance(s string) string {
 }
 
 type Review struct {
-	WorkingDirectory string    `json:"workingDirectory"`
-	Title            string    `json:"title"`
-	Chapters         []Chapter `json:"chapters"`
-	CreatedAt        time.Time `json:"createdAt,omitempty"`
+	SchemaVersion    int         `json:"schemaVersion,omitempty"` // Format version; see CurrentSchemaVersion
+	WorkingDirectory string      `json:"workingDirectory" desc:"Absolute path of the repository the review describes"`
+	Title            string      `json:"title"`
+	Chapters         []Chapter   `json:"chapters" desc:"Chapters in reading order"`
+	CreatedAt        time.Time   `json:"createdAt,omitempty"`
+	DiffSource       string      `json:"diffSource,omitempty"` // Label of the diff source that produced the review
+	Branch           string      `json:"branch,omitempty"`     // Branch checked out when the review was generated
+	Provenance       *Provenance `json:"provenance,omitempty"`
+	Generation       *Generation `json:"generation,omitempty"` // How diffstory generated the review, if it did
+	Notes            []Note      `json:"notes,omitempty"`      // Reader annotations on hunks and lines
+}
+
+// Note is a reader's annotation on a hunk, or on one line of a hunk's diff
+type Note struct {
+	HunkID      string    `json:"hunkId" desc:"id of the annotated hunk, or file::startLine for hunks without one"`
+	File        string    `json:"file" desc:"File of the annotated hunk, for readers of the JSON"`
+	Line        int       `json:"line,omitempty" desc:"1-based line within the hunk diff; omitted for notes on the whole hunk"`
+	Text        string    `json:"text"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Fingerprint string    `json:"fingerprint,omitempty" desc:"Content fingerprint of the annotated hunk, which notes follow when a review is regenerated"`
+	Orphaned    bool      `json:"orphaned,omitempty" desc:"true when the annotated hunk is no longer in the diff; hunkId is then empty"`
+}
+
+// Provenance records what a review was generated from, so a viewer can tell
+// when the working tree or refs have moved on since.
+type Provenance struct {
+	DiffCommand []string `json:"diffCommand"`
+	BaseSHA     string   `json:"baseSha,omitempty"` // Commit the diff is taken against, if any
+	HeadSHA     string   `json:"headSha,omitempty"` // HEAD when the review was generated
+	DiffHash    string   `json:"diffHash"`          // SHA-256 of the diff command output
+}
+
+// Generation records how a review was generated, so a story that looks wrong
+// can be traced back to what the LLM was asked and what it answered
+type Generation struct {
+	LLM            string    `json:"llm"` // Command line or API model that classified the hunks
+	StartedAt      time.Time `json:"startedAt"`
+	DurationMs     int64     `json:"durationMs"`
+	HunkCount      int       `json:"hunkCount"`                // Hunks in the diff, after exclusions
+	Batches        int       `json:"batches,omitempty"`        // Classification requests the hunks were split across
+	Requests       int       `json:"requests"`                 // LLM requests made, including merges and repairs
+	RepairAttempts int       `json:"repairAttempts,omitempty"` // Rounds of placing hunks a classification left out
+	JSONRepaired   bool      `json:"jsonRepaired,omitempty"`   // Some LLM output was malformed JSON that had to be repaired
+	Cached         bool      `json:"cached,omitempty"`         // The classification came from the LLM cache
+	Incremental    bool      `json:"incremental,omitempty"`    // Only hunks new since the previous review were classified
+	Partial        bool      `json:"partial,omitempty"`        // Saved with hunks the LLM left out in an Unclassified chapter
+	TwoPass        bool      `json:"twoPass,omitempty"`        // The classification was an outline, narrated section by section
+	Narrated       int       `json:"narrated,omitempty"`       // Sections whose what and why were written in a request of their own
+	Transcript     string    `json:"transcript,omitempty"`     // ID of the prompts and responses archived in the store
+}
+
+// Duration returns how long generation took
+func (g Generation) Duration() time.Duration {
+	return time.Duration(g.DurationMs) * time.Millisecond
 }
 
 // AllSections returns a flattened list of all sections across all chapters.
@@ -58,8 +118,21 @@ func (r Review) SectionCount() int {
 	return count
 }
 
+// ReviewedSectionCount returns the number of sections marked as reviewed.
+func (r Review) ReviewedSectionCount() int {
+	count := 0
+	for _, ch := range r.Chapters {
+		for _, s := range ch.Sections {
+			if s.IsReviewed() {
+				count++
+			}
+		}
+	}
+	return count
+}
+
 // NewReviewWithSections creates a Review with a single default chapter containing the given sections.
-// This is a convenience function primarily for testing and migration purposes.
+// This is a convenience function primarily for testing; ParseReview migrates flat files the same way.
 func NewReviewWithSections(workDir, title string, sections []Section) Review {
 	return Review{
 		WorkingDirectory: workDir,
@@ -81,17 +154,59 @@ type Chapter struct {
 }
 
 type Section struct {
-	ID    string `json:"id"`
-	Title string `json:"title"`
-	What  string `json:"what"`
-	Why   string `json:"why"`
-	Hunks []Hunk `json:"hunks"`
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	What     string `json:"what" desc:"What changed, in one sentence"`
+	Why      string `json:"why" desc:"Why it changed, in one sentence"`
+	Hunks    []Hunk `json:"hunks"`
+	Reviewed bool   `json:"reviewed,omitempty"` // Marked as reviewed by the reader
+}
+
+// IsReviewed reports whether the section was marked as reviewed, either
+// directly or by marking every one of its hunks.
+func (s Section) IsReviewed() bool {
+	if s.Reviewed {
+		return true
+	}
+	if len(s.Hunks) == 0 {
+		return false
+	}
+	for _, h := range s.Hunks {
+		if !h.Reviewed {
+			return false
+		}
+	}
+	return true
 }
 
 type Hunk struct {
-	File       string `json:"file"`
-	StartLine  int    `json:"startLine"`
-	Diff       string `json:"diff"`
-	Importance string `json:"importance"`
-	IsTest     *bool  `json:"isTest,omitempty"`
+	ID          string `json:"id,omitempty"` // file::startLine identifier assigned when the diff was parsed
+	Fingerprint string `json:"fingerprint,omitempty" desc:"Hash of the file and changed lines, which stays the same when the hunk moves"`
+	File        string `json:"file"`
+	OldFile     string `json:"oldFile,omitempty" desc:"Path before a rename or copy"`
+	ChangeKind  string `json:"changeKind,omitempty" desc:"added, deleted, renamed, copied or mode-changed; omitted for modifications"`
+	StartLine   int    `json:"startLine" desc:"First line of the hunk in the new file"`
+	Diff        string `json:"diff" desc:"Complete unified diff hunk, starting with its @@ header; for files changed without content hunks, the extended git header lines (rename from/to, new mode, ...)"`
+	Importance  string `json:"importance" enum:"high,medium,low"`
+	IsTest      *bool  `json:"isTest,omitempty" desc:"Whether the hunk is test code"`
+	Reviewed    bool   `json:"reviewed,omitempty"` // Marked as reviewed by the reader
+	Binary      bool   `json:"binary,omitempty" desc:"The file is binary; diff only says that it differs"`
+	OldSize     int64  `json:"oldSize,omitempty" desc:"Size in bytes of a binary file before the change, when known"`
+	NewSize     int64  `json:"newSize,omitempty" desc:"Size in bytes of a binary file after the change, when known"`
+}
+
+// IsFileHeader reports whether the hunk stands for a file change without
+// content hunks, such as a pure rename, mode change or binary change, so its
+// Diff holds extended git header lines rather than an @@ hunk.
+func (h Hunk) IsFileHeader() bool {
+	return h.Binary || (h.ChangeKind != "" && !strings.HasPrefix(h.Diff, "@@"))
+}
+
+// Identity returns the hunk's ID, falling back to its file and start line
+// for reviews written without hunk IDs.
+func (h Hunk) Identity() string {
+	if h.ID != "" {
+		return h.ID
+	}
+	return fmt.Sprintf("%s::%d", h.File, h.StartLine)
 }
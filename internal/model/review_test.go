@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -272,3 +273,72 @@ func TestSection_WhatWhy_Serialization(t *testing.T) {
 		t.Errorf("Why = %q, want %q", unmarshaled.Why, "Prevents brute-force attacks by limiting failed attempts")
 	}
 }
+
+func TestSection_IsReviewed(t *testing.T) {
+	tests := []struct {
+		name    string
+		section Section
+		want    bool
+	}{
+		{"unmarked", Section{Hunks: []Hunk{{}, {}}}, false},
+		{"marked directly", Section{Reviewed: true, Hunks: []Hunk{{}}}, true},
+		{"some hunks reviewed", Section{Hunks: []Hunk{{Reviewed: true}, {}}}, false},
+		{"all hunks reviewed", Section{Hunks: []Hunk{{Reviewed: true}, {Reviewed: true}}}, true},
+		{"no hunks", Section{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.section.IsReviewed(); got != tt.want {
+				t.Errorf("IsReviewed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReview_ReviewedSectionCount(t *testing.T) {
+	review := Review{Chapters: []Chapter{
+		{Sections: []Section{{Reviewed: true}, {}}},
+		{Sections: []Section{{Hunks: []Hunk{{Reviewed: true}}}}},
+	}}
+
+	if got := review.ReviewedSectionCount(); got != 2 {
+		t.Errorf("ReviewedSectionCount() = %d, want 2", got)
+	}
+}
+
+func TestSection_Reviewed_OmittedWhenFalse(t *testing.T) {
+	data, err := json.Marshal(Section{ID: "1", Hunks: []Hunk{{File: "a.go"}}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "reviewed") {
+		t.Errorf("unreviewed section should not serialize reviewed fields: %s", data)
+	}
+}
+
+func TestHunk_Identity(t *testing.T) {
+	if got := (Hunk{ID: "main.go::10#2", File: "main.go", StartLine: 10}).Identity(); got != "main.go::10#2" {
+		t.Errorf("expected stored ID, got %q", got)
+	}
+	if got := (Hunk{File: "main.go", StartLine: 10}).Identity(); got != "main.go::10" {
+		t.Errorf("expected file and start line fallback, got %q", got)
+	}
+}
+
+func TestHunk_IsFileHeader(t *testing.T) {
+	tests := []struct {
+		hunk Hunk
+		want bool
+	}{
+		{Hunk{Diff: "@@ -1 +1 @@\n-a\n+b"}, false},
+		{Hunk{ChangeKind: ChangeRenamed, Diff: "@@ -1 +1 @@\n-a\n+b"}, false},
+		{Hunk{ChangeKind: ChangeRenamed, Diff: "rename from a.go\nrename to b.go"}, true},
+		{Hunk{ChangeKind: ChangeModeChanged, Diff: "old mode 100644\nnew mode 100755"}, true},
+		{Hunk{Binary: true, Diff: "Binary files a/x.png and b/x.png differ"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.hunk.IsFileHeader(); got != tt.want {
+			t.Errorf("IsFileHeader(%q) = %v, want %v", tt.hunk.Diff, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Schema versions of the review JSON format. Files written before versioning
+// carry no schemaVersion and are identified by their shape.
+const (
+	// SchemaVersionFlat is the original format, with sections at the top level
+	SchemaVersionFlat = 1
+	// SchemaVersionChapters groups sections into chapters
+	SchemaVersionChapters = 2
+	// SchemaVersionReaderState adds reader notes and reviewed marks,
+	// provenance, generation records, hunk fingerprints and binary files
+	SchemaVersionReaderState = 3
+
+	// CurrentSchemaVersion is the version written by this build
+	CurrentSchemaVersion = SchemaVersionReaderState
+)
+
+// ErrSchemaTooNew is returned when a review was written by a newer version of
+// diffstory than this one
+var ErrSchemaTooNew = errors.New("review was written by a newer version of diffstory")
+
+// migrations upgrade a decoded review document from the version it is keyed
+// by to the next one
+var migrations = map[int]func(doc map[string]json.RawMessage) error{
+	SchemaVersionFlat:     migrateFlatToChapters,
+	SchemaVersionChapters: migrateAddedFields,
+}
+
+// ParseReview decodes a review file of any supported schema version,
+// upgrading it to the current format
+func ParseReview(data []byte) (*Review, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("review is null")
+	}
+
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("%w: schema version %d, this version understands up to %d; upgrade diffstory to read it",
+			ErrSchemaTooNew, version, CurrentSchemaVersion)
+	}
+
+	for ; version < CurrentSchemaVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("unsupported schema version %d", version)
+		}
+		if err := migrate(doc); err != nil {
+			return nil, fmt.Errorf("migrating from schema version %d: %w", version, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var review Review
+	if err := json.Unmarshal(upgraded, &review); err != nil {
+		return nil, err
+	}
+	review.SchemaVersion = CurrentSchemaVersion
+	review.FillFingerprints()
+	return &review, nil
+}
+
+// schemaVersion returns a document's declared schema version, or infers it
+// from its shape for files written before versioning
+func schemaVersion(doc map[string]json.RawMessage) (int, error) {
+	if raw, ok := doc["schemaVersion"]; ok {
+		var version int
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return 0, fmt.Errorf("invalid schemaVersion: %w", err)
+		}
+		if version < SchemaVersionFlat {
+			return 0, fmt.Errorf("invalid schemaVersion %d", version)
+		}
+		return version, nil
+	}
+	if _, hasChapters := doc["chapters"]; !hasChapters {
+		if _, hasSections := doc["sections"]; hasSections {
+			return SchemaVersionFlat, nil
+		}
+	}
+	return SchemaVersionChapters, nil
+}
+
+// migrateFlatToChapters moves top-level sections into a single default
+// chapter, as NewReviewWithSections does
+func migrateFlatToChapters(doc map[string]json.RawMessage) error {
+	var sections []json.RawMessage
+	if raw, ok := doc["sections"]; ok {
+		if err := json.Unmarshal(raw, &sections); err != nil {
+			return fmt.Errorf("sections: %w", err)
+		}
+	}
+	if sections == nil {
+		sections = []json.RawMessage{}
+	}
+	chapters, err := json.Marshal([]map[string]any{{
+		"id":       "default",
+		"title":    "Changes",
+		"sections": sections,
+	}})
+	if err != nil {
+		return err
+	}
+	delete(doc, "sections")
+	doc["chapters"] = chapters
+	return nil
+}
+
+// migrateAddedFields upgrades a version 2 document, which needs no changes:
+// version 3 only adds optional fields. The version exists so that older
+// builds refuse files whose notes and marks they would drop on saving.
+func migrateAddedFields(doc map[string]json.RawMessage) error {
+	return nil
+}
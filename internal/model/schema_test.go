@@ -0,0 +1,132 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseReview_CurrentVersion(t *testing.T) {
+	data := `{
+		"schemaVersion": 3,
+		"workingDirectory": "/test",
+		"title": "Test",
+		"chapters": [{"id": "ch-1", "title": "Auth", "sections": [{"id": "s1", "title": "Login", "hunks": []}]}]
+	}`
+
+	review, err := ParseReview([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if review.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", review.SchemaVersion, CurrentSchemaVersion)
+	}
+	if len(review.Chapters) != 1 || review.Chapters[0].ID != "ch-1" {
+		t.Errorf("unexpected chapters: %+v", review.Chapters)
+	}
+}
+
+func TestParseReview_UpgradesChapters(t *testing.T) {
+	data := `{"schemaVersion": 2, "workingDirectory": "/test", "title": "Test", "chapters": [{"id": "ch-1", "title": "Auth", "sections": [{"id": "s1", "title": "Login", "hunks": []}]}]}`
+
+	review, err := ParseReview([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if review.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", review.SchemaVersion, CurrentSchemaVersion)
+	}
+	if len(review.Chapters) != 1 || review.Chapters[0].Sections[0].ID != "s1" || len(review.Notes) != 0 {
+		t.Errorf("unexpected review: %+v", review)
+	}
+}
+
+func TestParseReview_UnversionedChapters(t *testing.T) {
+	data := `{"workingDirectory": "/test", "title": "Test", "chapters": [{"id": "ch-1", "title": "Auth", "sections": []}]}`
+
+	review, err := ParseReview([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if review.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", review.SchemaVersion, CurrentSchemaVersion)
+	}
+	if len(review.Chapters) != 1 || review.Chapters[0].Title != "Auth" {
+		t.Errorf("unexpected chapters: %+v", review.Chapters)
+	}
+}
+
+func TestParseReview_MigratesFlatSections(t *testing.T) {
+	data := `{
+		"workingDirectory": "/test",
+		"title": "Old Review",
+		"sections": [
+			{"id": "1", "title": "First", "what": "Does a thing", "hunks": [{"file": "a.go", "startLine": 1, "diff": "+a", "importance": "high"}]},
+			{"id": "2", "title": "Second", "hunks": []}
+		]
+	}`
+
+	review, err := ParseReview([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := NewReviewWithSections("/test", "Old Review", nil)
+	if len(review.Chapters) != 1 {
+		t.Fatalf("expected 1 chapter, got %d", len(review.Chapters))
+	}
+	chapter := review.Chapters[0]
+	if chapter.ID != want.Chapters[0].ID || chapter.Title != want.Chapters[0].Title {
+		t.Errorf("chapter = %q/%q, want %q/%q", chapter.ID, chapter.Title, want.Chapters[0].ID, want.Chapters[0].Title)
+	}
+	if len(chapter.Sections) != 2 {
+		t.Fatalf("expected 2 sections, got %d", len(chapter.Sections))
+	}
+	if chapter.Sections[0].What != "Does a thing" || chapter.Sections[0].Hunks[0].File != "a.go" {
+		t.Errorf("section not carried over: %+v", chapter.Sections[0])
+	}
+}
+
+func TestParseReview_VersionedFlatSections(t *testing.T) {
+	data := `{"schemaVersion": 1, "title": "Old", "sections": [{"id": "1", "title": "First", "hunks": []}]}`
+
+	review, err := ParseReview([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if review.SectionCount() != 1 {
+		t.Errorf("expected 1 section, got %d", review.SectionCount())
+	}
+}
+
+func TestParseReview_NewerVersion(t *testing.T) {
+	data := `{"schemaVersion": 99, "title": "Future", "chapters": []}`
+
+	_, err := ParseReview([]byte(data))
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "99") || !strings.Contains(err.Error(), "upgrade diffstory") {
+		t.Errorf("error should name the version and suggest upgrading: %v", err)
+	}
+}
+
+func TestParseReview_InvalidVersion(t *testing.T) {
+	tests := []string{
+		`{"schemaVersion": "two"}`,
+		`{"schemaVersion": 0}`,
+	}
+	for _, data := range tests {
+		if _, err := ParseReview([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestParseReview_InvalidJSON(t *testing.T) {
+	for _, data := range []string{`{invalid`, `null`, `[]`} {
+		if _, err := ParseReview([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
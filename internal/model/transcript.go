@@ -0,0 +1,18 @@
+package model
+
+// Transcript is what was sent to the LLM during one generation and what it
+// replied, archived beside the review so a story can be traced back to it
+type Transcript struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange is one request to the LLM and its raw reply
+type Exchange struct {
+	Purpose      string `json:"purpose"` // e.g. "classification, batch 2 of 3" or "merge"
+	Prompt       string `json:"prompt"`
+	Input        string `json:"input,omitempty"` // Hunks JSON the LLM read from a file named in the prompt
+	Response     string `json:"response,omitempty"`
+	Error        string `json:"error,omitempty"` // Why the request failed, if it did
+	DurationMs   int64  `json:"durationMs"`
+	JSONRepaired bool   `json:"jsonRepaired,omitempty"` // The response was malformed JSON that had to be repaired
+}
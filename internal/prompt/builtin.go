@@ -0,0 +1,99 @@
+package prompt
+
+// builtinClassification is the classification prompt used unless a
+// classification.tmpl overrides it
+const builtinClassification = `You are a code review assistant. Classify diff hunks into logical chapters and sections.
+
+IMPORTANT: You MUST classify ALL hunks. Every hunk ID must appear exactly once in your response.
+
+{{.Input}}
+
+The input is a JSON array of hunk objects with fields: id, file, startLine, diff.
+Files that were added, deleted, renamed, copied or had their mode changed also have changeKind,
+and renamed or copied files have oldFile. A file changed without any content changes (such as a
+pure rename) appears as a single hunk whose diff holds git's header lines instead of an @@ hunk.
+Binary files appear the same way with binary set to true, plus oldSize and newSize in bytes when
+known. Classify these like any other hunk, placing them with the changes they belong to.
+
+Hunks with mergeResolution set to true come from a merge commit's combined diff: their @@@ header
+has one range per parent, and each line has one +/-/space column per parent. They show how the
+merge resolved conflicts or changed code beyond what either parent had, which is what a reviewer of
+a merge most needs to see. Put them in their own chapter titled "Merge Resolution", and explain in
+each section's why how the two sides were reconciled.
+
+Respond with JSON in this exact format (no markdown fences, no explanation text):
+{
+  "title": "Brief title for this review",
+  "chapters": [
+    {
+      "id": "chapter-identifier",
+      "title": "Short chapter title",
+      "sections": [
+        {
+          "id": "section-identifier",
+          "title": "Short section title",
+          "what": "Brief description of WHAT changed in this section",
+          "why": "Brief explanation of WHY this change was made",
+          "hunks": [
+            {"id": "file/path.go::45", "importance": "high", "isTest": false},
+            {"id": "file/path_test.go::120", "importance": "medium", "isTest": true}
+          ]
+        }
+      ]
+    }
+  ]
+}
+
+## Grouping Philosophy
+
+Group hunks by FUNCTIONAL PURPOSE, not by file path. Hunks that work together to achieve a goal belong in the same section, even if they span multiple files.
+
+DO NOT:
+- Group by file path. "Changes to auth.go" is never a good chapter title.
+- Separate documentation into its own chapter. Docs belong with their related code.
+
+## Format Requirements
+
+- Each chapter contains one or more sections.
+- Chapter title: ~20-30 characters, describes the theme (e.g., "Authentication", "Database Schema").
+- Section title: ~30-40 characters, describes the specific change (e.g., "Add login endpoint handler").
+- what: 1 sentence describing the change (e.g., "Added rate limiting to login endpoint")
+- why: 1 sentence explaining the reasoning (e.g., "Prevents brute-force attacks by limiting failed attempts")
+- Each hunk must have importance: "high", "medium", or "low"
+  - high: Critical changes (security, core logic, breaking changes)
+  - medium: Important changes (new features, significant refactors)
+  - low: Minor changes (formatting, comments, trivial fixes)
+- Each hunk must have isTest: true if the hunk is test code, false if production code
+  - Test code includes: unit tests, integration tests, test fixtures, test utilities, mocks
+
+Example (good):
+  "what": "Added rate limiting to login endpoint"
+  "why": "Prevents brute-force attacks by limiting failed attempts to 5 per minute per IP"
+
+Example (bad):
+  "what": "Changed auth.go"  <- Too vague, doesn't describe the actual change
+  "why": "Needed to update it" <- Doesn't explain the reasoning
+
+## Response Schema
+
+Your response must validate against this JSON Schema:
+{{.Schema}}
+{{if .Context}}
+User context: {{.Context}}{{end}}`
+
+// builtinRetry is appended to the classification prompt when asking the LLM
+// to place the hunks a response left out, unless a retry.tmpl overrides it
+const builtinRetry = `
+
+## Placing Missing Hunks
+
+A previous response classified the rest of this diff but left out the input hunks:
+{{join .MissingIDs ", "}}
+
+The story so far has these chapters and sections:
+{{.Outline}}
+
+Place each input hunk in the existing section it belongs to by giving that section's id inside
+its chapter's id, repeating their titles, what and why unchanged. When a hunk fits no existing
+section, create a new section (in an existing or new chapter) with a new id. You MUST list every
+input hunk, and only the input hunks.`
@@ -0,0 +1,146 @@
+// Package prompt renders the prompts sent to the LLM from text/template
+// templates. Built-in templates are used unless a prompts directory holds a
+// replacement:
+//
+//	classification.tmpl  the classification prompt (ClassificationData)
+//	retry.tmpl           appended when asking the LLM to place hunks a response left out (RetryData)
+//
+// Templates may call join, as in {{join .MissingIDs ", "}}.
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Template names, which are also their file names without the .tmpl extension
+const (
+	Classification = "classification"
+	Retry          = "retry"
+)
+
+// Builtin is the source recorded for templates that were not overridden
+const Builtin = "built-in"
+
+// ClassificationData is what the classification template can refer to
+type ClassificationData struct {
+	Input      string // Tells the LLM where the input hunks are: a file path, or the hunks themselves
+	InputFile  string // Path of the file holding the hunks, or "" when they are in Input
+	Schema     string // JSON Schema the response must validate against
+	Context    string // Guidance the user entered for this generation, if any
+	DiffSource string // Label of the diff source being reviewed, such as "Uncommitted changes"
+}
+
+// RetryData is what the retry template can refer to
+type RetryData struct {
+	MissingIDs []string // IDs of the hunks the previous response left out
+	Outline    string   // JSON of the story's chapters and sections so far, without hunks
+	DiffSource string   // Label of the diff source being reviewed
+}
+
+// inputMarker stands in for the input hunks when checking that a
+// classification template includes them
+const inputMarker = "\x00input\x00"
+
+// sampleData is executed against each loaded template to catch references to
+// fields that do not exist before a generation needs the template
+var sampleData = map[string]any{
+	Classification: ClassificationData{Input: inputMarker, InputFile: "hunks.json", Schema: "{}", Context: "context", DiffSource: "source"},
+	Retry:          RetryData{MissingIDs: []string{"a.go::1"}, Outline: "[]", DiffSource: "source"},
+}
+
+var builtins = map[string]string{
+	Classification: builtinClassification,
+	Retry:          builtinRetry,
+}
+
+var funcs = template.FuncMap{"join": strings.Join}
+
+// Templates holds the prompt templates in use
+type Templates struct {
+	templates map[string]*template.Template
+	sources   map[string]string
+}
+
+// Default returns the built-in templates
+func Default() *Templates {
+	t := &Templates{templates: make(map[string]*template.Template), sources: make(map[string]string)}
+	for name, text := range builtins {
+		t.templates[name] = template.Must(template.New(name).Funcs(funcs).Parse(text))
+		t.sources[name] = Builtin
+	}
+	return t
+}
+
+// Load reads template overrides from dirs, which are searched in order so an
+// earlier directory's template wins, and validates them by rendering sample
+// data. Directories that do not exist are skipped. Templates without an
+// override keep the built-in text.
+func Load(dirs ...string) (*Templates, error) {
+	t := Default()
+	for name := range builtins {
+		for _, dir := range dirs {
+			path := filepath.Join(dir, name+".tmpl")
+			data, err := os.ReadFile(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			tmpl, err := parse(name, string(data))
+			if err != nil {
+				return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+			}
+			t.templates[name] = tmpl
+			t.sources[name] = path
+			break
+		}
+	}
+	return t, nil
+}
+
+// parse parses and validates one template
+func parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, sampleData[name]); err != nil {
+		return nil, err
+	}
+	if name == Classification && !strings.Contains(buf.String(), inputMarker) {
+		return nil, fmt.Errorf("template must include {{.Input}}, which gives the LLM the hunks to classify")
+	}
+	return tmpl, nil
+}
+
+// Source returns the file the named template was loaded from, or Builtin
+func (t *Templates) Source(name string) string {
+	return t.sources[name]
+}
+
+// Classification renders the classification prompt
+func (t *Templates) Classification(data ClassificationData) (string, error) {
+	return t.render(Classification, data)
+}
+
+// Retry renders the addendum asking the LLM to place missing hunks
+func (t *Templates) Retry(data RetryData) (string, error) {
+	return t.render(Retry, data)
+}
+
+func (t *Templates) render(name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.templates[name].Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s prompt: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,132 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".tmpl"), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefault_RendersBuiltinClassification(t *testing.T) {
+	templates := Default()
+
+	withoutContext, err := templates.Classification(ClassificationData{Input: "Read the input hunks from this JSON file: /tmp/x/hunks.json", Schema: `{"type":"object"}`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(withoutContext, "You are a code review assistant.") {
+		t.Errorf("expected the built-in prompt, got %q", withoutContext[:50])
+	}
+	if !strings.Contains(withoutContext, "\nRead the input hunks from this JSON file: /tmp/x/hunks.json\n") {
+		t.Error("expected the input in the prompt")
+	}
+	if !strings.HasSuffix(withoutContext, "JSON Schema:\n{\"type\":\"object\"}\n") {
+		t.Errorf("expected the prompt to end with the schema, got %q", withoutContext[len(withoutContext)-60:])
+	}
+
+	withContext, err := templates.Classification(ClassificationData{Input: "in", Schema: "{}", Context: "Focus on the API"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(withContext, "{}\n\nUser context: Focus on the API") {
+		t.Errorf("expected the user context after the schema, got %q", withContext[len(withContext)-40:])
+	}
+}
+
+func TestDefault_RendersBuiltinRetry(t *testing.T) {
+	retry, err := Default().Retry(RetryData{MissingIDs: []string{"a.go::1", "b.go::9"}, Outline: `[{"id":"auth"}]`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(retry, "left out the input hunks:\na.go::1, b.go::9\n") {
+		t.Errorf("expected the missing IDs joined, got %q", retry)
+	}
+	if !strings.Contains(retry, "chapters and sections:\n[{\"id\":\"auth\"}]\n") {
+		t.Errorf("expected the outline, got %q", retry)
+	}
+	if Default().Source(Retry) != Builtin {
+		t.Errorf("expected the built-in source, got %q", Default().Source(Retry))
+	}
+}
+
+func TestLoad_EarlierDirectoryWins(t *testing.T) {
+	user := filepath.Join(t.TempDir(), "prompts")
+	repo := filepath.Join(t.TempDir(), "prompts")
+	writeTemplate(t, user, Classification, "User prompt for {{.DiffSource}}: {{.Input}}")
+	writeTemplate(t, repo, Classification, "Repo prompt: {{.Input}}")
+	writeTemplate(t, repo, Retry, "Missing {{len .MissingIDs}}")
+
+	templates, err := Load(user, filepath.Join(t.TempDir(), "missing"), repo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	classification, err := templates.Classification(ClassificationData{Input: "hunks", DiffSource: "Staged changes"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if classification != "User prompt for Staged changes: hunks" {
+		t.Errorf("expected the user template, got %q", classification)
+	}
+	retry, err := templates.Retry(RetryData{MissingIDs: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retry != "Missing 2" {
+		t.Errorf("expected the repo's retry template, got %q", retry)
+	}
+	if got := templates.Source(Classification); got != filepath.Join(user, "classification.tmpl") {
+		t.Errorf("expected the classification source to be the user file, got %q", got)
+	}
+}
+
+func TestLoad_WithoutOverridesUsesBuiltins(t *testing.T) {
+	templates, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if templates.Source(Classification) != Builtin || templates.Source(Retry) != Builtin {
+		t.Errorf("expected built-in templates, got %q and %q", templates.Source(Classification), templates.Source(Retry))
+	}
+}
+
+func TestLoad_RejectsInvalidTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		text     string
+		expected string
+	}{
+		{"syntax error", Classification, "{{.Input", "unclosed action"},
+		{"unknown field", Classification, "{{.Input}} {{.Hunks}}", "can't evaluate field Hunks"},
+		{"unknown function", Retry, "{{upper .MissingIDs}}", `function "upper" not defined`},
+		{"no input", Classification, "Classify the hunks in {{.InputFile}}", "{{.Input}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplate(t, dir, tt.template, tt.text)
+
+			_, err := Load(dir)
+
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("expected an error containing %q, got %v", tt.expected, err)
+			}
+			if !strings.Contains(err.Error(), filepath.Join(dir, tt.template+".tmpl")) {
+				t.Errorf("expected the error to name the file, got %v", err)
+			}
+		})
+	}
+}
@@ -59,6 +59,6 @@ func (s *Service) Submit(ctx context.Context, review model.Review) (SubmitResult
 		return SubmitResult{}, err
 	}
 
-	filePath, _ := s.store.PathForDirectory(normalized)
+	filePath, _ := s.store.PathForReview(normalized, review.Branch, review.DiffSource)
 	return SubmitResult{FilePath: filePath}, nil
 }
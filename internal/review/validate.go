@@ -0,0 +1,112 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// Problem is a single validation failure, located by a JSON path such as
+// "$.chapters[0].sections[1].hunks[2].importance"
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// ValidateOptions configures optional checks made by Validate
+type ValidateOptions struct {
+	// CurrentHunks, when non-nil, are the hunks of the diff the review should
+	// describe. Review hunks that are not among them are reported.
+	CurrentHunks []diff.ParsedHunk
+}
+
+// Validate checks a review for every structural problem it can find, rather
+// than stopping at the first one, so authors can fix a file in one pass
+func Validate(r model.Review, opts ValidateOptions) []Problem {
+	var problems []Problem
+	report := func(path, format string, args ...any) {
+		problems = append(problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if r.WorkingDirectory == "" {
+		report("$.workingDirectory", "workingDirectory is required")
+	}
+	if len(r.Chapters) == 0 {
+		report("$.chapters", "review has no chapters")
+	}
+
+	var current map[string]bool
+	if opts.CurrentHunks != nil {
+		current = make(map[string]bool, len(opts.CurrentHunks))
+		for _, h := range opts.CurrentHunks {
+			current[hunkKey(h.File, h.Diff)] = true
+		}
+	}
+
+	chapterIDs := make(map[string]string)
+	sectionIDs := make(map[string]string)
+	for ci, chapter := range r.Chapters {
+		chapterPath := fmt.Sprintf("$.chapters[%d]", ci)
+		checkID(chapter.ID, chapterPath, "chapter", chapterIDs, report)
+		if len(chapter.Sections) == 0 {
+			report(chapterPath+".sections", "chapter has no sections")
+		}
+
+		for si, section := range chapter.Sections {
+			sectionPath := fmt.Sprintf("%s.sections[%d]", chapterPath, si)
+			checkID(section.ID, sectionPath, "section", sectionIDs, report)
+			if len(section.Hunks) == 0 {
+				report(sectionPath+".hunks", "section has no hunks")
+			}
+
+			for hi, hunk := range section.Hunks {
+				hunkPath := fmt.Sprintf("%s.hunks[%d]", sectionPath, hi)
+				if hunk.File == "" {
+					report(hunkPath+".file", "file is required")
+				}
+				if hunk.Importance == "" {
+					report(hunkPath+".importance", "importance is required (high, medium or low)")
+				} else if !model.ValidImportance(hunk.Importance) {
+					report(hunkPath+".importance", "invalid importance %q (want high, medium or low)", hunk.Importance)
+				}
+				// File changes without content hunks, such as pure renames,
+				// carry git header lines instead of an @@ hunk
+				if !hunk.IsFileHeader() {
+					if err := diff.CheckHunk(hunk.Diff); err != nil {
+						report(hunkPath+".diff", "not a unified diff hunk: %v", err)
+					}
+				}
+				if current != nil && !current[hunkKey(hunk.File, hunk.Diff)] {
+					report(hunkPath, "hunk in %s is not in the current diff", hunk.File)
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkID reports a missing id, or one already used at another path
+func checkID(id, path, kind string, seen map[string]string, report func(path, format string, args ...any)) {
+	if id == "" {
+		report(path+".id", "%s id is required", kind)
+		return
+	}
+	if first, ok := seen[id]; ok {
+		report(path+".id", "duplicate %s id %q (first used at %s)", kind, id, first)
+		return
+	}
+	seen[id] = path
+}
+
+// hunkKey identifies a hunk by its file and content, ignoring trailing
+// newlines
+func hunkKey(file, hunkDiff string) string {
+	return file + "\x00" + strings.TrimRight(hunkDiff, "\n")
+}
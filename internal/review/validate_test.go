@@ -0,0 +1,137 @@
+package review_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/review"
+)
+
+const validHunkDiff = "@@ -1,2 +1,2 @@\n context\n-old\n+new"
+
+func validReview() model.Review {
+	return model.Review{
+		WorkingDirectory: "/test/project",
+		Title:            "Test",
+		Chapters: []model.Chapter{{
+			ID:    "ch-1",
+			Title: "Changes",
+			Sections: []model.Section{{
+				ID:    "s-1",
+				Title: "Update",
+				Hunks: []model.Hunk{{File: "main.go", StartLine: 1, Diff: validHunkDiff, Importance: "high"}},
+			}},
+		}},
+	}
+}
+
+func problemPaths(problems []review.Problem) []string {
+	var paths []string
+	for _, p := range problems {
+		paths = append(paths, p.Path)
+	}
+	return paths
+}
+
+func TestValidate_ValidReview(t *testing.T) {
+	if problems := review.Validate(validReview(), review.ValidateOptions{}); len(problems) != 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	r := validReview()
+	r.WorkingDirectory = ""
+	r.Chapters = append(r.Chapters, model.Chapter{
+		ID:    "ch-1",
+		Title: "Duplicate",
+		Sections: []model.Section{
+			{ID: "s-1", Hunks: []model.Hunk{
+				{File: "a.go", Diff: "not a diff", Importance: "critical"},
+				{Diff: validHunkDiff},
+			}},
+			{Title: "No ID or hunks"},
+		},
+	}, model.Chapter{Title: "Empty"})
+
+	problems := review.Validate(r, review.ValidateOptions{})
+
+	want := []string{
+		"$.workingDirectory",
+		"$.chapters[1].id",
+		"$.chapters[1].sections[0].id",
+		"$.chapters[1].sections[0].hunks[0].importance",
+		"$.chapters[1].sections[0].hunks[0].diff",
+		"$.chapters[1].sections[0].hunks[1].file",
+		"$.chapters[1].sections[0].hunks[1].importance",
+		"$.chapters[1].sections[1].id",
+		"$.chapters[1].sections[1].hunks",
+		"$.chapters[2].id",
+		"$.chapters[2].sections",
+	}
+	got := problemPaths(problems)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problem paths:\n%s\nwant:\n%s\nproblems: %v", strings.Join(got, "\n"), strings.Join(want, "\n"), problems)
+	}
+}
+
+func TestValidate_DuplicateIDNamesFirstUse(t *testing.T) {
+	r := validReview()
+	r.Chapters[0].Sections = append(r.Chapters[0].Sections, r.Chapters[0].Sections[0])
+
+	problems := review.Validate(r, review.ValidateOptions{})
+
+	if len(problems) != 1 {
+		t.Fatalf("expected 1 problem, got %v", problems)
+	}
+	if !strings.Contains(problems[0].Message, "$.chapters[0].sections[0]") {
+		t.Errorf("message should point at the first use: %q", problems[0].Message)
+	}
+}
+
+func TestValidate_NoChapters(t *testing.T) {
+	r := validReview()
+	r.Chapters = nil
+
+	got := problemPaths(review.Validate(r, review.ValidateOptions{}))
+	if len(got) != 1 || got[0] != "$.chapters" {
+		t.Errorf("expected a $.chapters problem, got %v", got)
+	}
+}
+
+func TestValidate_CurrentHunks(t *testing.T) {
+	r := validReview()
+	r.Chapters[0].Sections[0].Hunks = append(r.Chapters[0].Sections[0].Hunks,
+		model.Hunk{File: "gone.go", StartLine: 1, Diff: validHunkDiff, Importance: "low"})
+
+	current := []diff.ParsedHunk{{File: "main.go", StartLine: 1, Diff: validHunkDiff + "\n"}}
+	got := problemPaths(review.Validate(r, review.ValidateOptions{CurrentHunks: current}))
+
+	if len(got) != 1 || got[0] != "$.chapters[0].sections[0].hunks[1]" {
+		t.Errorf("expected only the missing hunk to be reported, got %v", got)
+	}
+}
+
+func TestValidate_EmptyCurrentDiff(t *testing.T) {
+	got := problemPaths(review.Validate(validReview(), review.ValidateOptions{CurrentHunks: []diff.ParsedHunk{}}))
+	if len(got) != 1 {
+		t.Errorf("expected the hunk to be reported against an empty diff, got %v", got)
+	}
+}
+
+func TestValidate_AcceptsFileHeaderHunks(t *testing.T) {
+	r := validReview()
+	r.Chapters[0].Sections[0].Hunks = append(r.Chapters[0].Sections[0].Hunks, model.Hunk{
+		File:       "new.go",
+		OldFile:    "old.go",
+		ChangeKind: model.ChangeRenamed,
+		Diff:       "rename from old.go\nrename to new.go",
+		Importance: "low",
+	})
+
+	if problems := review.Validate(r, review.ValidateOptions{}); len(problems) != 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+}
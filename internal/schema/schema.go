@@ -0,0 +1,174 @@
+// Package schema generates JSON Schemas from Go types, following their
+// encoding/json struct tags so the schema always matches what is read and
+// written.
+//
+// Two extra struct tags refine the generated schema:
+//
+//	desc:"..."          sets the property's description
+//	enum:"a,b,c"        restricts a string property to the listed values
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// Draft is the JSON Schema dialect of generated schemas
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document or subschema
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           Properties         `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Property is a named property of an object schema
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties lists an object's properties in struct field order
+type Properties []Property
+
+// MarshalJSON encodes properties as an object, keeping their order
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(prop.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// For generates the schema of v's type. Named struct types other than the
+// root are placed in $defs and referenced, so recursive and shared types are
+// described once.
+func For(v any, title, description string) *Schema {
+	g := &generator{defs: make(map[string]*Schema)}
+	root := g.object(reflect.TypeOf(v))
+	root.Schema = Draft
+	root.Title = title
+	root.Description = description
+	if len(g.defs) > 0 {
+		root.Defs = g.defs
+	}
+	return root
+}
+
+// MarshalIndent renders a schema as indented JSON
+func MarshalIndent(s *Schema) ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+type generator struct {
+	defs map[string]*Schema
+}
+
+// typeSchema returns the schema for t, referencing named structs via $defs
+func (g *generator) typeSchema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return g.object(t)
+		}
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // Reserve the name so recursive types terminate
+			g.defs[name] = g.object(t)
+		}
+		return &Schema{Ref: "#/$defs/" + name}
+	default:
+		panic(fmt.Sprintf("schema: unsupported type %s", t))
+	}
+}
+
+// object describes a struct's JSON fields. Fields without omitempty are
+// required, and unknown properties are rejected.
+func (g *generator) object(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	closed := false
+	s := &Schema{Type: "object", AdditionalProperties: &closed}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := g.typeSchema(field.Type)
+		prop.Description = field.Tag.Get("desc")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop.Enum = strings.Split(enum, ",")
+		}
+
+		s.Properties = append(s.Properties, Property{Name: name, Schema: prop})
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// Review returns the JSON Schema of review files, as loaded with -review
+func Review() *Schema {
+	return For(model.Review{}, "diffstory review", "A code review told as a story of chapters and sections")
+}
@@ -0,0 +1,116 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/schema"
+)
+
+type testItem struct {
+	Name     string   `json:"name" desc:"Item name"`
+	Level    string   `json:"level" enum:"high,low"`
+	Count    int      `json:"count,omitempty"`
+	Flag     *bool    `json:"flag,omitempty"`
+	Tags     []string `json:"tags"`
+	internal string
+	Skipped  string `json:"-"`
+}
+
+type testRoot struct {
+	When  time.Time  `json:"when"`
+	Items []testItem `json:"items"`
+	Main  *testItem  `json:"main,omitempty"`
+}
+
+func TestFor_DescribesStructFields(t *testing.T) {
+	s := schema.For(testRoot{}, "Root", "A test root")
+
+	if s.Schema != schema.Draft || s.Title != "Root" || s.Description != "A test root" {
+		t.Errorf("unexpected root metadata: %+v", s)
+	}
+	if got := strings.Join(s.Required, ","); got != "when,items" {
+		t.Errorf("required = %s, want when,items", got)
+	}
+	if s.AdditionalProperties == nil || *s.AdditionalProperties {
+		t.Error("expected additionalProperties: false")
+	}
+
+	props := map[string]*schema.Schema{}
+	for _, p := range s.Properties {
+		props[p.Name] = p.Schema
+	}
+	if props["when"].Type != "string" || props["when"].Format != "date-time" {
+		t.Errorf("time should be a date-time string, got %+v", props["when"])
+	}
+	if props["items"].Type != "array" || props["items"].Items.Ref != "#/$defs/testItem" {
+		t.Errorf("items should reference testItem, got %+v", props["items"])
+	}
+	if props["main"].Ref != "#/$defs/testItem" {
+		t.Errorf("pointer fields should reference their element type, got %+v", props["main"])
+	}
+
+	item := s.Defs["testItem"]
+	if item == nil {
+		t.Fatal("expected testItem in $defs")
+	}
+	var names []string
+	for _, p := range item.Properties {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "name,level,count,flag,tags" {
+		t.Errorf("properties = %s, want struct field order without unexported or skipped fields", got)
+	}
+	if got := strings.Join(item.Required, ","); got != "name,level,tags" {
+		t.Errorf("required = %s, want name,level,tags", got)
+	}
+	if item.Properties[0].Schema.Description != "Item name" {
+		t.Errorf("expected desc tag to set the description, got %+v", item.Properties[0].Schema)
+	}
+	if got := strings.Join(item.Properties[1].Schema.Enum, ","); got != "high,low" {
+		t.Errorf("enum = %s, want high,low", got)
+	}
+	if item.Properties[3].Schema.Type != "boolean" || item.Properties[4].Schema.Items.Type != "string" {
+		t.Errorf("unexpected flag/tags schemas: %+v %+v", item.Properties[3].Schema, item.Properties[4].Schema)
+	}
+}
+
+func TestMarshalIndent_KeepsPropertyOrder(t *testing.T) {
+	data, err := schema.MarshalIndent(schema.For(testItem{}, "", ""))
+	if err != nil {
+		t.Fatalf("MarshalIndent failed: %v", err)
+	}
+	out := string(data)
+	if strings.Index(out, `"name"`) > strings.Index(out, `"level"`) || strings.Index(out, `"level"`) > strings.Index(out, `"tags"`) {
+		t.Errorf("properties out of order:\n%s", out)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+}
+
+func TestReview_DescribesReviewFormat(t *testing.T) {
+	s := schema.Review()
+
+	data, err := schema.MarshalIndent(s)
+	if err != nil {
+		t.Fatalf("MarshalIndent failed: %v", err)
+	}
+	for _, want := range []string{`"schemaVersion"`, `"chapters"`, `"$ref": "#/$defs/Hunk"`, `"startLine"`, `"notes"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("review schema missing %s", want)
+		}
+	}
+	hunk := s.Defs["Hunk"]
+	if hunk == nil {
+		t.Fatal("expected Hunk in $defs")
+	}
+	for _, p := range hunk.Properties {
+		if p.Name == "importance" && strings.Join(p.Schema.Enum, ",") != "high,medium,low" {
+			t.Errorf("importance enum = %v", p.Schema.Enum)
+		}
+	}
+}
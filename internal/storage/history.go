@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// MaxHistoryEntries is the number of reviews kept in each directory's history.
+// Older entries are removed when a new review is written.
+const MaxHistoryEntries = 20
+
+// historyTimeFormat sorts lexically in chronological order
+const historyTimeFormat = "20060102T150405.000000000Z"
+
+// ReviewEntry describes a stored or archived review without its contents
+type ReviewEntry struct {
+	Path             string
+	WorkingDirectory string
+	Title            string
+	Branch           string
+	DiffSource       string
+	CreatedAt        time.Time
+	SectionCount     int
+}
+
+func newReviewEntry(path string, review *model.Review) ReviewEntry {
+	return ReviewEntry{
+		Path:             path,
+		WorkingDirectory: review.WorkingDirectory,
+		Title:            review.Title,
+		Branch:           review.Branch,
+		DiffSource:       review.DiffSource,
+		CreatedAt:        review.CreatedAt,
+		SectionCount:     review.SectionCount(),
+	}
+}
+
+// sortNewestFirst orders entries by creation time, most recent first
+func sortNewestFirst(entries []ReviewEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+}
+
+// historyDir returns the directory holding archived reviews for a normalized
+// working directory. It lives below the base directory so the watcher, which
+// only watches the base directory itself, never sees history writes.
+func (s *Store) historyDir(normalized string) string {
+	return filepath.Join(s.baseDir, "history", HashDirectory(normalized))
+}
+
+// archive stores a copy of a review in the history and prunes old entries
+func (s *Store) archive(normalized string, createdAt time.Time, data []byte) error {
+	dir := s.historyDir(normalized)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	path := filepath.Join(dir, createdAt.UTC().Format(historyTimeFormat)+".json")
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	return pruneArchive(dir)
+}
+
+// pruneArchive removes all but the newest MaxHistoryEntries files of an
+// archive directory
+func pruneArchive(dir string) error {
+	names, err := historyFileNames(dir)
+	if err != nil {
+		return err
+	}
+	for len(names) > MaxHistoryEntries {
+		// names is sorted newest first, so drop from the end
+		oldest := names[len(names)-1]
+		if err := os.Remove(filepath.Join(dir, oldest)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune %s: %w", filepath.Base(filepath.Dir(dir)), err)
+		}
+		names = names[:len(names)-1]
+	}
+	return nil
+}
+
+// historyFileNames returns the archived review file names in dir, newest first
+func historyFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// History returns the archived reviews for a directory, newest first.
+// Entries that cannot be read are skipped.
+func (s *Store) History(dir string) ([]ReviewEntry, error) {
+	normalized, err := NormalizePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	historyDir := s.historyDir(normalized)
+
+	names, err := historyFileNames(historyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []ReviewEntry
+	for _, name := range names {
+		path := filepath.Join(historyDir, name)
+		review, err := readReviewFile(path)
+		if err != nil {
+			continue
+		}
+		history = append(history, newReviewEntry(path, review))
+	}
+	return history, nil
+}
+
+// RemoveHistory deletes all archived reviews and generation transcripts for
+// a directory
+func (s *Store) RemoveHistory(dir string) error {
+	normalized, err := NormalizePath(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.historyDir(normalized)); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.transcriptDir(normalized))
+}
+
+// OrphanedArchives returns the history and transcript directories of working
+// directories that have no current review left, treating the review files in
+// removed as already gone. A review file that cannot be read still counts as
+// a current review, so its history is kept.
+func (s *Store) OrphanedArchives(removed ...string) ([]string, error) {
+	dirEntries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return nil, err
+	}
+	gone := make(map[string]bool, len(removed))
+	for _, path := range removed {
+		gone[path] = true
+	}
+	current := make(map[string]bool)
+	for _, e := range dirEntries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" || gone[filepath.Join(s.baseDir, e.Name())] {
+			continue
+		}
+		hash, _, _ := strings.Cut(e.Name(), ".")
+		current[hash] = true
+	}
+
+	var orphaned []string
+	for _, parent := range s.archiveParents() {
+		archives, err := os.ReadDir(parent)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range archives {
+			if e.IsDir() && !current[e.Name()] {
+				orphaned = append(orphaned, filepath.Join(parent, e.Name()))
+			}
+		}
+	}
+	return orphaned, nil
+}
+
+// RemoveArchive deletes a history or transcript directory returned by
+// OrphanedArchives
+func (s *Store) RemoveArchive(path string) error {
+	if !slices.Contains(s.archiveParents(), filepath.Dir(path)) {
+		return fmt.Errorf("not an archive directory in %s: %s", s.baseDir, path)
+	}
+	return os.RemoveAll(path)
+}
+
+// archiveParents returns the directories holding a subdirectory of archives
+// per working directory
+func (s *Store) archiveParents() []string {
+	return []string{filepath.Join(s.baseDir, "history"), filepath.Join(s.baseDir, "transcripts")}
+}
@@ -0,0 +1,198 @@
+package storage_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestStore_WriteArchivesReviewInHistory(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	review := model.Review{
+		WorkingDirectory: "/test/project",
+		Title:            "First Review",
+		DiffSource:       "Staged changes",
+		CreatedAt:        time.Now(),
+	}
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	history, err := store.History("/test/project")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected 1 history entry, got %d", len(history))
+	}
+	if history[0].Title != "First Review" {
+		t.Errorf("Title = %q, want %q", history[0].Title, "First Review")
+	}
+	if history[0].DiffSource != "Staged changes" {
+		t.Errorf("DiffSource = %q, want %q", history[0].DiffSource, "Staged changes")
+	}
+}
+
+func TestStore_HistoryKeepsPreviousReviewsNewestFirst(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	base := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		review := model.Review{
+			WorkingDirectory: "/test/project",
+			Title:            fmt.Sprintf("Review %d", i),
+			CreatedAt:        base.Add(time.Duration(i) * time.Minute),
+		}
+		if err := store.Write(review); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	history, err := store.History("/test/project")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 history entries, got %d", len(history))
+	}
+	if history[0].Title != "Review 2" || history[2].Title != "Review 0" {
+		t.Errorf("expected newest first, got %q ... %q", history[0].Title, history[2].Title)
+	}
+
+	// The current review is still the latest write
+	current, err := store.Read("/test/project")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if current.Title != "Review 2" {
+		t.Errorf("current Title = %q, want %q", current.Title, "Review 2")
+	}
+}
+
+func TestStore_HistoryIsBounded(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	base := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	total := storage.MaxHistoryEntries + 5
+	for i := 0; i < total; i++ {
+		review := model.Review{
+			WorkingDirectory: "/test/project",
+			Title:            fmt.Sprintf("Review %d", i),
+			CreatedAt:        base.Add(time.Duration(i) * time.Minute),
+		}
+		if err := store.Write(review); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	history, err := store.History("/test/project")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != storage.MaxHistoryEntries {
+		t.Fatalf("expected %d history entries, got %d", storage.MaxHistoryEntries, len(history))
+	}
+	if history[len(history)-1].Title != "Review 5" {
+		t.Errorf("oldest kept entry = %q, want %q", history[len(history)-1].Title, "Review 5")
+	}
+}
+
+func TestStore_HistoryIsScopedToDirectory(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	for _, dir := range []string{"/test/project-a", "/test/project-b"} {
+		if err := store.Write(model.Review{WorkingDirectory: dir, Title: dir, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	history, err := store.History("/test/project-a")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Title != "/test/project-a" {
+		t.Errorf("expected only project-a history, got %+v", history)
+	}
+}
+
+func TestStore_HistoryEmptyForUnknownDirectory(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	history, err := store.History("/nonexistent/project")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("expected no history, got %d entries", len(history))
+	}
+}
+
+func TestStore_ReadPathLoadsArchivedReview(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	older := model.Review{WorkingDirectory: "/test/project", Title: "Older", CreatedAt: time.Now().Add(-time.Hour)}
+	newer := model.Review{WorkingDirectory: "/test/project", Title: "Newer", CreatedAt: time.Now()}
+	for _, r := range []model.Review{older, newer} {
+		if err := store.Write(r); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	history, err := store.History("/test/project")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	loaded, err := store.ReadPath(history[1].Path)
+	if err != nil {
+		t.Fatalf("ReadPath failed: %v", err)
+	}
+	if loaded.Title != "Older" {
+		t.Errorf("Title = %q, want %q", loaded.Title, "Older")
+	}
+}
+
+func TestStore_HistoryDoesNotAddFilesToBaseDir(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := storage.NewStoreWithDir(baseDir)
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	if err := store.Write(model.Review{WorkingDirectory: "/test/project", Title: "Test"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) != ".json" {
+			t.Errorf("unexpected file in base directory: %s", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package storage_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestPathForReview_DefaultKeyMatchesPathForDirectory(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	keyed, _ := store.PathForReview("/test/project", "", "")
+	dirOnly, _ := store.PathForDirectory("/test/project")
+
+	if keyed != dirOnly {
+		t.Errorf("PathForReview with empty key = %q, want %q", keyed, dirOnly)
+	}
+}
+
+func TestPathForReview_DiffersByBranchAndSource(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	staged, _ := store.PathForReview("/test/project", "main", "Staged changes")
+	sinceMain, _ := store.PathForReview("/test/project", "main", "Changes since main")
+	otherBranch, _ := store.PathForReview("/test/project", "feature", "Staged changes")
+
+	if staged == sinceMain {
+		t.Error("different diff sources should produce different paths")
+	}
+	if staged == otherBranch {
+		t.Error("different branches should produce different paths")
+	}
+}
+
+func TestIsReviewPathFor_MatchesAllKeysForDirectory(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	dirOnly, _ := store.PathForDirectory("/test/project")
+	keyed, _ := store.PathForReview("/test/project", "main", "Staged changes")
+	otherDir, _ := store.PathForReview("/test/other", "main", "Staged changes")
+
+	if !store.IsReviewPathFor("/test/project", dirOnly) {
+		t.Error("directory-level path should match")
+	}
+	if !store.IsReviewPathFor("/test/project", keyed) {
+		t.Error("keyed path should match")
+	}
+	if store.IsReviewPathFor("/test/project", otherDir) {
+		t.Error("path for another directory should not match")
+	}
+	if store.IsReviewPathFor("/test/project", keyed+".tmp") {
+		t.Error("temp file should not match")
+	}
+	if store.IsReviewPathFor("/test/project", filepath.Join(store.BaseDir(), "history", filepath.Base(keyed))) {
+		t.Error("file outside the base directory should not match")
+	}
+}
+
+func TestStore_ReviewsForDifferentSourcesDoNotClobber(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	now := time.Now()
+	staged := model.Review{WorkingDirectory: "/test/project", Title: "Staged", Branch: "main", DiffSource: "Staged changes", CreatedAt: now.Add(-time.Minute)}
+	sinceMain := model.Review{WorkingDirectory: "/test/project", Title: "Since main", Branch: "main", DiffSource: "Changes since main", CreatedAt: now}
+	for _, r := range []model.Review{staged, sinceMain} {
+		if err := store.Write(r); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	reviews, err := store.Reviews("/test/project")
+	if err != nil {
+		t.Fatalf("Reviews failed: %v", err)
+	}
+	if len(reviews) != 2 {
+		t.Fatalf("expected 2 stored reviews, got %d", len(reviews))
+	}
+	if reviews[0].Title != "Since main" || reviews[1].Title != "Staged" {
+		t.Errorf("expected newest first, got %q, %q", reviews[0].Title, reviews[1].Title)
+	}
+	if reviews[1].Branch != "main" || reviews[1].DiffSource != "Staged changes" {
+		t.Errorf("entry should carry branch and source, got %+v", reviews[1])
+	}
+}
+
+func TestStore_ReadReturnsMostRecentReviewForDirectory(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	now := time.Now()
+	older := model.Review{WorkingDirectory: "/test/project", Title: "Older", DiffSource: "Staged changes", CreatedAt: now.Add(-time.Hour)}
+	newer := model.Review{WorkingDirectory: "/test/project", Title: "Newer", DiffSource: "Uncommitted changes", CreatedAt: now}
+	for _, r := range []model.Review{newer, older} {
+		if err := store.Write(r); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	loaded, err := store.Read("/test/project")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if loaded.Title != "Newer" {
+		t.Errorf("Title = %q, want %q", loaded.Title, "Newer")
+	}
+}
+
+func TestStore_AllListsReviewsAcrossDirectories(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, dir := range []string{"/test/a", "/test/b"} {
+		review := model.Review{WorkingDirectory: dir, Title: dir, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := store.Write(review); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	entries, err := store.All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].WorkingDirectory != "/test/b" {
+		t.Errorf("expected newest first, got %q", entries[0].WorkingDirectory)
+	}
+}
+
+func TestStore_RemoveRejectsPathsOutsideBaseDir(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	outside := filepath.Join(t.TempDir(), "review.json")
+	if err := os.WriteFile(outside, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Remove(outside); err == nil {
+		t.Error("expected error removing a file outside the store")
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the store should remain: %v", err)
+	}
+}
+
+func TestStore_RemoveHistoryDeletesArchive(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	review := model.Review{WorkingDirectory: "/test/project", CreatedAt: time.Now()}
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if err := store.RemoveHistory("/test/project"); err != nil {
+		t.Fatalf("RemoveHistory failed: %v", err)
+	}
+
+	history, err := store.History("/test/project")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("expected empty history, got %d entries", len(history))
+	}
+}
+
+func TestStore_ScanReportsUnreadableFiles(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	if err := store.Write(model.Review{WorkingDirectory: "/test/project", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	corrupt := filepath.Join(store.BaseDir(), "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, unreadable, err := store.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected 1 readable review, got %d", len(entries))
+	}
+	if len(unreadable) != 1 || unreadable[0].Path != corrupt || unreadable[0].Err == nil {
+		t.Errorf("expected the corrupt file to be reported, got %+v", unreadable)
+	}
+}
+
+func TestStore_OrphanedArchives(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	for _, dir := range []string{"/test/kept", "/test/removed"} {
+		if err := store.Write(model.Review{WorkingDirectory: dir, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if _, err := store.WriteTranscript("/test/removed", model.Transcript{}); err != nil {
+		t.Fatalf("WriteTranscript failed: %v", err)
+	}
+	if _, err := store.WriteTranscript("/test/long-gone", model.Transcript{}); err != nil {
+		t.Fatalf("WriteTranscript failed: %v", err)
+	}
+	removed, _ := store.PathForDirectory("/test/removed")
+
+	orphaned, err := store.OrphanedArchives()
+	if err != nil {
+		t.Fatalf("OrphanedArchives failed: %v", err)
+	}
+	if len(orphaned) != 1 || filepath.Base(orphaned[0]) != storage.HashDirectory("/test/long-gone") {
+		t.Errorf("expected only the transcripts of a directory without reviews, got %v", orphaned)
+	}
+
+	orphaned, err = store.OrphanedArchives(removed)
+	if err != nil {
+		t.Fatalf("OrphanedArchives failed: %v", err)
+	}
+	if len(orphaned) != 3 {
+		t.Fatalf("expected the removed review's history and transcripts as well, got %v", orphaned)
+	}
+	if err := store.Remove(removed); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	for _, dir := range orphaned {
+		if err := store.RemoveArchive(dir); err != nil {
+			t.Fatalf("RemoveArchive failed: %v", err)
+		}
+	}
+	if history, _ := store.History("/test/kept"); len(history) != 1 {
+		t.Errorf("expected the kept directory's history to remain, got %d entries", len(history))
+	}
+	if history, _ := store.History("/test/removed"); len(history) != 0 {
+		t.Errorf("expected the removed directory's history to be gone, got %d entries", len(history))
+	}
+}
+
+func TestStore_RemoveArchiveRejectsOtherPaths(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	if err := store.RemoveArchive(store.BaseDir()); err == nil {
+		t.Error("expected error removing the base directory")
+	}
+	if _, err := os.Stat(store.BaseDir()); err != nil {
+		t.Errorf("base dir should remain: %v", err)
+	}
+}
+
+func TestStore_OverwriteReplacesCurrentReviewWithoutArchiving(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	review := model.NewReviewWithSections("/test/project", "Review", []model.Section{{ID: "1"}})
+	review.CreatedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	review.Chapters[0].Sections[0].Reviewed = true
+	if err := store.Overwrite(review); err != nil {
+		t.Fatalf("Overwrite failed: %v", err)
+	}
+
+	loaded, err := store.Read("/test/project")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !loaded.Chapters[0].Sections[0].Reviewed {
+		t.Error("expected overwritten review to keep reviewed state")
+	}
+	history, _ := store.History("/test/project")
+	if len(history) != 1 {
+		t.Errorf("Overwrite should not archive, got %d history entries", len(history))
+	}
+}
+
+func TestStore_OverwriteRejectsReplacedReview(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	old := model.Review{WorkingDirectory: "/test/project", Title: "Old", CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newer := model.Review{WorkingDirectory: "/test/project", Title: "New", CreatedAt: old.CreatedAt.Add(time.Hour)}
+	for _, r := range []model.Review{old, newer} {
+		if err := store.Write(r); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	if err := store.Overwrite(old); !errors.Is(err, storage.ErrNotCurrent) {
+		t.Fatalf("expected ErrNotCurrent, got %v", err)
+	}
+	loaded, _ := store.Read("/test/project")
+	if loaded.Title != "New" {
+		t.Errorf("newer review should be untouched, got %q", loaded.Title)
+	}
+}
+
+func TestStore_OverwriteRejectsUnstoredReview(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	err = store.Overwrite(model.Review{WorkingDirectory: "/test/project"})
+	if !errors.Is(err, storage.ErrNotCurrent) {
+		t.Fatalf("expected ErrNotCurrent, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LLMCacheKey hashes everything that determines an LLM reply into a cache
+// key. Parts are separated so that moving text between them changes the key.
+func LLMCacheKey(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// llmCacheDir holds cached LLM replies. Like the history, it lives below the
+// base directory so the watcher never sees cache writes.
+func (s *Store) llmCacheDir() string {
+	return filepath.Join(s.baseDir, "llm-cache")
+}
+
+func (s *Store) llmCachePath(key string) (string, error) {
+	if _, err := hex.DecodeString(key); err != nil || key == "" {
+		return "", fmt.Errorf("invalid LLM cache key %q", key)
+	}
+	return filepath.Join(s.llmCacheDir(), key+".json"), nil
+}
+
+// CachedLLMResponse returns the reply cached under key, if any. A hit counts
+// as a use, so the entry is among the last to be pruned.
+func (s *Store) CachedLLMResponse(key string) ([]byte, bool) {
+	path, err := s.llmCachePath(key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// CacheLLMResponse stores a reply under key, then removes the least recently
+// used entries until the cache holds at most maxBytes
+func (s *Store) CacheLLMResponse(key string, data []byte, maxBytes int64) error {
+	path, err := s.llmCachePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.llmCacheDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create LLM cache directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	return s.pruneLLMCache(maxBytes)
+}
+
+// pruneLLMCache removes the least recently used entries until the cache
+// holds at most maxBytes
+func (s *Store) pruneLLMCache(maxBytes int64) error {
+	entries, err := os.ReadDir(s.llmCacheDir())
+	if err != nil {
+		return err
+	}
+
+	type cacheFile struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cacheFile
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{e.Name(), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+
+	// Oldest first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(s.llmCacheDir(), f.name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune LLM cache: %w", err)
+		}
+		total -= f.size
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestLLMCacheKey_SeparatesParts(t *testing.T) {
+	if storage.LLMCacheKey("ab", "c") == storage.LLMCacheKey("a", "bc") {
+		t.Error("expected different keys when text moves between parts")
+	}
+	if storage.LLMCacheKey("a", "b") != storage.LLMCacheKey("a", "b") {
+		t.Error("expected the same parts to give the same key")
+	}
+}
+
+func TestStore_CachesLLMResponses(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	key := storage.LLMCacheKey("hunks", "claude")
+
+	if _, ok := store.CachedLLMResponse(key); ok {
+		t.Fatal("expected a miss before caching")
+	}
+	if err := store.CacheLLMResponse(key, []byte(`{"title":"T"}`), 1000); err != nil {
+		t.Fatalf("CacheLLMResponse failed: %v", err)
+	}
+	data, ok := store.CachedLLMResponse(key)
+	if !ok || string(data) != `{"title":"T"}` {
+		t.Errorf("expected the cached reply, got %q (hit %v)", data, ok)
+	}
+
+	// The cache stays out of the reviews the watcher and list see
+	entries, err := store.All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no reviews, got %v", entries)
+	}
+}
+
+func TestStore_CacheLLMResponsePrunesLeastRecentlyUsed(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := storage.NewStoreWithDir(baseDir)
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	reply := []byte(strings.Repeat("x", 100))
+	first, second, third := storage.LLMCacheKey("1"), storage.LLMCacheKey("2"), storage.LLMCacheKey("3")
+
+	for i, key := range []string{first, second} {
+		if err := store.CacheLLMResponse(key, reply, 250); err != nil {
+			t.Fatalf("CacheLLMResponse failed: %v", err)
+		}
+		// Give each entry a distinct age
+		past := time.Now().Add(time.Duration(i-10) * time.Minute)
+		if err := os.Chtimes(filepath.Join(baseDir, "llm-cache", key+".json"), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Using the first entry makes the second the least recently used
+	if _, ok := store.CachedLLMResponse(first); !ok {
+		t.Fatal("expected the first entry cached")
+	}
+	if err := store.CacheLLMResponse(third, reply, 250); err != nil {
+		t.Fatalf("CacheLLMResponse failed: %v", err)
+	}
+
+	for key, want := range map[string]bool{first: true, second: false, third: true} {
+		if _, ok := store.CachedLLMResponse(key); ok != want {
+			t.Errorf("entry %s cached = %v, want %v", key[:8], ok, want)
+		}
+	}
+}
+
+func TestStore_CachedLLMResponseRejectsPaths(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	if err := store.CacheLLMResponse("../escape", []byte("{}"), 1000); err == nil {
+		t.Error("expected an error for a key that is not a hash")
+	}
+}
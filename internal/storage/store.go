@@ -4,9 +4,11 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mchowning/diffstory/internal/model"
 )
@@ -71,13 +73,52 @@ func HashDirectory(dir string) string {
 }
 
 // PathForDirectory returns the file path for a given working directory.
-// The directory path is normalized before hashing.
+// The directory path is normalized before hashing. This is the path used by
+// reviews that record neither a branch nor a diff source.
 func (s *Store) PathForDirectory(dir string) (string, error) {
+	return s.PathForReview(dir, "", "")
+}
+
+// PathForReview returns the file path for a review of dir made on branch from
+// diffSource. Reviews of the same directory share the directory hash as a
+// prefix, so all reviews for a repository can be found together.
+func (s *Store) PathForReview(dir, branch, diffSource string) (string, error) {
 	normalized, err := NormalizePath(dir)
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(s.baseDir, HashDirectory(normalized)+".json"), nil
+	name := HashDirectory(normalized)
+	if branch != "" || diffSource != "" {
+		keyHash := sha256.Sum256([]byte(branch + "\x00" + diffSource))
+		name += "." + hex.EncodeToString(keyHash[:])[:keyHashLength]
+	}
+	return filepath.Join(s.baseDir, name+".json"), nil
+}
+
+// keyHashLength is the number of hex characters of the branch/source hash
+// used in review file names
+const keyHashLength = 16
+
+// IsReviewPathFor reports whether path is a review file for the normalized
+// directory dir, regardless of the branch and diff source it was keyed by.
+func (s *Store) IsReviewPathFor(dir, path string) bool {
+	if filepath.Dir(path) != s.baseDir {
+		return false
+	}
+	name := filepath.Base(path)
+	prefix := HashDirectory(dir)
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".json") {
+		return false
+	}
+	key := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json")
+	if key == "" {
+		return true
+	}
+	if len(key) != keyHashLength+1 || key[0] != '.' {
+		return false
+	}
+	_, err := hex.DecodeString(key[1:])
+	return err == nil
 }
 
 // BaseDir returns the base directory for review files (for watcher setup)
@@ -86,21 +127,85 @@ func (s *Store) BaseDir() string {
 }
 
 // Write persists a review to disk using atomic write (temp file + rename)
-// to prevent partial reads by file watchers.
+// to prevent partial reads by file watchers. A copy is also archived in the
+// directory's history so earlier reviews remain available.
 func (s *Store) Write(review model.Review) error {
-	path, err := s.PathForDirectory(review.WorkingDirectory)
+	normalized, err := NormalizePath(review.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	path, err := s.PathForReview(normalized, review.Branch, review.DiffSource)
+	if err != nil {
+		return err
+	}
+
+	review.SchemaVersion = model.CurrentSchemaVersion
+	data, err := json.MarshalIndent(review, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+
+	return s.archive(normalized, review.CreatedAt, data)
+}
+
+// ErrNotCurrent is returned by Overwrite when the review is not the one
+// currently stored for its directory, branch and diff source
+var ErrNotCurrent = errors.New("review is not the current stored review")
+
+// Overwrite replaces the stored review with an updated copy of itself, without
+// archiving it to history. It is used to persist reader state such as review
+// progress, and fails with ErrNotCurrent if the stored review has since been
+// replaced by a newer one or was never stored (for example an archived review).
+func (s *Store) Overwrite(review model.Review) error {
+	normalized, err := NormalizePath(review.WorkingDirectory)
+	if err != nil {
+		return err
+	}
+	path, err := s.PathForReview(normalized, review.Branch, review.DiffSource)
+	if err != nil {
+		return err
+	}
+
+	current, err := readReviewFile(path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotCurrent
+		}
 		return err
 	}
+	if !current.CreatedAt.Equal(review.CreatedAt) {
+		return ErrNotCurrent
+	}
 
+	review.SchemaVersion = model.CurrentSchemaVersion
 	data, err := json.MarshalIndent(review, "", "  ")
 	if err != nil {
 		return err
 	}
+	return writeFileAtomic(path, data)
+}
 
-	// Atomic write: write to temp file, then rename
-	tempPath := path + ".tmp"
-	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+// writeFileAtomic writes data to a temp file and renames it into place. The
+// temp file's name is unique, so concurrent writes cannot tear each other's.
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tempPath := temp.Name()
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0644)
+	}
+	if err != nil {
+		os.Remove(tempPath)
 		return fmt.Errorf("failed to write temp file: %w", err)
 	}
 
@@ -112,19 +217,105 @@ func (s *Store) Write(review model.Review) error {
 	return nil
 }
 
-// Read loads a review from disk for a given directory
+// Read loads the most recently created review for a given directory,
+// whichever branch or diff source it was made for.
 func (s *Store) Read(dir string) (*model.Review, error) {
-	path, err := s.PathForDirectory(dir)
+	entries, err := s.Reviews(dir)
 	if err != nil {
 		return nil, err
 	}
-	data, err := os.ReadFile(path)
+	if len(entries) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return readReviewFile(entries[0].Path)
+}
+
+// ReadPath loads a review from a path returned by Reviews or History
+func (s *Store) ReadPath(path string) (*model.Review, error) {
+	return readReviewFile(path)
+}
+
+// Reviews returns the stored reviews for a directory, one per branch and
+// diff source, newest first. Files that cannot be read are skipped.
+func (s *Store) Reviews(dir string) ([]ReviewEntry, error) {
+	normalized, err := NormalizePath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries, err := os.ReadDir(s.baseDir)
 	if err != nil {
 		return nil, err
 	}
-	var review model.Review
-	if err := json.Unmarshal(data, &review); err != nil {
+
+	var reviews []ReviewEntry
+	for _, e := range dirEntries {
+		path := filepath.Join(s.baseDir, e.Name())
+		if e.IsDir() || !s.IsReviewPathFor(normalized, path) {
+			continue
+		}
+		review, err := readReviewFile(path)
+		if err != nil {
+			continue
+		}
+		reviews = append(reviews, newReviewEntry(path, review))
+	}
+	sortNewestFirst(reviews)
+	return reviews, nil
+}
+
+// All returns every stored review in the cache, across all directories,
+// newest first. Files that cannot be read are skipped.
+func (s *Store) All() ([]ReviewEntry, error) {
+	reviews, _, err := s.Scan()
+	return reviews, err
+}
+
+// UnreadableFile is a review file in the cache that could not be read
+type UnreadableFile struct {
+	Path string
+	Err  error
+}
+
+// Scan returns every stored review like All, along with the review files
+// that could not be read
+func (s *Store) Scan() ([]ReviewEntry, []UnreadableFile, error) {
+	dirEntries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reviews []ReviewEntry
+	var unreadable []UnreadableFile
+	for _, e := range dirEntries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(s.baseDir, e.Name())
+		review, err := readReviewFile(path)
+		if err != nil {
+			unreadable = append(unreadable, UnreadableFile{Path: path, Err: err})
+			continue
+		}
+		reviews = append(reviews, newReviewEntry(path, review))
+	}
+	sortNewestFirst(reviews)
+	return reviews, unreadable, nil
+}
+
+// Remove deletes a stored review file. Only review files directly inside the
+// base directory can be removed.
+func (s *Store) Remove(path string) error {
+	if filepath.Dir(path) != s.baseDir || filepath.Ext(path) != ".json" {
+		return fmt.Errorf("not a review file in %s: %s", s.baseDir, path)
+	}
+	return os.Remove(path)
+}
+
+func readReviewFile(path string) (*model.Review, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
 		return nil, err
 	}
-	return &review, nil
+	return model.ParseReview(data)
 }
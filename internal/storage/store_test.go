@@ -3,6 +3,7 @@ package storage_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/mchowning/diffstory/internal/model"
@@ -245,3 +246,46 @@ func TestNewStore_FallsBackToDotCacheWhenXDGUnset(t *testing.T) {
 		t.Errorf("expected BaseDir %q, got %q", expected, store.BaseDir())
 	}
 }
+
+func TestStore_WriteRecordsSchemaVersion(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := storage.NewStoreWithDir(baseDir)
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	if err := store.Write(model.Review{WorkingDirectory: "/test/project", Title: "Test"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	path, _ := store.PathForDirectory("/test/project")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read review file: %v", err)
+	}
+	if !strings.Contains(string(data), `"schemaVersion": 3`) {
+		t.Errorf("expected schemaVersion in written file:\n%s", data)
+	}
+}
+
+func TestStore_ReadMigratesFlatReview(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := storage.NewStoreWithDir(baseDir)
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+
+	path, _ := store.PathForDirectory("/test/project")
+	flat := `{"workingDirectory": "/test/project", "title": "Old", "sections": [{"id": "1", "title": "First", "hunks": []}]}`
+	if err := os.WriteFile(path, []byte(flat), 0644); err != nil {
+		t.Fatalf("failed to write review file: %v", err)
+	}
+
+	loaded, err := store.Read("/test/project")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if loaded.SectionCount() != 1 || loaded.Chapters[0].Sections[0].Title != "First" {
+		t.Errorf("flat review not migrated: %+v", loaded.Chapters)
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// transcriptDir returns the directory holding the generation transcripts of
+// a normalized working directory. Like the history, it lives below the base
+// directory, out of the watcher's sight.
+func (s *Store) transcriptDir(normalized string) string {
+	return filepath.Join(s.baseDir, "transcripts", HashDirectory(normalized))
+}
+
+// WriteTranscript archives the transcript of a generation in dir and returns
+// the ID a review refers to it by. As with the history, only the newest
+// MaxHistoryEntries transcripts of a directory are kept.
+func (s *Store) WriteTranscript(dir string, transcript model.Transcript) (string, error) {
+	normalized, err := NormalizePath(dir)
+	if err != nil {
+		return "", err
+	}
+	archiveDir := s.transcriptDir(normalized)
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create transcript directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(transcript, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	id := time.Now().UTC().Format(historyTimeFormat)
+	if err := writeFileAtomic(filepath.Join(archiveDir, id+".json"), data); err != nil {
+		return "", err
+	}
+	return id, pruneArchive(archiveDir)
+}
+
+// ReadTranscript returns the transcript archived for dir under id. It
+// returns an error wrapping os.ErrNotExist once the transcript has been
+// pruned.
+func (s *Store) ReadTranscript(dir, id string) (*model.Transcript, error) {
+	if _, err := time.Parse(historyTimeFormat, id); err != nil {
+		return nil, fmt.Errorf("invalid transcript id %q", id)
+	}
+	normalized, err := NormalizePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(s.transcriptDir(normalized), id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var transcript model.Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, fmt.Errorf("invalid transcript %s: %w", id, err)
+	}
+	return &transcript, nil
+}
@@ -0,0 +1,95 @@
+package storage_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestStore_WritesAndReadsTranscripts(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+	transcript := model.Transcript{Exchanges: []model.Exchange{
+		{Purpose: "classification", Prompt: "Classify", Response: `{"title":"T"}`, DurationMs: 1200, JSONRepaired: true},
+	}}
+
+	id, err := store.WriteTranscript(workDir, transcript)
+	if err != nil {
+		t.Fatalf("WriteTranscript failed: %v", err)
+	}
+	got, err := store.ReadTranscript(workDir, id)
+	if err != nil {
+		t.Fatalf("ReadTranscript failed: %v", err)
+	}
+	if !reflect.DeepEqual(*got, transcript) {
+		t.Errorf("got %+v, want %+v", *got, transcript)
+	}
+
+	// Transcripts are scoped to their directory and stay out of the reviews
+	if _, err := store.ReadTranscript(t.TempDir(), id); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist for another directory, got %v", err)
+	}
+	if entries, err := store.All(); err != nil || len(entries) != 0 {
+		t.Errorf("expected no reviews, got %v (error %v)", entries, err)
+	}
+}
+
+func TestStore_TranscriptsAreBounded(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+
+	var ids []string
+	for i := 0; i <= storage.MaxHistoryEntries; i++ {
+		id, err := store.WriteTranscript(workDir, model.Transcript{})
+		if err != nil {
+			t.Fatalf("WriteTranscript failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if _, err := store.ReadTranscript(workDir, ids[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the oldest transcript to be pruned, got %v", err)
+	}
+	if _, err := store.ReadTranscript(workDir, ids[len(ids)-1]); err != nil {
+		t.Errorf("expected the newest transcript to be kept, got %v", err)
+	}
+}
+
+func TestStore_ReadTranscriptRejectsPaths(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	if _, err := store.ReadTranscript(t.TempDir(), "../../reviews"); err == nil {
+		t.Error("expected an error for an id that is not a transcript id")
+	}
+}
+
+func TestStore_RemoveHistoryRemovesTranscripts(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+	id, err := store.WriteTranscript(workDir, model.Transcript{})
+	if err != nil {
+		t.Fatalf("WriteTranscript failed: %v", err)
+	}
+
+	if err := store.RemoveHistory(workDir); err != nil {
+		t.Fatalf("RemoveHistory failed: %v", err)
+	}
+	if _, err := store.ReadTranscript(workDir, id); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the transcript to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/schema"
+)
+
+const batchPromptAddendum = `
+
+## Batch %d Of %d
+
+This diff is too large for one request, so its hunks are classified in %d batches and the input
+holds only this batch. Classify every hunk in it. Sections from all batches are combined
+afterwards, so make each section's title, what and why specific enough to tell which sections of
+other batches describe the same change.`
+
+const mergePromptTemplate = `You are a code review assistant. A diff too large for one request was classified in %d batches,
+each into its own chapters and sections. Combine them into the story a reviewer of the whole diff
+should read.
+
+The sections of all batches, with the chapter each was placed in and its number of hunks, are:
+%s
+
+Respond with JSON in this exact format (no markdown fences, no explanation text):
+{
+  "title": "Brief title for the whole review",
+  "chapters": [
+    {
+      "id": "chapter-identifier",
+      "title": "Short chapter title",
+      "sections": [
+        {
+          "id": "section-identifier",
+          "title": "Short section title",
+          "what": "Brief description of WHAT changed in this section",
+          "why": "Brief explanation of WHY this change was made",
+          "from": ["1/section-identifier", "2/other-section-identifier"]
+        }
+      ]
+    }
+  ]
+}
+
+- List every batch section id in the from list of exactly one section.
+- When sections of different batches describe the same change, merge them into one section that
+  lists all their ids, and write its what and why to cover all of them.
+- Group sections into chapters by functional purpose, not by batch or file path, with the same
+  title lengths as the batches used.
+
+## Response Schema
+
+Your response must validate against this JSON Schema:
+%s
+%s`
+
+// mergeResponse is the merge pass's plan for combining batch sections
+type mergeResponse struct {
+	Title    string         `json:"title" desc:"Brief title for the whole review"`
+	Chapters []mergeChapter `json:"chapters"`
+}
+
+type mergeChapter struct {
+	ID       string         `json:"id"`
+	Title    string         `json:"title"`
+	Sections []mergeSection `json:"sections"`
+}
+
+type mergeSection struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	What  string   `json:"what" desc:"One sentence describing what changed"`
+	Why   string   `json:"why" desc:"One sentence explaining why the change was made"`
+	From  []string `json:"from" desc:"ids of the batch sections combined into this section; every batch section must appear exactly once"`
+}
+
+// mergeOutlineSection is a batch section as shown to the merge pass
+type mergeOutlineSection struct {
+	ID      string `json:"id"`
+	Chapter string `json:"chapter"`
+	Title   string `json:"title"`
+	What    string `json:"what"`
+	Why     string `json:"why"`
+	Hunks   int    `json:"hunks"`
+}
+
+// mergeSchema returns the JSON Schema of the merge pass's response
+func mergeSchema() *schema.Schema {
+	return schema.For(mergeResponse{}, "diffstory batch merge",
+		"Plan for combining sections classified in separate batches, returned by the LLM")
+}
+
+// hunkBatchOverhead approximates the JSON around a hunk's diff in the input
+const hunkBatchOverhead = 100
+
+// batchHunks splits hunks into batches whose input JSON stays within budget
+// bytes. A file's hunks stay in one batch unless they alone exceed the
+// budget; a single hunk over the budget gets a batch of its own. A budget of
+// zero or less puts every hunk in one batch.
+func batchHunks(hunks []diff.ParsedHunk, budget int) [][]diff.ParsedHunk {
+	size := func(hs []diff.ParsedHunk) int {
+		total := 0
+		for _, h := range hs {
+			total += len(h.Diff) + len(h.File) + len(h.OldFile) + hunkBatchOverhead
+		}
+		return total
+	}
+	if budget <= 0 || size(hunks) <= budget {
+		return [][]diff.ParsedHunk{hunks}
+	}
+
+	var batches [][]diff.ParsedHunk
+	var current []diff.ParsedHunk
+	currentSize := 0
+	add := func(hs []diff.ParsedHunk) {
+		s := size(hs)
+		if len(current) > 0 && currentSize+s > budget {
+			batches = append(batches, current)
+			current, currentSize = nil, 0
+		}
+		current = append(current, hs...)
+		currentSize += s
+	}
+
+	for start := 0; start < len(hunks); {
+		end := start + 1
+		for end < len(hunks) && hunks[end].File == hunks[start].File {
+			end++
+		}
+		file := hunks[start:end]
+		if size(file) <= budget {
+			add(file)
+		} else {
+			for i := range file {
+				add(file[i : i+1])
+			}
+		}
+		start = end
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// batchSectionID qualifies a section ID with its 1-based batch number, as
+// batches choose their section IDs independently
+func batchSectionID(batch int, id string) string {
+	return fmt.Sprintf("%d/%s", batch, id)
+}
+
+// qualifyBatches qualifies the chapter and section IDs of each batch with
+// its batch number, so combining them cannot fold together unrelated
+// sections that separate batches happened to give the same ID, such as
+// "section-1"
+func qualifyBatches(batches []LLMResponse) []LLMResponse {
+	qualified := make([]LLMResponse, len(batches))
+	for bi, batch := range batches {
+		qualified[bi] = LLMResponse{Title: batch.Title}
+		for _, ch := range batch.Chapters {
+			chapter := LLMChapter{ID: batchSectionID(bi+1, ch.ID), Title: ch.Title}
+			for _, s := range ch.Sections {
+				s.ID = batchSectionID(bi+1, s.ID)
+				chapter.Sections = append(chapter.Sections, s)
+			}
+			qualified[bi].Chapters = append(qualified[bi].Chapters, chapter)
+		}
+	}
+	return qualified
+}
+
+// mergeOutlineJSON renders the sections of all batches for the merge prompt
+func mergeOutlineJSON(batches []LLMResponse) (string, error) {
+	var outline []mergeOutlineSection
+	for bi, batch := range batches {
+		for _, ch := range batch.Chapters {
+			for _, s := range ch.Sections {
+				outline = append(outline, mergeOutlineSection{
+					ID:      batchSectionID(bi+1, s.ID),
+					Chapter: ch.Title,
+					Title:   s.Title,
+					What:    s.What,
+					Why:     s.Why,
+					Hunks:   len(s.Hunks),
+				})
+			}
+		}
+	}
+	data, err := json.MarshalIndent(outline, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to build batch outline: %w", err)
+	}
+	return string(data), nil
+}
+
+// applyMergePlan builds one classification from the batches by moving each
+// batch section's hunks into the merged section listing it. Only hunk
+// references from the batches are used, so the plan cannot add, drop or
+// duplicate hunks: a batch section the plan lists twice goes to the first
+// section, and one it leaves out keeps its batch chapter, with both IDs
+// qualified by the batch.
+func applyMergePlan(plan mergeResponse, batches []LLMResponse) LLMResponse {
+	type batchSection struct {
+		chapter LLMChapter
+		section LLMSection
+	}
+	byID := make(map[string]*batchSection)
+	var order []string
+	for _, batch := range qualifyBatches(batches) {
+		for _, ch := range batch.Chapters {
+			for _, s := range ch.Sections {
+				if existing, ok := byID[s.ID]; ok {
+					existing.section.Hunks = append(existing.section.Hunks, s.Hunks...)
+					continue
+				}
+				byID[s.ID] = &batchSection{chapter: ch, section: s}
+				order = append(order, s.ID)
+			}
+		}
+	}
+
+	merged := LLMResponse{Title: plan.Title}
+	if merged.Title == "" && len(batches) > 0 {
+		merged.Title = batches[0].Title
+	}
+	used := make(map[string]bool)
+	for _, ch := range plan.Chapters {
+		chapter := LLMChapter{ID: ch.ID, Title: ch.Title}
+		for _, s := range ch.Sections {
+			section := LLMSection{ID: s.ID, Title: s.Title, What: s.What, Why: s.Why}
+			for _, id := range s.From {
+				if bs, ok := byID[id]; ok && !used[id] {
+					used[id] = true
+					section.Hunks = append(section.Hunks, bs.section.Hunks...)
+				}
+			}
+			if len(section.Hunks) > 0 {
+				chapter.Sections = append(chapter.Sections, section)
+			}
+		}
+		if len(chapter.Sections) > 0 {
+			merged.Chapters = append(merged.Chapters, chapter)
+		}
+	}
+
+	var leftover []LLMChapter
+	for _, id := range order {
+		if !used[id] {
+			bs := byID[id]
+			leftover = append(leftover, LLMChapter{ID: bs.chapter.ID, Title: bs.chapter.Title, Sections: []LLMSection{bs.section}})
+		}
+	}
+	return combineResponses(merged, LLMResponse{Chapters: leftover})
+}
+
+// combineResponses joins classifications without a merge pass: chapters and
+// sections with the same ID are combined, the rest appended in order. It is
+// used when regenerating incrementally, where batches place hunks into the
+// existing story's sections by ID, and to add repair placements to a
+// classification. Independent batches are qualified with qualifyBatches
+// first.
+func combineResponses(responses ...LLMResponse) LLMResponse {
+	var combined LLMResponse
+	for _, r := range responses {
+		if combined.Title == "" {
+			combined.Title = r.Title
+		}
+		for _, ch := range r.Chapters {
+			for _, s := range ch.Sections {
+				if ci, si, ok := findLLMSectionByID(combined.Chapters, s.ID); ok {
+					existing := &combined.Chapters[ci].Sections[si]
+					existing.Hunks = append(existing.Hunks, s.Hunks...)
+					continue
+				}
+				ci := -1
+				for i, c := range combined.Chapters {
+					if c.ID == ch.ID {
+						ci = i
+						break
+					}
+				}
+				if ci < 0 {
+					combined.Chapters = append(combined.Chapters, LLMChapter{ID: ch.ID, Title: ch.Title})
+					ci = len(combined.Chapters) - 1
+				}
+				combined.Chapters[ci].Sections = append(combined.Chapters[ci].Sections, s)
+			}
+		}
+	}
+	return combined
+}
+
+func findLLMSectionByID(chapters []LLMChapter, id string) (int, int, bool) {
+	for ci, ch := range chapters {
+		for si, s := range ch.Sections {
+			if s.ID == id {
+				return ci, si, true
+			}
+		}
+	}
+	return -1, -1, false
+}
@@ -0,0 +1,230 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func batchIDs(batches [][]diff.ParsedHunk) [][]string {
+	var ids [][]string
+	for _, batch := range batches {
+		var batchIDs []string
+		for _, h := range batch {
+			batchIDs = append(batchIDs, h.ID)
+		}
+		ids = append(ids, batchIDs)
+	}
+	return ids
+}
+
+func TestBatchHunks(t *testing.T) {
+	hunk := func(file string, line, size int) diff.ParsedHunk {
+		return diff.ParsedHunk{ID: file + "::" + string(rune('0'+line)), File: file, Diff: strings.Repeat("x", size)}
+	}
+	hunks := []diff.ParsedHunk{
+		hunk("a.go", 1, 100),
+		hunk("a.go", 2, 100),
+		hunk("b.go", 1, 100),
+		hunk("c.go", 1, 500),
+		hunk("c.go", 2, 500),
+	}
+
+	tests := []struct {
+		name     string
+		budget   int
+		expected [][]string
+	}{
+		{"unbatched", 0, [][]string{{"a.go::1", "a.go::2", "b.go::1", "c.go::1", "c.go::2"}}},
+		{"within budget", 10000, [][]string{{"a.go::1", "a.go::2", "b.go::1", "c.go::1", "c.go::2"}}},
+		{"files kept together", 700, [][]string{{"a.go::1", "a.go::2", "b.go::1"}, {"c.go::1"}, {"c.go::2"}}},
+		{"oversized hunks alone", 300, [][]string{{"a.go::1"}, {"a.go::2"}, {"b.go::1"}, {"c.go::1"}, {"c.go::2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := batchIDs(batchHunks(hunks, tt.budget))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected batches %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyMergePlan(t *testing.T) {
+	batches := []LLMResponse{
+		{Title: "Batch one", Chapters: []LLMChapter{{ID: "auth", Title: "Auth", Sections: []LLMSection{
+			{ID: "login", Title: "Login", Hunks: []LLMHunkRef{{ID: "a.go::1", Importance: "high"}}},
+			{ID: "docs", Title: "Docs", Hunks: []LLMHunkRef{{ID: "a.md::1", Importance: "low"}}},
+		}}}},
+		{Title: "Batch two", Chapters: []LLMChapter{{ID: "auth", Title: "Auth", Sections: []LLMSection{
+			{ID: "login", Title: "Login tests", Hunks: []LLMHunkRef{{ID: "a_test.go::1", Importance: "medium"}}},
+		}}}},
+	}
+	plan := mergeResponse{
+		Title: "Add login",
+		Chapters: []mergeChapter{{ID: "authentication", Title: "Authentication", Sections: []mergeSection{
+			// Lists a batch section twice and one that does not exist
+			{ID: "login", Title: "Login with tests", From: []string{"1/login", "2/login", "1/login", "3/none"}},
+			{ID: "empty", Title: "Nothing", From: []string{"9/none"}},
+		}}},
+	}
+
+	merged := applyMergePlan(plan, batches)
+
+	if merged.Title != "Add login" {
+		t.Errorf("expected the plan's title, got %q", merged.Title)
+	}
+	var got []string
+	for _, ch := range merged.Chapters {
+		for _, s := range ch.Sections {
+			var ids []string
+			for _, h := range s.Hunks {
+				ids = append(ids, h.ID)
+			}
+			got = append(got, ch.ID+"/"+s.ID+": "+strings.Join(ids, ","))
+		}
+	}
+	expected := []string{
+		"authentication/login: a.go::1,a_test.go::1",
+		// The section the plan left out keeps its batch chapter
+		"1/auth/1/docs: a.md::1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected sections %v, got %v", expected, got)
+	}
+}
+
+func TestCombineResponses(t *testing.T) {
+	combined := combineResponses(
+		LLMResponse{Title: "First", Chapters: []LLMChapter{{ID: "auth", Sections: []LLMSection{
+			{ID: "login", Hunks: []LLMHunkRef{{ID: "a.go::1"}}},
+		}}}},
+		LLMResponse{Title: "Second", Chapters: []LLMChapter{
+			{ID: "auth", Sections: []LLMSection{{ID: "logout", Hunks: []LLMHunkRef{{ID: "a.go::9"}}}}},
+			{ID: "other", Sections: []LLMSection{{ID: "login", Hunks: []LLMHunkRef{{ID: "b.go::1"}}}}},
+		}},
+	)
+
+	if combined.Title != "First" {
+		t.Errorf("expected the first title, got %q", combined.Title)
+	}
+	if len(combined.Chapters) != 1 || len(combined.Chapters[0].Sections) != 2 {
+		t.Fatalf("expected one chapter with two sections, got %+v", combined.Chapters)
+	}
+	if hunks := combined.Chapters[0].Sections[0].Hunks; len(hunks) != 2 || hunks[1].ID != "b.go::1" {
+		t.Errorf("expected sections with the same ID combined, got %+v", hunks)
+	}
+}
+
+func TestMergeBatches_FallbackKeepsBatchesApart(t *testing.T) {
+	// Both batches call their section "section-1"; the merge pass fails
+	batches := []LLMResponse{
+		{Title: "One", Chapters: []LLMChapter{{ID: "chapter-1", Title: "Auth", Sections: []LLMSection{
+			{ID: "section-1", Title: "Login", What: "login", Hunks: []LLMHunkRef{{ID: "a.go::1"}}},
+		}}}},
+		{Title: "Two", Chapters: []LLMChapter{{ID: "chapter-1", Title: "Docs", Sections: []LLMSection{
+			{ID: "section-1", Title: "Readme", What: "readme", Hunks: []LLMHunkRef{{ID: "README.md::1"}}},
+		}}}},
+	}
+	caller := llmCaller{ctx: context.Background(), provider: llm.Command{Args: []string{"false"}}}
+
+	merged := mergeBatches(caller, batches, "")
+
+	var got []string
+	for _, ch := range merged.Chapters {
+		for _, s := range ch.Sections {
+			got = append(got, fmt.Sprintf("%s %s/%s %s: %d hunks", ch.Title, ch.ID, s.ID, s.Title, len(s.Hunks)))
+		}
+	}
+	expected := []string{
+		"Auth 1/chapter-1/1/section-1 Login: 1 hunks",
+		"Docs 2/chapter-1/2/section-1 Readme: 1 hunks",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected sections %v, got %v", expected, got)
+	}
+}
+
+func TestGenerateReviewCmd_ClassifiesLargeDiffsInBatches(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+
+	patch := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-a\n+b\n"
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(patch), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Answers each batch, then the merge pass, by what the prompt asks for
+	script := `case "$1" in
+*"Batch 1 Of 2"*) echo '{"title":"A","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"A","what":"w","why":"y","hunks":[{"id":"a.go::1","importance":"high"}]}]}]}' ;;
+*"Batch 2 Of 2"*) echo '{"title":"B","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"B","what":"w","why":"y","hunks":[{"id":"b.go::1","importance":"low"}]}]}]}' ;;
+*) echo '{"title":"Merged","chapters":[{"id":"m","title":"M","sections":[{"id":"both","title":"Both","what":"w","why":"y","from":["1/s","2/s"]}]}]}' ;;
+esac`
+	params := GenerateParams{
+		DiffCommand:   []string{"cat", "changes.patch"},
+		DiffSource:    "Patch",
+		LLM:           llm.Command{Args: []string{"sh", "-c", script, "llm"}, Delivery: llm.DeliverArgument},
+		MaxBatchBytes: 150,
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	if _, ok := msg.(GenerateSuccessMsg); !ok {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+
+	stored := storedReview(store, workDir, "", "Patch")
+	if stored == nil {
+		t.Fatal("expected the review to be stored")
+	}
+	if stored.Title != "Merged" || len(stored.Chapters) != 1 || len(stored.Chapters[0].Sections) != 1 {
+		t.Fatalf("expected one merged section, got %+v", stored)
+	}
+	if hunks := stored.Chapters[0].Sections[0].Hunks; len(hunks) != 2 {
+		t.Errorf("expected both batches' hunks in the merged section, got %+v", hunks)
+	}
+}
+
+func TestGenerateReviewCmd_BatchedClassificationIsValidatedAcrossTheDiff(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+
+	patch := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-a\n+b\n"
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(patch), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every batch classifies a.go, so b.go is missing; the merge pass fails
+	script := `case "$1" in
+*"Batch "*) echo '{"title":"A","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"A","what":"w","why":"y","hunks":[{"id":"a.go::1","importance":"high"}]}]}]}' ;;
+*) exit 1 ;;
+esac`
+	params := GenerateParams{
+		DiffCommand:   []string{"cat", "changes.patch"},
+		DiffSource:    "Patch",
+		LLM:           llm.Command{Args: []string{"sh", "-c", script, "llm"}, Delivery: llm.DeliverArgument},
+		MaxBatchBytes: 150,
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	failed, ok := msg.(GenerateValidationFailedMsg)
+	if !ok {
+		t.Fatalf("expected GenerateValidationFailedMsg, got %#v", msg)
+	}
+	if !reflect.DeepEqual(failed.Missing, []string{"b.go::1"}) {
+		t.Errorf("expected b.go::1 missing, got %v", failed.Missing)
+	}
+}
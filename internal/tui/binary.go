@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// addBinarySizes looks up the sizes of binary files before and after the
+// change. Sizes git cannot provide are left at zero.
+func addBinarySizes(ctx context.Context, workDir string, hunks []diff.ParsedHunk) {
+	var repoRoot string
+	rootResolved := false
+	for i := range hunks {
+		h := &hunks[i]
+		if !h.Binary {
+			continue
+		}
+		h.OldSize = blobSize(ctx, workDir, h.OldBlob)
+		h.NewSize = blobSize(ctx, workDir, h.NewBlob)
+		if h.NewSize == 0 && !isNullBlob(h.NewBlob) {
+			// Working tree contents are hashed for the diff but not stored in
+			// the object database, so read the file itself
+			if !rootResolved {
+				rootResolved = true
+				if output, err := runCommand(ctx, workDir, []string{"git", "rev-parse", "--show-toplevel"}, nil); err == nil {
+					repoRoot = strings.TrimSpace(output)
+				}
+			}
+			if repoRoot != "" {
+				if info, err := os.Stat(filepath.Join(repoRoot, h.File)); err == nil {
+					h.NewSize = info.Size()
+				}
+			}
+		}
+	}
+}
+
+// isNullBlob reports whether blob is absent or git's all-zero hash, which
+// stands for the missing side of an added or deleted file
+func isNullBlob(blob string) bool {
+	return strings.Trim(blob, "0") == ""
+}
+
+// blobSize returns the size of a git blob, or 0 if it cannot be read
+func blobSize(ctx context.Context, workDir, blob string) int64 {
+	if isNullBlob(blob) {
+		return 0
+	}
+	output, err := runCommand(ctx, workDir, []string{"git", "cat-file", "-s", blob}, nil)
+	if err != nil {
+		return 0
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// isBinaryMarker reports whether a line of a binary hunk's diff is git's
+// note that the file is binary, as opposed to an extended header line
+func isBinaryMarker(line string) bool {
+	return line == "GIT binary patch" || (strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"))
+}
+
+// binarySummary describes a binary change for the diff panel
+func binarySummary(h model.Hunk) string {
+	switch {
+	case h.ChangeKind == model.ChangeAdded:
+		return "Binary file added" + sizeSuffix(h.NewSize)
+	case h.ChangeKind == model.ChangeDeleted:
+		return "Binary file deleted" + sizeSuffix(h.OldSize)
+	case h.OldSize > 0 && h.NewSize > 0:
+		return fmt.Sprintf("Binary file changed: %s → %s (%s)", formatSize(h.OldSize), formatSize(h.NewSize), formatSizeDelta(h.NewSize-h.OldSize))
+	default:
+		return "Binary file changed"
+	}
+}
+
+func sizeSuffix(size int64) string {
+	if size <= 0 {
+		return ""
+	}
+	return " (" + formatSize(size) + ")"
+}
+
+// formatSize renders a byte count for people, e.g. "512 B" or "1.5 KB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit || suffix == "GB" {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return ""
+}
+
+// formatSizeDelta renders a signed change in size
+func formatSizeDelta(delta int64) string {
+	switch {
+	case delta > 0:
+		return "+" + formatSize(delta)
+	case delta < 0:
+		return "-" + formatSize(-delta)
+	default:
+		return "same size"
+	}
+}
@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1536, "1.5 KB"},
+		{5 * 1024 * 1024, "5.0 MB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GB"},
+		{4096 * 1024 * 1024 * 1024, "4096.0 GB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.size); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestBinarySummary(t *testing.T) {
+	tests := []struct {
+		name string
+		hunk model.Hunk
+		want string
+	}{
+		{"added", model.Hunk{ChangeKind: model.ChangeAdded, NewSize: 2048}, "Binary file added (2.0 KB)"},
+		{"deleted", model.Hunk{ChangeKind: model.ChangeDeleted, OldSize: 100}, "Binary file deleted (100 B)"},
+		{"grown", model.Hunk{OldSize: 1024, NewSize: 3072}, "Binary file changed: 1.0 KB → 3.0 KB (+2.0 KB)"},
+		{"shrunk", model.Hunk{OldSize: 300, NewSize: 100}, "Binary file changed: 300 B → 100 B (-200 B)"},
+		{"unknown sizes", model.Hunk{}, "Binary file changed"},
+		{"added unknown size", model.Hunk{ChangeKind: model.ChangeAdded}, "Binary file added"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.hunk.Binary = true
+			if got := binarySummary(tt.hunk); got != tt.want {
+				t.Errorf("binarySummary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddBinarySizes_ReadsBlobsAndWorkingTree(t *testing.T) {
+	dir := setupTempGitRepo(t)
+	commitFile(t, dir, "image.bin", "\x00\x01\x02\x03")
+	if err := os.WriteFile(filepath.Join(dir, "image.bin"), []byte("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09"), 0644); err != nil {
+		t.Fatalf("failed to modify file: %v", err)
+	}
+	ctx := context.Background()
+
+	output, err := runCommand(ctx, dir, []string{"git", "diff", "HEAD", "--no-color"}, nil)
+	if err != nil {
+		t.Fatalf("git diff failed: %v", err)
+	}
+	hunks, err := diff.Parse(output)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(hunks) != 1 || !hunks[0].Binary {
+		t.Fatalf("expected one binary hunk, got %+v", hunks)
+	}
+
+	addBinarySizes(ctx, dir, hunks)
+
+	if hunks[0].OldSize != 4 {
+		t.Errorf("OldSize = %d, want 4 (from the committed blob)", hunks[0].OldSize)
+	}
+	if hunks[0].NewSize != 10 {
+		t.Errorf("NewSize = %d, want 10 (from the working tree)", hunks[0].NewSize)
+	}
+}
+
+func TestAddBinarySizes_IgnoresTextAndNullBlobs(t *testing.T) {
+	hunks := []diff.ParsedHunk{
+		{File: "a.go", Diff: "@@ -1 +1 @@\n-a\n+b", OldBlob: "1234567", NewBlob: "89abcde"},
+		{File: "gone.bin", Binary: true, ChangeKind: model.ChangeDeleted, OldBlob: "0000000", NewBlob: "0000000"},
+	}
+
+	addBinarySizes(context.Background(), t.TempDir(), hunks)
+
+	for _, h := range hunks {
+		if h.OldSize != 0 || h.NewSize != 0 {
+			t.Errorf("expected no sizes for %s, got %d/%d", h.File, h.OldSize, h.NewSize)
+		}
+	}
+}
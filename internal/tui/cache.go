@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/prompt"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+// classificationCacheVersion changes when the way classifications are made
+// changes enough that cached ones should not be reused
+const classificationCacheVersion = "classification-1"
+
+// classificationCacheKey identifies a classification by everything that
+// decides it: the hunks, the LLM, the rendered prompt (template, schema,
+// context and diff source), the outline of an incremental update, the
+// two-pass instructions and the batch size. The prompt is rendered without
+// the hunks' location, which differs between runs.
+func classificationCacheKey(prompts *prompt.Templates, params GenerateParams, hunks []diff.ParsedHunk, schemaJSON, addendum string) (string, error) {
+	hunksJSON, err := buildHunksJSON(hunks)
+	if err != nil {
+		return "", err
+	}
+	classification, err := prompts.Classification(prompt.ClassificationData{
+		Schema:     schemaJSON,
+		Context:    params.Context,
+		DiffSource: params.DiffSource,
+	})
+	if err != nil {
+		return "", err
+	}
+	return storage.LLMCacheKey(
+		classificationCacheVersion,
+		llm.Identity(params.LLM),
+		hunksJSON,
+		classification+addendum,
+		strconv.Itoa(params.MaxBatchBytes),
+	), nil
+}
+
+// cacheClassification stores a complete classification under key
+func cacheClassification(store *storage.Store, key string, response LLMResponse, maxBytes int64) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return store.CacheLLMResponse(key, data, maxBytes)
+}
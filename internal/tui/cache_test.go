@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestGenerateReviewCmd_CachesClassifications(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(classifyAPatch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	calls := filepath.Join(t.TempDir(), "calls")
+
+	// Counts requests in the file named by the first argument
+	script := `echo x >> "$1"; echo '` + classifyA + `'`
+	params := GenerateParams{
+		DiffCommand:   []string{"cat", "changes.patch"},
+		DiffSource:    "Patch",
+		LLM:           llm.Command{Args: []string{"sh", "-c", script, "llm", calls}, Delivery: llm.DeliverArgument},
+		CacheMaxBytes: 100000,
+	}
+	generate := func(params GenerateParams) GenerateSuccessMsg {
+		t.Helper()
+		msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+		success, ok := msg.(GenerateSuccessMsg)
+		if !ok {
+			t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+		}
+		return success
+	}
+
+	if generate(params).Cached {
+		t.Error("expected the first generation to ask the LLM")
+	}
+	if !generate(params).Cached || countLines(t, calls) != 1 {
+		t.Errorf("expected the same generation to use the cache, got %d requests", countLines(t, calls))
+	}
+	if storedReview(store, workDir, "", "Patch") == nil {
+		t.Error("expected a cached generation to store the review")
+	}
+
+	withContext := params
+	withContext.Context = "Focus on the API"
+	if generate(withContext).Cached {
+		t.Error("expected different context to miss the cache")
+	}
+
+	forced := params
+	forced.Force = true
+	if generate(forced).Cached {
+		t.Error("expected a forced generation to ask the LLM")
+	}
+
+	disabled := params
+	disabled.CacheMaxBytes = 0
+	if generate(disabled).Cached {
+		t.Error("expected no caching when the cache is disabled")
+	}
+	if n := countLines(t, calls); n != 4 {
+		t.Errorf("expected 4 requests, got %d", n)
+	}
+}
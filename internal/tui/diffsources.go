@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/mchowning/diffstory/internal/config"
 )
 
 // DiffSource represents a source of diff content for review generation
@@ -28,6 +30,43 @@ func DefaultDiffSources(baseBranch string) []DiffSource {
 	}
 }
 
+// CommitDiffSource returns the diff source of the changes made by a single
+// commit
+func CommitDiffSource(ref string) DiffSource {
+	return DiffSource{
+		Label:   fmt.Sprintf("Commit: %s", ref),
+		Command: []string{"git", "show", ref, "--no-color", "--no-ext-diff", "--format="},
+	}
+}
+
+// RangeDiffSource returns the diff source of the changes between two commits
+func RangeDiffSource(start, end string) DiffSource {
+	return DiffSource{
+		Label:   fmt.Sprintf("Range: %s..%s", start, end),
+		Command: []string{"git", "diff", start + ".." + end, "--no-color", "--no-ext-diff"},
+	}
+}
+
+// withConfiguredDiffSources adds the diffSources from config to sources,
+// before the entries that ask for a commit
+func withConfiguredDiffSources(sources []DiffSource, configured []config.DiffSource) []DiffSource {
+	if len(configured) == 0 {
+		return sources
+	}
+	at := len(sources)
+	for i, source := range sources {
+		if source.NeedsCommit || source.NeedsCommitRange {
+			at = i
+			break
+		}
+	}
+	result := append([]DiffSource{}, sources[:at]...)
+	for _, c := range configured {
+		result = append(result, DiffSource{Label: c.Label, CommandHint: strings.Join(c.Command, " "), Command: c.Command})
+	}
+	return append(result, sources[at:]...)
+}
+
 // gitRunner executes a git command in a directory and returns stdout.
 type gitRunner func(workDir string, args ...string) (string, error)
 
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+
+	"github.com/mchowning/diffstory/internal/config"
 )
 
 func TestDefaultDiffSources_HasExpectedCount(t *testing.T) {
@@ -195,3 +197,21 @@ func TestDetectBaseBranch_FallsBackToMainWhenNothingFound(t *testing.T) {
 		t.Errorf("expected fallback 'main', got %q", branch)
 	}
 }
+
+func TestWithConfiguredDiffSources_AddsBeforeCommitEntries(t *testing.T) {
+	sources := withConfiguredDiffSources(DefaultDiffSources("main"), []config.DiffSource{
+		{Label: "Jujutsu change", Command: []string{"jj", "diff", "--git"}},
+	})
+
+	var labels []string
+	for _, source := range sources {
+		labels = append(labels, source.Label)
+	}
+	expected := "Uncommitted changes,Staged changes,Changes since main,Jujutsu change,Specific commit...,Commit range..."
+	if got := strings.Join(labels, ","); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if sources[3].CommandHint != "jj diff --git" {
+		t.Errorf("expected the command as the hint, got %q", sources[3].CommandHint)
+	}
+}
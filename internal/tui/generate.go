@@ -15,98 +15,67 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/kaptinlin/jsonrepair"
 	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
 	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/prompt"
 	"github.com/mchowning/diffstory/internal/storage"
 )
 
-const classificationPromptTemplate = `You are a code review assistant. Classify diff hunks into logical chapters and sections.
-
-IMPORTANT: You MUST classify ALL hunks. Every hunk ID must appear exactly once in your response.
-
-Read the input hunks from this JSON file: %s
-
-The file contains a JSON array of hunk objects with fields: id, file, startLine, diff.
-
-Respond with JSON in this exact format (no markdown fences, no explanation text):
-{
-  "title": "Brief title for this review",
-  "chapters": [
-    {
-      "id": "chapter-identifier",
-      "title": "Short chapter title",
-      "sections": [
-        {
-          "id": "section-identifier",
-          "title": "Short section title",
-          "what": "Brief description of WHAT changed in this section",
-          "why": "Brief explanation of WHY this change was made",
-          "hunks": [
-            {"id": "file/path.go::45", "importance": "high", "isTest": false},
-            {"id": "file/path_test.go::120", "importance": "medium", "isTest": true}
-          ]
-        }
-      ]
-    }
-  ]
-}
-
-## Grouping Philosophy
-
-Group hunks by FUNCTIONAL PURPOSE, not by file path. Hunks that work together to achieve a goal belong in the same section, even if they span multiple files.
-
-DO NOT:
-- Group by file path. "Changes to auth.go" is never a good chapter title.
-- Separate documentation into its own chapter. Docs belong with their related code.
-
-## Format Requirements
-
-- Each chapter contains one or more sections.
-- Chapter title: ~20-30 characters, describes the theme (e.g., "Authentication", "Database Schema").
-- Section title: ~30-40 characters, describes the specific change (e.g., "Add login endpoint handler").
-- what: 1 sentence describing the change (e.g., "Added rate limiting to login endpoint")
-- why: 1 sentence explaining the reasoning (e.g., "Prevents brute-force attacks by limiting failed attempts")
-- Each hunk must have importance: "high", "medium", or "low"
-  - high: Critical changes (security, core logic, breaking changes)
-  - medium: Important changes (new features, significant refactors)
-  - low: Minor changes (formatting, comments, trivial fixes)
-- Each hunk must have isTest: true if the hunk is test code, false if production code
-  - Test code includes: unit tests, integration tests, test fixtures, test utilities, mocks
-
-Example (good):
-  "what": "Added rate limiting to login endpoint"
-  "why": "Prevents brute-force attacks by limiting failed attempts to 5 per minute per IP"
-
-Example (bad):
-  "what": "Changed auth.go"  <- Too vague, doesn't describe the actual change
-  "why": "Needed to update it" <- Doesn't explain the reasoning
-%s`
-
-const retryPromptAddendum = `
-
-CRITICAL: The previous response was incomplete. These hunk IDs were missing:
-%s
-
-You MUST include ALL hunk IDs in your response, including the ones listed above.`
+// How the prompt points the LLM at its input hunks: in a file, or in the
+// prompt itself for HTTP APIs and commands that cannot read files
+const (
+	fileInputTemplate   = "Read the input hunks from this JSON file: %s"
+	inlineInputTemplate = "The input hunks are:\n%s"
+)
 
 // GenerateParams holds parameters for review generation
 type GenerateParams struct {
-	DiffCommand []string
-	LLMCommand  []string // Resolved LLM command to use
-	Context     string
-	IsRetry     bool
-	MissingIDs  []string
-	ParsedHunks []diff.ParsedHunk // Set on retry to avoid re-parsing
+	DiffCommand          []string
+	DiffSource           string       // Label of the selected diff source, recorded in the review
+	LLM                  llm.Provider // Resolved LLM to use
+	Context              string
+	Repair               *LLMResponse             // Set on retry: a classification to repair instead of classifying anew
+	ParsedHunks          []diff.ParsedHunk        // Set on retry to avoid re-parsing
+	Provenance           *model.Provenance        // Set on retry alongside ParsedHunks
+	Incremental          bool                     // Keep the stored review's classification, placing only new hunks
+	MaxBatchBytes        int                      // Size budget of one classification request; larger diffs are batched
+	MaxRepairAttempts    int                      // Times to ask the LLM to place hunks a classification left out
+	Prompts              *prompt.Templates        // Prompt templates; nil uses the built-in ones
+	Exclude              []string                 // Glob patterns of files left out of the review
+	CacheMaxBytes        int64                    // Size limit of the LLM cache; 0 disables it
+	Force                bool                     // Ask the LLM even if the classification is cached
+	TwoPass              bool                     // Classify into an outline, then write each section's narrative separately
+	NarrativeConcurrency int                      // Narrative requests to run at once in two-pass generation
+	Record               *GenerationRecord        // Set on retry: the record of the generation being retried
+	Output               func(model.Review) error // Receives the review instead of the store, when set
+	Progress             func(string)             // Told of each step, for generation outside the viewer
+}
+
+// Generate runs review generation outside the viewer, as generateReviewCmd
+// does in it, and returns the outcome: a GenerateSuccessMsg,
+// GenerateErrorMsg, GenerateCancelledMsg or GenerateValidationFailedMsg
+func Generate(ctx context.Context, workDir string, store *storage.Store, logger *slog.Logger, params GenerateParams) tea.Msg {
+	return generateReviewCmd(ctx, workDir, store, logger, params)()
 }
 
 // generateReviewCmd returns a command that runs the LLM generation with
 // deterministic diff parsing and classification validation.
 func generateReviewCmd(ctx context.Context, workDir string, store *storage.Store, logger *slog.Logger, params GenerateParams) tea.Cmd {
 	return func() tea.Msg {
+		progress := func(format string, args ...any) {
+			if params.Progress != nil {
+				params.Progress(fmt.Sprintf(format, args...))
+			}
+		}
+		started := time.Now()
+		record := newGenerationRecord(params.Record, params.LLM)
 		var parsedHunks []diff.ParsedHunk
+		var provenance *model.Provenance
 
 		// Use cached hunks on retry, otherwise parse fresh
-		if params.IsRetry && len(params.ParsedHunks) > 0 {
+		if params.Repair != nil && len(params.ParsedHunks) > 0 {
 			parsedHunks = params.ParsedHunks
+			provenance = params.Provenance
 			if logger != nil {
 				logger.Info("using cached hunks for retry", "count", len(parsedHunks))
 			}
@@ -115,6 +84,7 @@ func generateReviewCmd(ctx context.Context, workDir string, store *storage.Store
 			if logger != nil {
 				logger.Info("running diff command", "command", params.DiffCommand)
 			}
+			progress("Running %s", strings.Join(params.DiffCommand, " "))
 			diffOutput, err := runCommand(ctx, workDir, params.DiffCommand, nil)
 			if err != nil {
 				return GenerateErrorMsg{Err: fmt.Errorf("diff command failed: %w", err)}
@@ -135,97 +105,428 @@ func generateReviewCmd(ctx context.Context, workDir string, store *storage.Store
 			if logger != nil {
 				logger.Info("parsed diff into hunks", "count", len(parsedHunks))
 			}
+			if kept := diff.Exclude(parsedHunks, params.Exclude); len(kept) < len(parsedHunks) {
+				if len(kept) == 0 {
+					return GenerateErrorMsg{Err: fmt.Errorf("all %d hunks are in excluded files", len(parsedHunks))}
+				}
+				if logger != nil {
+					logger.Info("excluded hunks", "count", len(parsedHunks)-len(kept), "patterns", params.Exclude)
+				}
+				progress("Left out %d hunks in excluded files", len(parsedHunks)-len(kept))
+				parsedHunks = kept
+			}
+			progress("Found %d hunks", len(parsedHunks))
+			addBinarySizes(ctx, workDir, parsedHunks)
+			provenance = buildProvenance(ctx, workDir, params.DiffCommand, diffOutput)
 		}
 
-		// Step 3: Write hunks to file in working directory for LLM to read
-		hunksJSON, err := buildHunksJSON(parsedHunks)
-		if err != nil {
-			return GenerateErrorMsg{Err: fmt.Errorf("failed to build hunks JSON: %w", err)}
-		}
-		inputPath := filepath.Join(workDir, ".diffstory-input.json")
-		if err := os.WriteFile(inputPath, []byte(hunksJSON), 0600); err != nil {
-			return GenerateErrorMsg{Err: fmt.Errorf("failed to write input file: %w", err)}
+		branch := currentBranch(ctx, workDir)
+		cached := false
+		var plan *incrementalPlan
+		save := func(review model.Review) tea.Msg {
+			review.DiffSource = params.DiffSource
+			review.Branch = branch
+			review.Provenance = provenance
+			record.Generation.HunkCount = len(parsedHunks)
+			record.Generation.Cached = cached
+			record.Generation.Incremental = plan != nil
+			record.addElapsed(started)
+			// A review written to Output doesn't live in the store, so
+			// neither does its transcript
+			archive := store
+			if params.Output != nil {
+				archive = nil
+			}
+			review.Generation = record.finish(archive, workDir, logger)
+			if params.Output != nil {
+				if err := params.Output(review); err != nil {
+					return GenerateErrorMsg{Err: fmt.Errorf("failed to write review: %w", err)}
+				}
+				return GenerateSuccessMsg{Cached: cached}
+			}
+			// Keep what the reader did on the review this one replaces
+			if carried := carryOverStoredState(store, &review); carried > 0 && logger != nil {
+				logger.Info("carried reader state over from previous review", "count", carried)
+			}
+			if err := store.Write(review); err != nil {
+				return GenerateErrorMsg{Err: fmt.Errorf("failed to save review: %w", err)}
+			}
+			if path, err := store.PathForReview(review.WorkingDirectory, review.Branch, review.DiffSource); err == nil {
+				progress("Saved the review to %s", path)
+			}
+			return GenerateSuccessMsg{Cached: cached}
 		}
-		defer os.Remove(inputPath)
-		if logger != nil {
-			logger.Info("wrote hunks to input file", "path", inputPath, "bytes", len(hunksJSON))
+
+		// In incremental mode, only hunks the stored review lacks are classified
+		llmHunks := parsedHunks
+		if params.Incremental {
+			if previous := storedReview(store, workDir, branch, params.DiffSource); previous != nil {
+				p := planIncremental(*previous, parsedHunks)
+				plan = &p
+				if logger != nil {
+					logger.Info("regenerating incrementally", "kept", len(parsedHunks)-len(p.fresh), "fresh", len(p.fresh))
+				}
+				progress("Keeping the stored classification of %d hunks", len(parsedHunks)-len(p.fresh))
+				if len(p.fresh) == 0 {
+					return save(p.assemble(workDir, nil, nil))
+				}
+				llmHunks = p.fresh
+			} else if logger != nil {
+				logger.Info("no stored review to update, generating in full")
+			}
 		}
 
-		// Step 4: Build LLM prompt
+		// Step 3: Build the parts of the LLM prompt shared by every batch
+		prompts := params.Prompts
+		if prompts == nil {
+			prompts = prompt.Default()
+		}
 		contextAddendum := ""
 		if params.Context != "" {
 			contextAddendum = fmt.Sprintf("\nUser context: %s", params.Context)
 		}
 
-		prompt := fmt.Sprintf(classificationPromptTemplate, inputPath, contextAddendum)
+		schemaJSON, err := json.Marshal(ClassificationSchema())
+		if err != nil {
+			return GenerateErrorMsg{Err: fmt.Errorf("failed to build response schema: %w", err)}
+		}
 
-		// Add retry addendum if this is a retry
-		if params.IsRetry && len(params.MissingIDs) > 0 {
-			prompt += fmt.Sprintf(retryPromptAddendum, strings.Join(params.MissingIDs, ", "))
+		sharedAddendum := ""
+		if plan != nil {
+			outline, err := plan.outlineJSON()
+			if err != nil {
+				return GenerateErrorMsg{Err: err}
+			}
+			sharedAddendum = fmt.Sprintf(incrementalPromptAddendum, outline)
+		}
+		if params.TwoPass {
+			sharedAddendum += outlinePromptAddendum
 		}
 
-		// Step 5: Call LLM
-		if ctx.Err() != nil {
-			return GenerateCancelledMsg{}
+		// Step 4: Classify the hunks, in batches when they exceed the size budget
+		caller := llmCaller{ctx: ctx, workDir: workDir, provider: params.LLM, logger: logger, record: record}
+		// Hunks go to the LLM in a file, outside the working tree, or
+		// in the prompt
+		var inputDir string
+		if llm.ReadsInputFile(params.LLM) {
+			dir, err := os.MkdirTemp("", "diffstory-")
+			if err != nil {
+				return GenerateErrorMsg{Err: fmt.Errorf("failed to create input directory: %w", err)}
+			}
+			defer os.RemoveAll(dir)
+			inputDir = dir
 		}
+		// classify sends one classification request for hunks, with addendum
+		// following the prompt, recorded in the transcript as purpose
+		classify := func(hunks []diff.ParsedHunk, addendum, purpose string) (*LLMResponse, error) {
+			hunksJSON, err := buildHunksJSON(hunks)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build hunks JSON: %w", err)
+			}
+			data := prompt.ClassificationData{
+				Input:      fmt.Sprintf(inlineInputTemplate, hunksJSON),
+				Schema:     string(schemaJSON),
+				Context:    params.Context,
+				DiffSource: params.DiffSource,
+			}
+			input := ""
+			if inputDir != "" {
+				data.InputFile, err = writeHunksInput(inputDir, "hunks.json", hunksJSON, logger)
+				if err != nil {
+					return nil, err
+				}
+				data.Input = fmt.Sprintf(fileInputTemplate, data.InputFile)
+				input = hunksJSON
+			}
+			classification, err := prompts.Classification(data)
+			if err != nil {
+				return nil, err
+			}
+			output, err := caller.call(purpose, classification+addendum, input, string(schemaJSON))
+			if err != nil {
+				return nil, err
+			}
 
-		llmArgs := append([]string{}, params.LLMCommand[1:]...)
-		llmArgs = append(llmArgs, prompt)
-		llmCmd := append([]string{params.LLMCommand[0]}, llmArgs...)
-		if logger != nil {
-			logger.Info("calling LLM", "fullCommand", llmCmd, "prompt", prompt)
+			// Parse LLM response
+			response, repaired, err := extractLLMResponse(output, logger)
+			if err != nil {
+				if logger != nil {
+					logger.Error("LLM response parse failed", "output", output, "error", err)
+				}
+				return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+			}
+			if repaired {
+				record.markJSONRepaired()
+			}
+			return response, nil
 		}
-		output, err := runCommand(ctx, workDir, llmCmd, nil)
-		if err != nil {
-			if ctx.Err() != nil {
-				return GenerateCancelledMsg{}
+
+		// Identical generations reuse the classification cached before
+		var cacheKey string
+		if params.CacheMaxBytes > 0 {
+			cacheKey, err = classificationCacheKey(prompts, params, llmHunks, string(schemaJSON), sharedAddendum)
+			if err != nil {
+				return GenerateErrorMsg{Err: err}
 			}
-			return GenerateErrorMsg{Err: fmt.Errorf("LLM failed: %w", err)}
 		}
-		if logger != nil {
-			logger.Info("LLM returned", "outputLength", len(output))
+		response := params.Repair
+		if response == nil && cacheKey != "" && !params.Force {
+			if data, ok := store.CachedLLMResponse(cacheKey); ok {
+				var hit LLMResponse
+				if err := json.Unmarshal(data, &hit); err == nil {
+					if logger != nil {
+						logger.Info("using cached classification", "key", cacheKey)
+					}
+					response = &hit
+					cached = true
+					progress("Using the cached classification of these hunks")
+				}
+			}
 		}
+		if response == nil {
+			batches := batchHunks(llmHunks, params.MaxBatchBytes)
+			if len(batches) > 1 && logger != nil {
+				logger.Info("classifying hunks in batches", "batches", len(batches), "budget", params.MaxBatchBytes)
+			}
+			record.Generation.Batches = len(batches)
+			responses := make([]LLMResponse, 0, len(batches))
+			for i, batch := range batches {
+				addendum, purpose := sharedAddendum, "classification"
+				if len(batches) > 1 {
+					addendum += fmt.Sprintf(batchPromptAddendum, i+1, len(batches), len(batches))
+					purpose = fmt.Sprintf("classification, batch %d of %d", i+1, len(batches))
+					progress("Classifying batch %d of %d (%d hunks)", i+1, len(batches), len(batch))
+				} else {
+					progress("Classifying %d hunks", len(batch))
+				}
+				batchResponse, err := classify(batch, addendum, purpose)
+				if err != nil {
+					if ctx.Err() != nil {
+						return GenerateCancelledMsg{}
+					}
+					return GenerateErrorMsg{Err: err}
+				}
+				responses = append(responses, *batchResponse)
+			}
 
-		// Step 6: Parse LLM response
-		response, err := extractLLMResponse(output, logger)
-		if err != nil {
-			if logger != nil {
-				logger.Error("LLM response parse failed", "output", output, "error", err)
+			// Step 5: Merge the batches into one story. Incremental batches place
+			// hunks into the existing sections by ID, so they are combined as is.
+			response = &responses[0]
+			if len(responses) > 1 {
+				var merged LLMResponse
+				if plan != nil {
+					merged = combineResponses(responses...)
+				} else {
+					progress("Merging %d batches into one story", len(responses))
+					merged = mergeBatches(caller, responses, contextAddendum)
+					if ctx.Err() != nil {
+						return GenerateCancelledMsg{}
+					}
+				}
+				response = &merged
 			}
-			return GenerateErrorMsg{Err: fmt.Errorf("failed to parse LLM response: %w", err)}
 		}
 
-		// Step 7: Validate classification
-		validation := validateClassification(parsedHunks, *response)
-		if !validation.Valid {
-			if params.IsRetry {
-				// Second failure - return for user decision
-				return GenerateValidationFailedMsg{
-					Hunks:      parsedHunks,
-					Missing:    validation.MissingIDs,
-					Duplicates: validation.DuplicateIDs,
-					Invalid:    validation.InvalidImportance,
-					Response:   response,
+		// Step 6: Repair the classification across the whole diff. Duplicates
+		// and unknown importance are settled without the LLM; hunks left out
+		// are sent back to it, to be placed in the story so far.
+		repaired := settleClassification(*response, llmHunks)
+		validation := validateClassification(llmHunks, repaired)
+		for attempt := 1; !validation.Valid && attempt <= params.MaxRepairAttempts; attempt++ {
+			if logger != nil {
+				logger.Info("repairing classification", "attempt", attempt, "missing", len(validation.MissingIDs))
+			}
+			progress("Placing %d hunks the classification left out (attempt %d of %d)", len(validation.MissingIDs), attempt, params.MaxRepairAttempts)
+			record.Generation.RepairAttempts++
+			for _, batch := range batchHunks(missingHunks(llmHunks, validation.MissingIDs), params.MaxBatchBytes) {
+				outline, err := responseOutlineJSON(repaired)
+				if err != nil {
+					return GenerateErrorMsg{Err: err}
+				}
+				ids := make([]string, len(batch))
+				for i, h := range batch {
+					ids[i] = h.ID
+				}
+				retry, err := prompts.Retry(prompt.RetryData{MissingIDs: ids, Outline: outline, DiffSource: params.DiffSource})
+				if err != nil {
+					return GenerateErrorMsg{Err: err}
+				}
+				placement, err := classify(batch, sharedAddendum+retry, fmt.Sprintf("repair, attempt %d", record.Generation.RepairAttempts))
+				if err != nil {
+					if ctx.Err() != nil {
+						return GenerateCancelledMsg{}
+					}
+					// The batch's hunks stay missing for the next attempt
+					if logger != nil {
+						logger.Warn("repair request failed", "attempt", attempt, "error", err)
+					}
+					continue
 				}
+				repaired = settleClassification(combineResponses(repaired, *placement), llmHunks)
 			}
-			// First failure - auto retry
-			return GenerateNeedsRetryMsg{
+			validation = validateClassification(llmHunks, repaired)
+		}
+		if !validation.Valid {
+			// Out of attempts - return for user decision
+			record.Generation.HunkCount = len(parsedHunks)
+			record.Generation.Incremental = plan != nil
+			record.addElapsed(started)
+			return GenerateValidationFailedMsg{
 				Hunks:      parsedHunks,
-				MissingIDs: validation.MissingIDs,
-				Context:    params.Context,
+				Missing:    validation.MissingIDs,
+				Response:   &repaired,
+				Provenance: provenance,
+				Attempts:   params.MaxRepairAttempts,
+				Record:     record,
+			}
+		}
+		response = &repaired
+
+		// Step 7: In two-pass generation the classification is an outline;
+		// each section's what and why are written in a request of their own.
+		// A cached classification was narrated before it was cached.
+		narrated := true
+		if params.TwoPass && !cached {
+			record.Generation.TwoPass = true
+			narrate := func(LLMSection) bool { return true }
+			if plan != nil {
+				// Sections of the stored review keep their text
+				narrate = func(s LLMSection) bool {
+					_, _, ok := findSectionByID(plan.base.Chapters, s.ID)
+					return !ok
+				}
+			}
+			progress("Writing the narrative of each section, %d at a time", max(params.NarrativeConcurrency, 1))
+			count, attempted := narrateSections(caller, &repaired, llmHunks, inputDir, contextAddendum, params.NarrativeConcurrency, narrate)
+			if ctx.Err() != nil {
+				return GenerateCancelledMsg{}
+			}
+			record.Generation.Narrated += count
+			narrated = count == attempted
+		}
+		if cacheKey != "" && !cached && narrated {
+			if err := cacheClassification(store, cacheKey, repaired, params.CacheMaxBytes); err != nil && logger != nil {
+				logger.Warn("failed to cache classification", "error", err)
 			}
 		}
 
-		// Step 8: Assemble final review
-		review := assembleReview(workDir, response, parsedHunks)
+		// Step 8: Assemble final review and write it to storage
+		if plan != nil {
+			return save(plan.assemble(workDir, response, nil))
+		}
+		return save(assembleReview(workDir, response, parsedHunks))
+	}
+}
 
-		// Step 9: Write to storage
-		if err := store.Write(review); err != nil {
-			return GenerateErrorMsg{Err: fmt.Errorf("failed to save review: %w", err)}
+// carryOverStoredState carries reviewed marks and notes from the stored
+// review that review will replace, if any, matching hunks by fingerprint. It
+// returns the number carried over.
+func carryOverStoredState(store *storage.Store, review *model.Review) int {
+	previous := storedReview(store, review.WorkingDirectory, review.Branch, review.DiffSource)
+	if previous == nil {
+		return 0
+	}
+	return review.CarryOver(*previous)
+}
+
+// storedReview returns the stored review of workDir for branch and
+// diffSource, or nil if there is none
+func storedReview(store *storage.Store, workDir, branch, diffSource string) *model.Review {
+	path, err := store.PathForReview(workDir, branch, diffSource)
+	if err != nil {
+		return nil
+	}
+	previous, err := store.ReadPath(path)
+	if err != nil {
+		return nil
+	}
+	return previous
+}
+
+// llmCaller sends prompts to the resolved LLM for one generation
+type llmCaller struct {
+	ctx      context.Context
+	workDir  string
+	provider llm.Provider
+	logger   *slog.Logger
+	record   *GenerationRecord // Receives each exchange, when set
+}
+
+// call sends prompt, with the JSON Schema of the expected reply, and returns
+// the LLM's output. The exchange is recorded as purpose, along with input,
+// the hunks the LLM reads from a file, if any.
+func (c llmCaller) call(purpose, prompt, input, schemaJSON string) (string, error) {
+	if c.ctx.Err() != nil {
+		return "", c.ctx.Err()
+	}
+	if c.logger != nil {
+		c.logger.Info("calling LLM", "provider", c.provider.Name(), "prompt", prompt)
+	}
+	started := time.Now()
+	output, err := c.provider.Complete(c.ctx, llm.Request{Prompt: prompt, Schema: schemaJSON, Dir: c.workDir})
+	if c.record != nil {
+		exchange := model.Exchange{Purpose: purpose, Prompt: prompt, Input: input, Response: output, DurationMs: time.Since(started).Milliseconds()}
+		if err != nil {
+			exchange.Error = err.Error()
 		}
+		c.record.addExchange(exchange)
+	}
+	if err != nil {
+		return "", fmt.Errorf("LLM failed: %w", err)
+	}
+	if c.logger != nil {
+		c.logger.Info("LLM returned", "outputLength", len(output))
+	}
+	return output, nil
+}
 
-		return GenerateSuccessMsg{}
+// writeHunksInput writes the hunks JSON to the input file the LLM reads,
+// name in dir, a private temporary directory, and returns its path
+func writeHunksInput(dir, name, hunksJSON string, logger *slog.Logger) (string, error) {
+	inputPath := filepath.Join(dir, name)
+	if err := os.WriteFile(inputPath, []byte(hunksJSON), 0600); err != nil {
+		return "", fmt.Errorf("failed to write input file: %w", err)
 	}
+	if logger != nil {
+		logger.Info("wrote hunks to input file", "path", inputPath, "bytes", len(hunksJSON))
+	}
+	return inputPath, nil
+}
+
+// mergeBatches asks the LLM how to combine the batches' sections into one
+// story and applies its plan. If the merge pass fails, the batches' chapters
+// and sections are kept side by side instead, so a large diff still yields a
+// review.
+func mergeBatches(caller llmCaller, batches []LLMResponse, contextAddendum string) LLMResponse {
+	fallback := func(reason string, err error) LLMResponse {
+		if caller.logger != nil {
+			caller.logger.Warn("batch merge pass failed, combining batches as classified", "reason", reason, "error", err)
+		}
+		return combineResponses(qualifyBatches(batches)...)
+	}
+
+	outline, err := mergeOutlineJSON(batches)
+	if err != nil {
+		return fallback("outline", err)
+	}
+	schemaJSON, err := json.Marshal(mergeSchema())
+	if err != nil {
+		return fallback("schema", err)
+	}
+	mergePrompt := fmt.Sprintf(mergePromptTemplate, len(batches), outline, schemaJSON, contextAddendum)
+	output, err := caller.call("merge", mergePrompt, "", string(schemaJSON))
+	if err != nil {
+		return fallback("llm", err)
+	}
+	var plan mergeResponse
+	repaired, err := decodeLLMJSON(output, &plan, caller.logger)
+	if err != nil {
+		return fallback("parse", err)
+	}
+	if repaired && caller.record != nil {
+		caller.record.markJSONRepaired()
+	}
+	return applyMergePlan(plan, batches)
 }
 
 // buildHunksJSON creates a JSON representation of hunks for the LLM prompt
@@ -241,48 +542,96 @@ func buildHunksJSON(hunks []diff.ParsedHunk) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("failed to marshal diff for %s: %w", h.ID, err)
 		}
-		sb.WriteString(fmt.Sprintf(`  {"id": %q, "file": %q, "startLine": %d, "diff": %s}`,
-			h.ID, h.File, h.StartLine, string(diffBytes)))
+		sb.WriteString(fmt.Sprintf(`  {"id": %q, "file": %q`, h.ID, h.File))
+		if h.OldFile != "" {
+			sb.WriteString(fmt.Sprintf(`, "oldFile": %q`, h.OldFile))
+		}
+		if h.ChangeKind != "" {
+			sb.WriteString(fmt.Sprintf(`, "changeKind": %q`, h.ChangeKind))
+		}
+		if diff.Parents(h.Diff) > 1 {
+			sb.WriteString(`, "mergeResolution": true`)
+		}
+		if h.Binary {
+			sb.WriteString(`, "binary": true`)
+			if h.OldSize > 0 {
+				sb.WriteString(fmt.Sprintf(`, "oldSize": %d`, h.OldSize))
+			}
+			if h.NewSize > 0 {
+				sb.WriteString(fmt.Sprintf(`, "newSize": %d`, h.NewSize))
+			}
+		}
+		sb.WriteString(fmt.Sprintf(`, "startLine": %d, "diff": %s}`, h.StartLine, string(diffBytes)))
 	}
 	sb.WriteString("\n]")
 	return sb.String(), nil
 }
 
-// extractLLMResponse parses the LLM output to find JSON response
-func extractLLMResponse(output string, logger *slog.Logger) (*LLMResponse, error) {
+// extractLLMResponse parses the LLM output to find JSON response, reporting
+// whether it had to be repaired
+func extractLLMResponse(output string, logger *slog.Logger) (*LLMResponse, bool, error) {
+	var response LLMResponse
+	repaired, err := decodeLLMJSON(output, &response, logger)
+	if err != nil {
+		return nil, false, err
+	}
+	return &response, repaired, nil
+}
+
+// decodeLLMJSON decodes the first JSON object in the LLM output into v,
+// repairing malformed JSON when it can. It reports whether repair was needed.
+func decodeLLMJSON(output string, v any, logger *slog.Logger) (bool, error) {
 	// Find the first '{' character (LLM may include preamble)
 	start := strings.Index(output, "{")
 	if start == -1 {
-		return nil, fmt.Errorf("no JSON object found in response")
+		return false, fmt.Errorf("no JSON object found in response")
 	}
 
 	jsonStr := output[start:]
 
 	// Try parsing as-is first
 	decoder := json.NewDecoder(strings.NewReader(jsonStr))
-	var response LLMResponse
-	if err := decoder.Decode(&response); err != nil {
-		// Attempt repair
-		repaired, repairErr := jsonrepair.JSONRepair(jsonStr)
-		if repairErr != nil {
-			return nil, fmt.Errorf("failed to decode JSON: %w (repair also failed: %v)", err, repairErr)
-		}
+	err := decoder.Decode(v)
+	if err == nil {
+		return false, nil
+	}
 
-		// Try parsing repaired JSON
-		decoder = json.NewDecoder(strings.NewReader(repaired))
-		if err := decoder.Decode(&response); err != nil {
-			return nil, fmt.Errorf("failed to decode repaired JSON: %w", err)
-		}
+	// Attempt repair
+	repaired, repairErr := jsonrepair.JSONRepair(jsonStr)
+	if repairErr != nil {
+		return false, fmt.Errorf("failed to decode JSON: %w (repair also failed: %v)", err, repairErr)
+	}
 
-		// Log that repair was needed
-		if logger != nil {
-			logger.Info("JSON repair applied to LLM output",
-				"originalLength", len(jsonStr),
-				"repairedLength", len(repaired))
-		}
+	// Try parsing repaired JSON
+	decoder = json.NewDecoder(strings.NewReader(repaired))
+	if err := decoder.Decode(v); err != nil {
+		return false, fmt.Errorf("failed to decode repaired JSON: %w", err)
 	}
 
-	return &response, nil
+	// Log that repair was needed
+	if logger != nil {
+		logger.Info("JSON repair applied to LLM output",
+			"originalLength", len(jsonStr),
+			"repairedLength", len(repaired))
+	}
+	return true, nil
+}
+
+// modelHunk copies a parsed hunk into a review hunk, leaving the
+// classification to the caller
+func modelHunk(h diff.ParsedHunk) model.Hunk {
+	return model.Hunk{
+		ID:          h.ID,
+		Fingerprint: h.Fingerprint,
+		File:        h.File,
+		OldFile:     h.OldFile,
+		ChangeKind:  h.ChangeKind,
+		StartLine:   h.StartLine,
+		Diff:        h.Diff,
+		Binary:      h.Binary,
+		OldSize:     h.OldSize,
+		NewSize:     h.NewSize,
+	}
 }
 
 // assembleReview combines LLM classification with parsed hunk data
@@ -313,13 +662,10 @@ func assembleReview(workDir string, response *LLMResponse, hunks []diff.ParsedHu
 			}
 			for _, href := range s.Hunks {
 				if h, ok := hunkMap[href.ID]; ok {
-					section.Hunks = append(section.Hunks, model.Hunk{
-						File:       h.File,
-						StartLine:  h.StartLine,
-						Diff:       h.Diff,
-						Importance: model.NormalizeImportance(href.Importance),
-						IsTest:     href.IsTest,
-					})
+					hunk := modelHunk(h)
+					hunk.Importance = model.NormalizeImportance(href.Importance)
+					hunk.IsTest = href.IsTest
+					section.Hunks = append(section.Hunks, hunk)
 				}
 			}
 			chapter.Sections = append(chapter.Sections, section)
@@ -343,12 +689,9 @@ func assemblePartialReview(workDir string, response *LLMResponse, hunks []diff.P
 	var unclassifiedHunks []model.Hunk
 	for _, id := range missingIDs {
 		if h, ok := hunkMap[id]; ok {
-			unclassifiedHunks = append(unclassifiedHunks, model.Hunk{
-				File:       h.File,
-				StartLine:  h.StartLine,
-				Diff:       h.Diff,
-				Importance: model.ImportanceMedium, // Default to medium
-			})
+			hunk := modelHunk(h)
+			hunk.Importance = model.ImportanceMedium // Default to medium
+			unclassifiedHunks = append(unclassifiedHunks, hunk)
 		}
 	}
 
@@ -385,6 +728,16 @@ func getUntrackedFiles(ctx context.Context, workDir string) ([]string, error) {
 	return strings.Split(output, "\n"), nil
 }
 
+// currentBranch returns the checked-out branch name, or "" when HEAD is
+// detached or workDir is not a git repository
+func currentBranch(ctx context.Context, workDir string) string {
+	output, err := runCommand(ctx, workDir, []string{"git", "symbolic-ref", "--short", "-q", "HEAD"}, nil)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
 func runCommand(ctx context.Context, workDir string, args []string, stdin []byte) (string, error) {
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	cmd.Dir = workDir
@@ -2,6 +2,10 @@ package tui
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,12 +14,16 @@ import (
 	"time"
 
 	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/prompt"
+	"github.com/mchowning/diffstory/internal/storage"
 )
 
 func TestExtractLLMResponse_CleanOutput(t *testing.T) {
 	input := `{"title": "Test Review", "chapters": []}`
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -27,7 +35,7 @@ func TestExtractLLMResponse_CleanOutput(t *testing.T) {
 func TestExtractLLMResponse_MarkdownFences(t *testing.T) {
 	input := "```json\n{\"title\": \"Fenced Review\", \"chapters\": []}\n```"
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -39,7 +47,7 @@ func TestExtractLLMResponse_MarkdownFences(t *testing.T) {
 func TestExtractLLMResponse_MarkdownFencesNoLang(t *testing.T) {
 	input := "```\n{\"title\": \"No Lang\", \"chapters\": []}\n```"
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -55,7 +63,7 @@ func TestExtractLLMResponse_SurroundingText(t *testing.T) {
 
 I hope this helps!`
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -83,7 +91,7 @@ func TestExtractLLMResponse_NestedBraces(t *testing.T) {
 		]
 	}`
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -98,7 +106,7 @@ func TestExtractLLMResponse_NestedBraces(t *testing.T) {
 func TestExtractLLMResponse_NoJSON(t *testing.T) {
 	input := "This is just plain text with no JSON at all."
 
-	_, err := extractLLMResponse(input, nil)
+	_, _, err := extractLLMResponse(input, nil)
 	if err == nil {
 		t.Fatal("expected error for missing JSON")
 	}
@@ -108,7 +116,7 @@ func TestExtractLLMResponse_UnclosedBrace(t *testing.T) {
 	// Missing closing brackets - should be repaired
 	input := `{"title": "Unclosed", "chapters": [`
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("expected repair to succeed, got: %v", err)
 	}
@@ -121,7 +129,7 @@ func TestExtractLLMResponse_InvalidJSON(t *testing.T) {
 	// Unquoted value - jsonrepair can fix this
 	input := `{"title": invalid}`
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("expected repair to succeed, got: %v", err)
 	}
@@ -150,7 +158,7 @@ func TestExtractLLMResponse_UnbalancedBracesInStringValues(t *testing.T) {
 		]
 	}`
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -163,7 +171,7 @@ func TestExtractLLMResponse_TrailingComma(t *testing.T) {
 	// Trailing comma - should be repaired
 	input := `{"title": "Test", "chapters": [],}`
 
-	response, err := extractLLMResponse(input, nil)
+	response, _, err := extractLLMResponse(input, nil)
 	if err != nil {
 		t.Fatalf("expected repair to succeed, got: %v", err)
 	}
@@ -176,7 +184,7 @@ func TestExtractLLMResponse_Unrepairable(t *testing.T) {
 	// Completely malformed - should fail even after repair attempt
 	input := `not json at all {{{`
 
-	_, err := extractLLMResponse(input, nil)
+	_, _, err := extractLLMResponse(input, nil)
 	if err == nil {
 		t.Fatal("expected error for unrepairable JSON")
 	}
@@ -271,6 +279,10 @@ func TestAssembleReview_CombinesHunksWithClassification(t *testing.T) {
 	if sections[0].Hunks[0].Importance != "high" {
 		t.Errorf("expected importance 'high', got %q", sections[0].Hunks[0].Importance)
 	}
+	// Verify the hunk ID is carried over for notes to refer to
+	if sections[0].Hunks[1].ID != "file.go::50" {
+		t.Errorf("expected hunk ID 'file.go::50', got %q", sections[0].Hunks[1].ID)
+	}
 }
 
 func TestAssemblePartialReview_AddsUnclassifiedChapter(t *testing.T) {
@@ -599,3 +611,338 @@ func TestGetUntrackedFiles_ExcludesTrackedFiles(t *testing.T) {
 	}
 }
 
+
+func TestCurrentBranch_ReturnsCheckedOutBranch(t *testing.T) {
+	dir := setupTempGitRepo(t)
+
+	cmd := exec.Command("git", "checkout", "-b", "feature-x")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to create branch: %v", err)
+	}
+
+	if branch := currentBranch(context.Background(), dir); branch != "feature-x" {
+		t.Errorf("currentBranch() = %q, want %q", branch, "feature-x")
+	}
+}
+
+func TestCurrentBranch_EmptyOutsideGitRepo(t *testing.T) {
+	if branch := currentBranch(context.Background(), t.TempDir()); branch != "" {
+		t.Errorf("currentBranch() = %q, want empty", branch)
+	}
+}
+
+func TestBuildHunksJSON_IncludesFileChanges(t *testing.T) {
+	hunks := []diff.ParsedHunk{
+		{ID: "new.go::0", File: "new.go", OldFile: "old.go", ChangeKind: "renamed", Diff: "rename from old.go\nrename to new.go"},
+		{ID: "a.go::1", File: "a.go", StartLine: 1, Diff: "+a"},
+	}
+
+	result, err := buildHunksJSON(hunks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal([]byte(result), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got %v:\n%s", err, result)
+	}
+	if decoded[0]["oldFile"] != "old.go" || decoded[0]["changeKind"] != "renamed" {
+		t.Errorf("expected rename details on the first hunk, got %v", decoded[0])
+	}
+	if _, ok := decoded[1]["changeKind"]; ok {
+		t.Errorf("modified files should have no changeKind, got %v", decoded[1])
+	}
+}
+
+func TestAssembleReview_CopiesFileChanges(t *testing.T) {
+	hunks := []diff.ParsedHunk{
+		{ID: "new.go::0", File: "new.go", OldFile: "old.go", ChangeKind: "renamed", Diff: "rename from old.go\nrename to new.go"},
+	}
+	response := &LLMResponse{
+		Title: "Rename",
+		Chapters: []LLMChapter{{ID: "c", Title: "C", Sections: []LLMSection{{
+			ID: "s", Title: "S", Hunks: []LLMHunkRef{{ID: "new.go::0", Importance: "low"}},
+		}}}},
+	}
+
+	review := assembleReview("/test", response, hunks)
+
+	hunk := review.Chapters[0].Sections[0].Hunks[0]
+	if hunk.OldFile != "old.go" || hunk.ChangeKind != "renamed" {
+		t.Errorf("expected rename details to be copied, got %+v", hunk)
+	}
+	partial := assemblePartialReview("/test", &LLMResponse{Title: "Rename"}, hunks, []string{"new.go::0"})
+	if got := partial.Chapters[0].Sections[0].Hunks[0]; got.OldFile != "old.go" || got.ChangeKind != "renamed" {
+		t.Errorf("expected rename details on unclassified hunks, got %+v", got)
+	}
+}
+
+func TestBuildHunksJSON_IncludesBinarySizes(t *testing.T) {
+	hunks := []diff.ParsedHunk{
+		{ID: "logo.png::0", File: "logo.png", Binary: true, OldSize: 10, NewSize: 20, Diff: "Binary files a/logo.png and b/logo.png differ"},
+	}
+
+	result, err := buildHunksJSON(hunks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal([]byte(result), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got %v:\n%s", err, result)
+	}
+	if decoded[0]["binary"] != true || decoded[0]["oldSize"] != float64(10) || decoded[0]["newSize"] != float64(20) {
+		t.Errorf("expected binary details, got %v", decoded[0])
+	}
+}
+
+func TestBuildHunksJSON_MarksMergeResolutions(t *testing.T) {
+	hunks := []diff.ParsedHunk{
+		{ID: "a.go::1", File: "a.go", StartLine: 1, Diff: "@@@ -1,1 -1,1 +1,1 @@@\n- ours\n -theirs\n++resolved"},
+		{ID: "b.go::1", File: "b.go", StartLine: 1, Diff: "@@ -1 +1 @@\n-a\n+b"},
+	}
+
+	result, err := buildHunksJSON(hunks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal([]byte(result), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got %v:\n%s", err, result)
+	}
+	if decoded[0]["mergeResolution"] != true {
+		t.Errorf("expected combined hunk to be marked, got %v", decoded[0])
+	}
+	if _, ok := decoded[1]["mergeResolution"]; ok {
+		t.Errorf("ordinary hunks should not be marked, got %v", decoded[1])
+	}
+}
+
+func TestCarryOverStoredState(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+
+	previous := model.NewReviewWithSections(workDir, "Before", []model.Section{
+		{ID: "s", Hunks: []model.Hunk{{ID: "a.go::1", File: "a.go", Diff: "@@ -1 +1 @@\n-a\n+b", Importance: "high", Reviewed: true}}},
+	})
+	previous.DiffSource = "Uncommitted changes"
+	previous.CreatedAt = time.Now()
+	if err := store.Write(previous); err != nil {
+		t.Fatalf("failed to write previous review: %v", err)
+	}
+
+	parsed, err := diff.Parse("diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -4 +4 @@\n-a\n+b\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response := &LLMResponse{
+		Title: "After",
+		Chapters: []LLMChapter{{ID: "c", Title: "C", Sections: []LLMSection{{
+			ID: "s", Title: "S", Hunks: []LLMHunkRef{{ID: parsed[0].ID, Importance: "high"}},
+		}}}},
+	}
+	review := assembleReview(workDir, response, parsed)
+	review.DiffSource = "Uncommitted changes"
+
+	if carried := carryOverStoredState(store, &review); carried != 1 {
+		t.Errorf("carried = %d, want 1", carried)
+	}
+	hunk := review.Chapters[0].Sections[0].Hunks[0]
+	if hunk.Fingerprint != parsed[0].Fingerprint || !hunk.Reviewed {
+		t.Errorf("expected the moved hunk to keep its fingerprint and reviewed mark, got %+v", hunk)
+	}
+
+	review.DiffSource = "Staged changes"
+	review.Chapters[0].Sections[0].Hunks[0].Reviewed = false
+	if carried := carryOverStoredState(store, &review); carried != 0 {
+		t.Errorf("expected nothing carried from a review of another diff source, got %d", carried)
+	}
+}
+
+func TestGenerateReviewCmd_HTTPProviderReceivesHunksInPrompt(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	patch := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n"
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(patch), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompt = body.Messages[0].Content
+		reply, _ := json.Marshal(`{"title":"T","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"S","what":"w","why":"y","hunks":[{"id":"a.go::1","importance":"high"}]}]}]}`)
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%s},"finish_reason":"stop"}]}`, reply)
+	}))
+	defer server.Close()
+
+	params := GenerateParams{
+		DiffCommand: []string{"cat", "changes.patch"},
+		DiffSource:  "Patch",
+		LLM:         llm.OpenAI{BaseURL: server.URL, Model: "stub"},
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	if _, ok := msg.(GenerateSuccessMsg); !ok {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+
+	if !strings.Contains(prompt, `"id": "a.go::1"`) || strings.Contains(prompt, "JSON file") {
+		t.Errorf("expected the hunks inline in the prompt, got:\n%s", prompt)
+	}
+	if stored := storedReview(store, workDir, "", "Patch"); stored == nil || stored.Title != "T" {
+		t.Errorf("expected the review to be stored, got %+v", stored)
+	}
+}
+
+// classifyA is an LLM reply classifying the single hunk of classifyAPatch
+const (
+	classifyAPatch = "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n"
+	classifyA      = `{"title":"T","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"S","what":"w","why":"y","hunks":[{"id":"a.go::1","importance":"high"}]}]}]}`
+)
+
+func TestGenerateReviewCmd_PromptDelivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery llm.Delivery
+		script   string
+	}{
+		{
+			// The hunks are in a file outside the working tree, named in the
+			// prompt, which is the final argument
+			name:     "file",
+			delivery: llm.DeliverFile,
+			script: `path=$(printf '%s\n' "$1" | sed -n 's/^Read the input hunks from this JSON file: //p')
+case "$path" in "$PWD"/*|"") exit 1 ;; esac
+grep -q 'a.go::1' "$path" || exit 1
+echo "$path" > "$RECORD"
+echo '` + classifyA + `'`,
+		},
+		{
+			name:     "argument",
+			delivery: llm.DeliverArgument,
+			script: `case "$1" in *'"id": "a.go::1"'*) ;; *) exit 1 ;; esac
+echo '` + classifyA + `'`,
+		},
+		{
+			name:     "stdin",
+			delivery: llm.DeliverStdin,
+			script: `[ $# -eq 0 ] || exit 1
+case "$(cat)" in *'"id": "a.go::1"'*) ;; *) exit 1 ;; esac
+echo '` + classifyA + `'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := storage.NewStoreWithDir(t.TempDir())
+			if err != nil {
+				t.Fatalf("failed to create store: %v", err)
+			}
+			workDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(classifyAPatch), 0644); err != nil {
+				t.Fatal(err)
+			}
+			record := filepath.Join(t.TempDir(), "input-path")
+			t.Setenv("RECORD", record)
+
+			params := GenerateParams{
+				DiffCommand: []string{"cat", "changes.patch"},
+				DiffSource:  "Patch",
+				LLM:         llm.Command{Args: []string{"sh", "-c", tt.script, "llm"}, Delivery: tt.delivery},
+			}
+			msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+			if _, ok := msg.(GenerateSuccessMsg); !ok {
+				t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+			}
+
+			entries, err := os.ReadDir(workDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected nothing written to the working tree, found %d entries", len(entries))
+			}
+			if data, err := os.ReadFile(record); err == nil {
+				if _, err := os.Stat(strings.TrimSpace(string(data))); !os.IsNotExist(err) {
+					t.Errorf("expected the input file to be removed, got %v", err)
+				}
+			} else if tt.delivery == llm.DeliverFile {
+				t.Error("expected the LLM to be given an input file")
+			}
+		})
+	}
+}
+
+func TestGenerateReviewCmd_UsesPromptTemplates(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(classifyAPatch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	promptDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(promptDir, "classification.tmpl"), []byte("Custom {{.DiffSource}}: {{.Input}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prompts, err := prompt.Load(promptDir)
+	if err != nil {
+		t.Fatalf("failed to load prompts: %v", err)
+	}
+
+	script := `case "$1" in "Custom Patch: The input hunks are:"*) echo '` + classifyA + `' ;; *) exit 1 ;; esac`
+	params := GenerateParams{
+		DiffCommand: []string{"cat", "changes.patch"},
+		DiffSource:  "Patch",
+		LLM:         llm.Command{Args: []string{"sh", "-c", script, "llm"}, Delivery: llm.DeliverArgument},
+		Prompts:     prompts,
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	if _, ok := msg.(GenerateSuccessMsg); !ok {
+		t.Fatalf("expected GenerateSuccessMsg from the custom prompt, got %#v", msg)
+	}
+}
+
+func TestGenerateReviewCmd_LeavesOutExcludedFiles(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	patch := classifyAPatch + "diff --git a/go.sum b/go.sum\n--- a/go.sum\n+++ b/go.sum\n@@ -1 +1 @@\n-a\n+b\n"
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(patch), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reply only classifies a.go, so it passes validation only if go.sum was left out
+	script := `case "$1" in *go.sum*) exit 1 ;; *) echo '` + classifyA + `' ;; esac`
+	params := GenerateParams{
+		DiffCommand: []string{"cat", "changes.patch"},
+		DiffSource:  "Patch",
+		LLM:         llm.Command{Args: []string{"sh", "-c", script, "llm"}, Delivery: llm.DeliverArgument},
+		Exclude:     []string{"go.sum"},
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	if _, ok := msg.(GenerateSuccessMsg); !ok {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+
+	params.Exclude = []string{"*"}
+	msg = generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	if errMsg, ok := msg.(GenerateErrorMsg); !ok || !strings.Contains(errMsg.Err.Error(), "excluded") {
+		t.Errorf("expected an error when every file is excluded, got %#v", msg)
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mchowning/diffstory/internal/config"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
 )
 
 // dialogStyle creates a bordered dialog box
@@ -48,7 +51,7 @@ func (m Model) renderSourcePicker() string {
 	}
 
 	sb.WriteString("\n")
-	helpBox := helpStyle.Width(maxWidth).Render("j/k  navigate\nEnter  select\nEsc  cancel")
+	helpBox := helpStyle.Width(maxWidth).Render("j/k  navigate\nEnter  select\nf  select, ignoring cached results\nEsc  cancel")
 	sb.WriteString(helpBox)
 
 	dialog := dialogStyle.Render(sb.String())
@@ -125,7 +128,19 @@ func (m Model) renderContextInput() string {
 	sb.WriteString("Instructions for reviewer (editable)\n\n")
 	sb.WriteString(m.contextInput.View())
 	sb.WriteString("\n\n")
-	sb.WriteString(helpStyle.Render("Enter  generate\nAlt+Enter  new line\nEsc  cancel"))
+	help := "Enter  generate\nAlt+Enter  new line\nEsc  cancel"
+	if m.canGenerateIncrementally() {
+		mode := "Full: classify every hunk again"
+		if m.incrementalGenerate {
+			mode = "Incremental: keep the current story, place only new hunks"
+		}
+		sb.WriteString(mode + "\n\n")
+		help = "Enter  generate\nTab  switch full/incremental\nAlt+Enter  new line\nEsc  cancel"
+	}
+	if m.forceGenerate {
+		sb.WriteString("Ignoring cached results: the LLM will be asked again\n\n")
+	}
+	sb.WriteString(helpStyle.Render(help))
 
 	dialog := dialogStyle.Render(sb.String())
 	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
@@ -135,7 +150,13 @@ func (m Model) renderContextInput() string {
 func (m Model) renderValidationError() string {
 	var sb strings.Builder
 	sb.WriteString("Classification incomplete\n\n")
-	sb.WriteString("Missing hunks:\n")
+	if m.repairAttempts == 1 {
+		sb.WriteString("Still missing after 1 repair attempt:\n")
+	} else if m.repairAttempts > 1 {
+		sb.WriteString(fmt.Sprintf("Still missing after %d repair attempts:\n", m.repairAttempts))
+	} else {
+		sb.WriteString("Missing hunks:\n")
+	}
 	maxDisplay := 10
 	for i, id := range m.missingHunkIDs {
 		if i >= maxDisplay {
@@ -145,7 +166,7 @@ func (m Model) renderValidationError() string {
 		sb.WriteString(fmt.Sprintf("  • %s\n", id))
 	}
 	sb.WriteString("\n")
-	sb.WriteString(helpStyle.Render("r  retry\np  proceed with partial\nEsc  cancel"))
+	sb.WriteString(helpStyle.Render("r  retry the missing hunks\np  proceed with partial\nEsc  cancel"))
 
 	dialog := dialogStyle.Render(sb.String())
 	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
@@ -162,9 +183,10 @@ func (m Model) updateSourcePicker(msg tea.KeyMsg) (Model, tea.Cmd) {
 		if m.diffSourceSelected > 0 {
 			m.diffSourceSelected--
 		}
-	case "enter":
+	case "enter", "f":
 		source := m.diffSources[m.diffSourceSelected]
 		m.selectedDiffSource = &source
+		m.forceGenerate = msg.String() == "f"
 		if source.NeedsCommit {
 			m.generateUIState = GenerateUIStateCommitSelector
 			m.commitSelected = 0
@@ -176,9 +198,7 @@ func (m Model) updateSourcePicker(msg tea.KeyMsg) (Model, tea.Cmd) {
 			m.commitInputActive = false
 			return m, loadCommitList()
 		}
-		m.generateUIState = GenerateUIStateContextInput
-		m.contextInput.Focus()
-		return m, textarea.Blink
+		return m.openContextInput()
 	case "esc":
 		m.generateUIState = GenerateUIStateNone
 	}
@@ -258,23 +278,45 @@ func (m Model) selectCommit(commitRef string) (Model, tea.Cmd) {
 		return m, nil
 	} else if m.generateUIState == GenerateUIStateCommitRangeEnd {
 		// Build range command
-		m.selectedDiffSource = &DiffSource{
-			Label:   fmt.Sprintf("Range: %s..%s", m.rangeStartCommit, commitRef),
-			Command: []string{"git", "diff", m.rangeStartCommit + ".." + commitRef, "--no-color", "--no-ext-diff"},
-		}
-		m.generateUIState = GenerateUIStateContextInput
-		m.contextInput.Focus()
-		return m, textarea.Blink
+		source := RangeDiffSource(m.rangeStartCommit, commitRef)
+		m.selectedDiffSource = &source
+		return m.openContextInput()
 	}
 
 	// Single commit mode
-	m.selectedDiffSource = &DiffSource{
-		Label:   fmt.Sprintf("Commit: %s", commitRef),
-		Command: []string{"git", "show", commitRef, "--no-color", "--no-ext-diff", "--format="},
-	}
+	source := CommitDiffSource(commitRef)
+	m.selectedDiffSource = &source
+	return m.openContextInput()
+}
+
+// openContextInput moves on to the context input. Regenerating a source
+// whose review is stored defaults to updating it incrementally, once the
+// store has been checked.
+func (m Model) openContextInput() (Model, tea.Cmd) {
 	m.generateUIState = GenerateUIStateContextInput
+	m.incrementalAvailable = false
+	m.incrementalGenerate = false
 	m.contextInput.Focus()
-	return m, textarea.Blink
+	cmd := tea.Cmd(textarea.Blink)
+	if m.store != nil && m.selectedDiffSource != nil {
+		cmd = tea.Batch(cmd, checkStoredSourceCmd(m.store, m.workDir, m.selectedDiffSource.Label))
+	}
+	return m, cmd
+}
+
+// checkStoredSourceCmd looks up the review the generator would update for
+// diffSource, keyed by the checked-out branch as generation keys it
+func checkStoredSourceCmd(store *storage.Store, workDir, diffSource string) tea.Cmd {
+	return func() tea.Msg {
+		branch := currentBranch(context.Background(), workDir)
+		return StoredSourceMsg{DiffSource: diffSource, Exists: storedReview(store, workDir, branch, diffSource) != nil}
+	}
+}
+
+// canGenerateIncrementally reports whether a review of the selected diff
+// source is stored, so it can be updated rather than replaced
+func (m Model) canGenerateIncrementally() bool {
+	return m.incrementalAvailable
 }
 
 // updateContextInput handles key events in the context input state
@@ -295,6 +337,11 @@ func (m Model) updateContextInput(msg tea.KeyMsg) (Model, tea.Cmd) {
 		}
 		m.generateUIState = GenerateUIStateNone
 		return m, m.startGeneration()
+	case tea.KeyTab:
+		if m.canGenerateIncrementally() {
+			m.incrementalGenerate = !m.incrementalGenerate
+		}
+		return m, nil
 	case tea.KeyEsc:
 		m.generateUIState = GenerateUIStateSourcePicker
 		m.contextInput.Blur()
@@ -322,6 +369,9 @@ func (m Model) updateValidationError(msg tea.KeyMsg) (Model, tea.Cmd) {
 		m.generateUIState = GenerateUIStateNone
 		m.parsedHunks = nil
 		m.missingHunkIDs = nil
+		m.lastLLMResponse = nil
+		m.lastProvenance = nil
+		m.lastRecord = nil
 	}
 	return m, nil
 }
@@ -362,12 +412,22 @@ func (m *Model) startGeneration() tea.Cmd {
 	m.cancelGenerate = cancel
 	m.isGenerating = true
 	m.generateStartTime = time.Now()
+	m.generatingSource = m.selectedDiffSource.Label
 
 	params := GenerateParams{
-		DiffCommand: m.selectedDiffSource.Command,
-		LLMCommand:  m.resolvedLLMCommand,
-		Context:     m.lastContext,
-		IsRetry:     false,
+		DiffCommand:          m.selectedDiffSource.Command,
+		DiffSource:           m.selectedDiffSource.Label,
+		LLM:                  m.resolvedLLM,
+		Context:              m.lastContext,
+		Incremental:          m.incrementalGenerate,
+		MaxBatchBytes:        m.maxBatchBytes(),
+		MaxRepairAttempts:    m.maxRepairAttempts(),
+		Prompts:              m.prompts,
+		Exclude:              m.excludePatterns(),
+		CacheMaxBytes:        m.llmCacheMaxBytes(),
+		Force:                m.forceGenerate,
+		TwoPass:              m.twoPass(),
+		NarrativeConcurrency: m.narrativeConcurrency(),
 	}
 
 	return tea.Batch(
@@ -376,7 +436,59 @@ func (m *Model) startGeneration() tea.Cmd {
 	)
 }
 
-// startRetryGeneration retries generation with the preserved context
+// excludePatterns returns the configured globs of files to leave out of
+// generated reviews
+func (m Model) excludePatterns() []string {
+	if m.config == nil {
+		return nil
+	}
+	return m.config.Exclude
+}
+
+// maxBatchBytes returns the configured size budget of one classification
+// request
+func (m Model) maxBatchBytes() int {
+	if m.config == nil {
+		return config.DefaultMaxBatchBytes
+	}
+	return m.config.MaxBatchBytes
+}
+
+// llmCacheMaxBytes returns the configured size limit of the LLM cache, or 0
+// when it is disabled
+func (m Model) llmCacheMaxBytes() int64 {
+	if m.config == nil {
+		return config.DefaultLLMCacheMaxBytes
+	}
+	return max(m.config.LLMCacheMaxBytes, 0)
+}
+
+// maxRepairAttempts returns the configured number of times to ask the LLM to
+// place hunks a classification left out
+func (m Model) maxRepairAttempts() int {
+	if m.config == nil {
+		return config.DefaultMaxRepairAttempts
+	}
+	return max(m.config.MaxRepairAttempts, 0)
+}
+
+// twoPass reports whether reviews are generated as an outline followed by a
+// narrative request per section
+func (m Model) twoPass() bool {
+	return m.config != nil && m.config.TwoPassGeneration
+}
+
+// narrativeConcurrency returns the configured number of section narratives
+// to write at once
+func (m Model) narrativeConcurrency() int {
+	if m.config == nil {
+		return config.DefaultNarrativeConcurrency
+	}
+	return max(m.config.NarrativeConcurrency, 1)
+}
+
+// startRetryGeneration makes more repair attempts on the classification that
+// left hunks out, with the preserved context
 func (m *Model) startRetryGeneration() tea.Cmd {
 	if m.selectedDiffSource == nil {
 		return nil
@@ -386,14 +498,24 @@ func (m *Model) startRetryGeneration() tea.Cmd {
 	m.cancelGenerate = cancel
 	m.isGenerating = true
 	m.generateStartTime = time.Now()
+	m.generatingSource = m.selectedDiffSource.Label
 
 	params := GenerateParams{
-		DiffCommand: m.selectedDiffSource.Command,
-		LLMCommand:  m.resolvedLLMCommand,
-		Context:     m.lastContext,
-		IsRetry:     true,
-		MissingIDs:  m.missingHunkIDs,
-		ParsedHunks: m.parsedHunks,
+		DiffCommand:          m.selectedDiffSource.Command,
+		DiffSource:           m.selectedDiffSource.Label,
+		LLM:                  m.resolvedLLM,
+		Context:              m.lastContext,
+		Repair:               m.lastLLMResponse,
+		ParsedHunks:          m.parsedHunks,
+		Provenance:           m.lastProvenance,
+		Record:               m.lastRecord,
+		CacheMaxBytes:        m.llmCacheMaxBytes(),
+		Incremental:          m.incrementalGenerate,
+		MaxBatchBytes:        m.maxBatchBytes(),
+		MaxRepairAttempts:    m.maxRepairAttempts(),
+		Prompts:              m.prompts,
+		TwoPass:              m.twoPass(),
+		NarrativeConcurrency: m.narrativeConcurrency(),
 	}
 
 	return tea.Batch(
@@ -415,9 +537,30 @@ func (m *Model) proceedWithPartial() tea.Cmd {
 	missingIDs := m.missingHunkIDs
 	workDir := m.workDir
 	store := m.store
+	var diffSource string
+	if m.selectedDiffSource != nil {
+		diffSource = m.selectedDiffSource.Label
+	}
+
+	incremental := m.incrementalGenerate
+	provenance := m.lastProvenance
+	logger := m.logger
+	record := newGenerationRecord(m.lastRecord, m.resolvedLLM)
+	record.Generation.Partial = true
 
 	return func() tea.Msg {
-		review := assemblePartialReview(workDir, response, hunks, missingIDs)
+		branch := currentBranch(context.Background(), workDir)
+		var review model.Review
+		if previous := storedReview(store, workDir, branch, diffSource); incremental && previous != nil {
+			review = planIncremental(*previous, hunks).assemble(workDir, response, missingIDs)
+		} else {
+			review = assemblePartialReview(workDir, response, hunks, missingIDs)
+		}
+		review.DiffSource = diffSource
+		review.Branch = branch
+		review.Provenance = provenance
+		review.Generation = record.finish(store, workDir, logger)
+		carryOverStoredState(store, &review)
 		if err := store.Write(review); err != nil {
 			return GenerateErrorMsg{Err: fmt.Errorf("failed to save partial review: %w", err)}
 		}
@@ -0,0 +1,259 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+// generationView is the state of the generation overlay, which shows how the
+// loaded review was generated and the exchanges with the LLM
+type generationView struct {
+	transcript *model.Transcript // nil until loaded, or when there is none
+	err        error             // Why the transcript could not be loaded
+	selected   int               // Selected exchange
+	reading    bool              // The selected exchange is shown in full
+	viewport   viewport.Model    // Scrolls the exchange being read
+}
+
+// loadTranscriptCmd returns a command that loads the transcript archived
+// for workDir under id
+func loadTranscriptCmd(store *storage.Store, workDir, id string) tea.Cmd {
+	return func() tea.Msg {
+		transcript, err := store.ReadTranscript(workDir, id)
+		return TranscriptLoadedMsg{ID: id, Transcript: transcript, Err: err}
+	}
+}
+
+// openGeneration shows the generation overlay, or a status message when the
+// loaded review has no generation record
+func (m Model) openGeneration() (Model, tea.Cmd) {
+	if m.loadedReview == nil || m.loadedReview.Generation == nil {
+		m.statusMsg = "No generation record: this review was not generated by this version of diffstory"
+		return m, tea.Tick(3*time.Second, func(time.Time) tea.Msg {
+			return ClearStatusMsg{}
+		})
+	}
+	m.generationView = &generationView{}
+	generation := m.loadedReview.Generation
+	if generation.Transcript == "" || m.store == nil {
+		return m, nil
+	}
+	return m, loadTranscriptCmd(m.store, m.loadedReview.WorkingDirectory, generation.Transcript)
+}
+
+// updateGeneration handles key events while the generation overlay is open
+func (m Model) updateGeneration(msg tea.KeyMsg) (Model, tea.Cmd) {
+	view := *m.generationView
+	if view.reading {
+		switch msg.String() {
+		case "esc", "q":
+			view.reading = false
+		default:
+			var cmd tea.Cmd
+			view.viewport, cmd = view.viewport.Update(msg)
+			m.generationView = &view
+			return m, cmd
+		}
+		m.generationView = &view
+		return m, nil
+	}
+
+	var exchanges []model.Exchange
+	if view.transcript != nil {
+		exchanges = view.transcript.Exchanges
+	}
+	switch msg.String() {
+	case "j", "down":
+		if view.selected < len(exchanges)-1 {
+			view.selected++
+		}
+	case "k", "up":
+		if view.selected > 0 {
+			view.selected--
+		}
+	case "enter":
+		if view.selected < len(exchanges) {
+			width, height := m.exchangeViewportSize()
+			view.viewport = viewport.New(width, height)
+			view.viewport.SetContent(exchangeContent(exchanges[view.selected], width))
+			view.reading = true
+		}
+	case "esc", "q", "i":
+		m.generationView = nil
+		return m, nil
+	}
+	m.generationView = &view
+	return m, nil
+}
+
+// exchangeViewportSize returns the size of the viewport an exchange is read
+// in, inside the overlay's dialog
+func (m Model) exchangeViewportSize() (int, int) {
+	return max(m.generationDialogWidth()-6, 20), max(m.height-12, 5)
+}
+
+func (m Model) generationDialogWidth() int {
+	return min(m.width-4, 120)
+}
+
+// exchangeContent renders an exchange in full: the prompt, the input file
+// if the LLM read one, and the reply or error, wrapped to width
+func exchangeContent(exchange model.Exchange, width int) string {
+	var sb strings.Builder
+	part := func(title, text string) {
+		sb.WriteString(descriptionLabelStyle.Render(title))
+		sb.WriteString("\n")
+		sb.WriteString(wrapPreformatted(text, width))
+		sb.WriteString("\n\n")
+	}
+	part("PROMPT", exchange.Prompt)
+	if exchange.Input != "" {
+		part("INPUT FILE", exchange.Input)
+	}
+	if exchange.Error != "" {
+		part("ERROR", exchange.Error)
+	}
+	if exchange.Response != "" {
+		title := "RESPONSE"
+		if exchange.JSONRepaired {
+			title += " (malformed JSON, repaired)"
+		}
+		part(title, exchange.Response)
+	}
+	return strings.TrimSuffix(sb.String(), "\n\n")
+}
+
+// wrapPreformatted breaks text's lines at width, keeping its line breaks and
+// indentation, unlike wrapText
+func wrapPreformatted(text string, width int) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		runes := []rune(line)
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// generationSummary describes a generation as label and value rows
+func generationSummary(review model.Review) [][2]string {
+	g := review.Generation
+	rows := [][2]string{
+		{"Diff source", review.DiffSource},
+		{"LLM", g.LLM},
+		{"Generated", fmt.Sprintf("%s, in %s", g.StartedAt.Local().Format("2006-01-02 15:04:05"), g.Duration().Round(100*time.Millisecond))},
+	}
+	hunks := fmt.Sprintf("%d", g.HunkCount)
+	if g.Batches > 1 {
+		hunks += fmt.Sprintf(", classified in %d batches", g.Batches)
+	}
+	if g.Incremental {
+		hunks += ", updating the previous review"
+	}
+	rows = append(rows, [2]string{"Hunks", hunks})
+
+	requests := fmt.Sprintf("%d", g.Requests)
+	if g.Cached {
+		requests += ", classification from the cache"
+	}
+	if g.RepairAttempts > 0 {
+		requests += fmt.Sprintf(", %d repair attempts", g.RepairAttempts)
+	}
+	rows = append(rows, [2]string{"LLM requests", requests})
+
+	if g.TwoPass {
+		rows = append(rows, [2]string{"Two passes", fmt.Sprintf("an outline, then a narrative request for %d sections", g.Narrated)})
+	}
+	if g.JSONRepaired {
+		rows = append(rows, [2]string{"JSON repair", "applied to malformed LLM output"})
+	}
+	if g.Partial {
+		rows = append(rows, [2]string{"Unclassified", "saved with the hunks the LLM left out unclassified"})
+	}
+	return rows
+}
+
+// renderGeneration renders the generation overlay
+func (m Model) renderGeneration() string {
+	view := m.generationView
+	dialogWidth := m.generationDialogWidth()
+	var sb strings.Builder
+
+	var exchanges []model.Exchange
+	if view.transcript != nil {
+		exchanges = view.transcript.Exchanges
+	}
+	if view.reading && view.selected < len(exchanges) {
+		exchange := exchanges[view.selected]
+		sb.WriteString(fmt.Sprintf("LLM request %d of %d: %s\n\n", view.selected+1, len(exchanges), exchange.Purpose))
+		sb.WriteString(view.viewport.View())
+		sb.WriteString("\n\n")
+		sb.WriteString(helpStyle.Render(fmt.Sprintf("j/k  scroll (%d%%)\nEsc  back", int(view.viewport.ScrollPercent()*100))))
+		dialog := dialogStyle.Width(dialogWidth).Render(sb.String())
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
+	}
+
+	sb.WriteString("Generation\n\n")
+	for _, row := range generationSummary(*m.loadedReview) {
+		sb.WriteString(dimStyle.Render(fmt.Sprintf("%-14s", row[0])))
+		sb.WriteString(truncate(row[1], max(dialogWidth-22, 10)))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+
+	switch {
+	case len(exchanges) > 0:
+		sb.WriteString("Transcript\n\n")
+		maxDisplay := max(min(m.height-22, 20), 3)
+		start := 0
+		if view.selected >= maxDisplay {
+			start = view.selected - maxDisplay + 1
+		}
+		for i := start; i < len(exchanges) && i < start+maxDisplay; i++ {
+			exchange := exchanges[i]
+			prefix, style := "  ", normalStyle
+			if i == view.selected {
+				prefix, style = "› ", selectedStyle
+			}
+			detail := fmt.Sprintf("  %s prompt, %s reply, %s", formatSize(int64(len(exchange.Prompt)+len(exchange.Input))), formatSize(int64(len(exchange.Response))), (time.Duration(exchange.DurationMs) * time.Millisecond).Round(100*time.Millisecond))
+			if exchange.Error != "" {
+				detail = "  failed: " + truncate(exchange.Error, 40)
+			}
+			sb.WriteString(style.Render(prefix+exchange.Purpose) + dimStyle.Render(detail))
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+		sb.WriteString(helpStyle.Render("j/k  navigate\nEnter  read request and reply\nEsc  close"))
+	case view.err != nil && errors.Is(view.err, os.ErrNotExist):
+		sb.WriteString(dimStyle.Render("The transcript has been pruned; only the newest are kept"))
+		sb.WriteString("\n\n")
+		sb.WriteString(helpStyle.Render("Esc  close"))
+	case view.err != nil:
+		sb.WriteString(dimStyle.Render("Could not load the transcript: " + view.err.Error()))
+		sb.WriteString("\n\n")
+		sb.WriteString(helpStyle.Render("Esc  close"))
+	default:
+		if m.loadedReview.Generation.Transcript != "" && view.transcript == nil {
+			sb.WriteString(dimStyle.Render("Loading transcript..."))
+		} else {
+			sb.WriteString(dimStyle.Render("No LLM requests were made"))
+		}
+		sb.WriteString("\n\n")
+		sb.WriteString(helpStyle.Render("Esc  close"))
+	}
+
+	dialog := dialogStyle.Width(dialogWidth).Render(sb.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
+}
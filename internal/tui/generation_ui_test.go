@@ -0,0 +1,105 @@
+package tui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+	"github.com/mchowning/diffstory/internal/tui"
+)
+
+// runCmd runs cmd, feeding its message back into m. The commands of a batch
+// are each run in turn.
+func runCmd(m tui.Model, cmd tea.Cmd) tui.Model {
+	if cmd == nil {
+		return m
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, c := range batch {
+			m = runCmd(m, c)
+		}
+		return m
+	}
+	updated, _ := m.Update(msg)
+	return updated.(tui.Model)
+}
+
+func TestGeneration_OverlayShowsRecordAndTranscript(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+	id, err := store.WriteTranscript(workDir, model.Transcript{Exchanges: []model.Exchange{
+		{Purpose: "classification", Prompt: "Classify these hunks", Response: `{"title":"Progress"}`, DurationMs: 4200, JSONRepaired: true},
+	}})
+	if err != nil {
+		t.Fatalf("WriteTranscript failed: %v", err)
+	}
+	review := progressTestReview(workDir)
+	review.DiffSource = "Staged changes"
+	review.Generation = &model.Generation{
+		LLM: "claude -p", StartedAt: time.Now(), DurationMs: 4300, HunkCount: 4, Requests: 1,
+		JSONRepaired: true, Transcript: id,
+	}
+	m := newProgressModel(t, store, review)
+
+	m, cmd := pressKey(m, "i")
+	if !m.ShowGeneration() {
+		t.Fatal("expected the generation overlay to open")
+	}
+	m = runCmd(m, cmd)
+	view := m.View()
+	for _, want := range []string{"Staged changes", "claude -p", "applied to malformed LLM output", "Transcript", "classification"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in the overlay:\n%s", want, view)
+		}
+	}
+
+	m, _ = pressType(m, tea.KeyEnter)
+	view = m.View()
+	if !strings.Contains(view, "Classify these hunks") || !strings.Contains(view, "RESPONSE (malformed JSON, repaired)") {
+		t.Errorf("expected the exchange in full:\n%s", view)
+	}
+
+	m, _ = pressType(m, tea.KeyEsc)
+	if !m.ShowGeneration() || !strings.Contains(m.View(), "Transcript") {
+		t.Fatal("expected Esc to go back to the overlay")
+	}
+	m, _ = pressType(m, tea.KeyEsc)
+	if m.ShowGeneration() {
+		t.Error("expected Esc to close the overlay")
+	}
+}
+
+func TestGeneration_PrunedTranscript(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	review := progressTestReview(t.TempDir())
+	review.Generation = &model.Generation{LLM: "claude -p", Requests: 1, Transcript: "20250101T120000.000000000Z"}
+	m := newProgressModel(t, store, review)
+
+	m, cmd := pressKey(m, "i")
+	m = runCmd(m, cmd)
+	if !strings.Contains(m.View(), "The transcript has been pruned") {
+		t.Errorf("expected the overlay to say the transcript is gone:\n%s", m.View())
+	}
+}
+
+func TestGeneration_WithoutRecordShowsStatus(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+
+	m, _ = pressKey(m, "i")
+	if m.ShowGeneration() {
+		t.Error("expected no overlay for a review without a generation record")
+	}
+	if !strings.Contains(m.StatusMsg(), "No generation record") {
+		t.Errorf("expected a status message, got %q", m.StatusMsg())
+	}
+}
@@ -0,0 +1,200 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+const incrementalPromptAddendum = `
+
+## Updating An Existing Review
+
+These changes were reviewed before. The input holds only the hunks that are new or changed
+since; every other hunk keeps its place in the existing story, whose chapters and sections are:
+%s
+
+Place each input hunk in the existing section it belongs to by giving that section's id inside
+its chapter's id, repeating their titles, what and why unchanged. When a hunk fits no existing
+section, create a new section (in an existing or new chapter) with a new id. List only the input
+hunks; do not repeat hunks already in the story.`
+
+// outlineChapter is a chapter of an existing review as shown to the LLM when
+// regenerating incrementally, without its hunks
+type outlineChapter struct {
+	ID       string           `json:"id"`
+	Title    string           `json:"title"`
+	Sections []outlineSection `json:"sections"`
+}
+
+type outlineSection struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	What  string `json:"what"`
+	Why   string `json:"why"`
+}
+
+// incrementalPlan divides a new diff's hunks into those the previous review
+// already placed, matched by fingerprint, and those the LLM has to place
+type incrementalPlan struct {
+	base  model.Review      // The previous review, holding only hunks still in the diff
+	fresh []diff.ParsedHunk // Hunks that are new or changed since the previous review
+}
+
+// planIncremental matches hunks against previous. Kept hunks take their new
+// position and diff but keep their section, importance and test flag;
+// sections and chapters left without hunks are dropped once the fresh hunks
+// have been placed.
+func planIncremental(previous model.Review, hunks []diff.ParsedHunk) incrementalPlan {
+	byFingerprint := make(map[string]diff.ParsedHunk, len(hunks))
+	for _, h := range hunks {
+		byFingerprint[h.Fingerprint] = h
+	}
+
+	previous = previous.WithFingerprints()
+	plan := incrementalPlan{base: model.Review{
+		WorkingDirectory: previous.WorkingDirectory,
+		Title:            previous.Title,
+	}}
+	placed := make(map[string]bool)
+	for _, ch := range previous.Chapters {
+		chapter := model.Chapter{ID: ch.ID, Title: ch.Title}
+		for _, s := range ch.Sections {
+			// Reviewed marks are carried over per hunk, so a section that
+			// gains new hunks is no longer reviewed as a whole
+			section := model.Section{ID: s.ID, Title: s.Title, What: s.What, Why: s.Why}
+			for _, old := range s.Hunks {
+				fp := old.ContentFingerprint()
+				h, ok := byFingerprint[fp]
+				if !ok || placed[fp] {
+					continue
+				}
+				placed[fp] = true
+				hunk := modelHunk(h)
+				hunk.Importance = old.Importance
+				hunk.IsTest = old.IsTest
+				section.Hunks = append(section.Hunks, hunk)
+			}
+			chapter.Sections = append(chapter.Sections, section)
+		}
+		plan.base.Chapters = append(plan.base.Chapters, chapter)
+	}
+
+	for _, h := range hunks {
+		if !placed[h.Fingerprint] {
+			plan.fresh = append(plan.fresh, h)
+		}
+	}
+	return plan
+}
+
+// outlineJSON renders the base review's chapters and sections for the prompt
+func (p incrementalPlan) outlineJSON() (string, error) {
+	var outline []outlineChapter
+	for _, ch := range p.base.Chapters {
+		chapter := outlineChapter{ID: ch.ID, Title: ch.Title, Sections: []outlineSection{}}
+		for _, s := range ch.Sections {
+			chapter.Sections = append(chapter.Sections, outlineSection{ID: s.ID, Title: s.Title, What: s.What, Why: s.Why})
+		}
+		outline = append(outline, chapter)
+	}
+	data, err := json.MarshalIndent(outline, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to build review outline: %w", err)
+	}
+	return string(data), nil
+}
+
+// assemble merges the LLM's placement of the fresh hunks into the base
+// review. Hunks listed in missingIDs go to an unclassified section, as for a
+// partial review. Without fresh hunks, response may be nil.
+func (p incrementalPlan) assemble(workDir string, response *LLMResponse, missingIDs []string) model.Review {
+	review := p.base
+	review.WorkingDirectory = workDir
+	review.CreatedAt = time.Now()
+	review.Chapters = cloneChapters(p.base.Chapters)
+	if response != nil {
+		added := assemblePartialReview(workDir, response, p.fresh, missingIDs)
+		mergeChapters(&review, added.Chapters)
+	}
+	review.Chapters = withoutEmptySections(review.Chapters)
+	return review
+}
+
+// mergeChapters adds chapters into review: sections with an existing
+// section's ID extend it, other sections join the chapter with the same ID,
+// and chapters with new IDs are appended
+func mergeChapters(review *model.Review, chapters []model.Chapter) {
+	for _, ch := range chapters {
+		for _, s := range ch.Sections {
+			if ci, si, ok := findSectionByID(review.Chapters, s.ID); ok {
+				existing := &review.Chapters[ci].Sections[si]
+				existing.Hunks = append(existing.Hunks, s.Hunks...)
+				continue
+			}
+			ci := findChapterByID(review.Chapters, ch.ID)
+			if ci < 0 {
+				review.Chapters = append(review.Chapters, model.Chapter{ID: ch.ID, Title: ch.Title})
+				ci = len(review.Chapters) - 1
+			}
+			review.Chapters[ci].Sections = append(review.Chapters[ci].Sections, s)
+		}
+	}
+}
+
+func findSectionByID(chapters []model.Chapter, id string) (int, int, bool) {
+	for ci, ch := range chapters {
+		for si, s := range ch.Sections {
+			if s.ID == id {
+				return ci, si, true
+			}
+		}
+	}
+	return -1, -1, false
+}
+
+func findChapterByID(chapters []model.Chapter, id string) int {
+	for ci, ch := range chapters {
+		if ch.ID == id {
+			return ci
+		}
+	}
+	return -1
+}
+
+// cloneChapters copies chapters deeply enough that appending hunks and
+// sections leaves the original untouched
+func cloneChapters(chapters []model.Chapter) []model.Chapter {
+	cloned := make([]model.Chapter, len(chapters))
+	for ci, ch := range chapters {
+		cloned[ci] = ch
+		cloned[ci].Sections = make([]model.Section, len(ch.Sections))
+		for si, s := range ch.Sections {
+			cloned[ci].Sections[si] = s
+			cloned[ci].Sections[si].Hunks = append([]model.Hunk(nil), s.Hunks...)
+		}
+	}
+	return cloned
+}
+
+// withoutEmptySections drops sections without hunks, and chapters left
+// without sections
+func withoutEmptySections(chapters []model.Chapter) []model.Chapter {
+	var kept []model.Chapter
+	for _, ch := range chapters {
+		var sections []model.Section
+		for _, s := range ch.Sections {
+			if len(s.Hunks) > 0 {
+				sections = append(sections, s)
+			}
+		}
+		if len(sections) > 0 {
+			ch.Sections = sections
+			kept = append(kept, ch)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,233 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+// incrementalFixture returns a stored review of three hunks and a new diff
+// in which one hunk moved, one changed and one file is new
+func incrementalFixture(t *testing.T) (model.Review, []diff.ParsedHunk) {
+	t.Helper()
+	isTest := true
+	previous := model.Review{
+		WorkingDirectory: "/test",
+		Title:            "Add login",
+		Chapters: []model.Chapter{
+			{ID: "auth", Title: "Auth", Sections: []model.Section{
+				{ID: "login", Title: "Login", What: "Adds login", Why: "Users", Reviewed: true, Hunks: []model.Hunk{
+					{ID: "auth.go::1", File: "auth.go", Diff: "@@ -1 +1 @@\n-a\n+b", Importance: "high"},
+				}},
+				{ID: "logout", Title: "Logout", Hunks: []model.Hunk{
+					{ID: "auth.go::20", File: "auth.go", Diff: "@@ -20 +20 @@\n-c\n+d", Importance: "medium"},
+				}},
+			}},
+			{ID: "tests", Title: "Tests", Sections: []model.Section{
+				{ID: "login-tests", Title: "Login tests", Hunks: []model.Hunk{
+					{ID: "auth_test.go::1", File: "auth_test.go", Diff: "@@ -1 +1 @@\n-t\n+u", Importance: "low", IsTest: &isTest},
+				}},
+			}},
+		},
+	}
+
+	hunks, err := diff.Parse(`diff --git a/auth.go b/auth.go
+--- a/auth.go
++++ b/auth.go
+@@ -3 +3 @@
+-a
++b
+@@ -22 +22 @@
+-c
++changed
+diff --git a/auth_test.go b/auth_test.go
+--- a/auth_test.go
++++ b/auth_test.go
+@@ -1 +1 @@
+-t
++u
+diff --git a/session.go b/session.go
+new file mode 100644
+--- /dev/null
++++ b/session.go
+@@ -0,0 +1 @@
++package auth
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return previous, hunks
+}
+
+func TestPlanIncremental(t *testing.T) {
+	previous, hunks := incrementalFixture(t)
+
+	plan := planIncremental(previous, hunks)
+
+	var fresh []string
+	for _, h := range plan.fresh {
+		fresh = append(fresh, h.ID)
+	}
+	if strings.Join(fresh, ",") != "auth.go::22,session.go::1" {
+		t.Errorf("fresh = %v, want the changed and new hunks", fresh)
+	}
+
+	login := plan.base.Chapters[0].Sections[0]
+	if len(login.Hunks) != 1 || login.Hunks[0].ID != "auth.go::3" || login.Hunks[0].Importance != "high" {
+		t.Errorf("expected the moved hunk to keep its section and importance at its new position, got %+v", login.Hunks)
+	}
+	if login.Reviewed {
+		t.Error("section reviewed marks should not be kept; they are carried per hunk")
+	}
+	if got := plan.base.Chapters[1].Sections[0].Hunks[0]; got.IsTest == nil || !*got.IsTest {
+		t.Errorf("expected isTest to be kept, got %+v", got)
+	}
+	if len(plan.base.Chapters[0].Sections[1].Hunks) != 0 {
+		t.Error("expected the changed hunk to leave its old section")
+	}
+}
+
+func TestIncrementalPlanAssemble(t *testing.T) {
+	previous, hunks := incrementalFixture(t)
+	plan := planIncremental(previous, hunks)
+
+	response := &LLMResponse{
+		Title: "Ignored",
+		Chapters: []LLMChapter{
+			{ID: "auth", Title: "Auth", Sections: []LLMSection{
+				{ID: "logout", Title: "Logout", Hunks: []LLMHunkRef{{ID: "auth.go::22", Importance: "high"}}},
+			}},
+			{ID: "sessions", Title: "Sessions", Sections: []LLMSection{
+				{ID: "store", Title: "Session store", What: "Adds sessions", Hunks: []LLMHunkRef{{ID: "session.go::1", Importance: "medium"}}},
+			}},
+		},
+	}
+	if v := validateClassification(plan.fresh, *response); !v.Valid {
+		t.Fatalf("expected the response to classify every fresh hunk: %+v", v)
+	}
+
+	review := plan.assemble("/test", response, nil)
+
+	if review.Title != "Add login" {
+		t.Errorf("expected the story's title to be kept, got %q", review.Title)
+	}
+	var outline []string
+	for _, ch := range review.Chapters {
+		for _, s := range ch.Sections {
+			var ids []string
+			for _, h := range s.Hunks {
+				ids = append(ids, h.ID)
+			}
+			outline = append(outline, ch.ID+"/"+s.ID+":"+strings.Join(ids, ","))
+		}
+	}
+	want := []string{"auth/login:auth.go::3", "auth/logout:auth.go::22", "tests/login-tests:auth_test.go::1", "sessions/store:session.go::1"}
+	if strings.Join(outline, " ") != strings.Join(want, " ") {
+		t.Errorf("outline = %v, want %v", outline, want)
+	}
+	if len(plan.base.Chapters[0].Sections[1].Hunks) != 0 {
+		t.Error("assemble should not modify the plan")
+	}
+}
+
+func TestIncrementalPlanAssemble_NothingNew(t *testing.T) {
+	previous, _ := incrementalFixture(t)
+	hunks, err := diff.Parse("diff --git a/auth.go b/auth.go\n--- a/auth.go\n+++ b/auth.go\n@@ -9 +9 @@\n-a\n+b\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan := planIncremental(previous, hunks)
+	if len(plan.fresh) != 0 {
+		t.Fatalf("expected no fresh hunks, got %+v", plan.fresh)
+	}
+
+	review := plan.assemble("/test", nil, nil)
+	if len(review.Chapters) != 1 || len(review.Chapters[0].Sections) != 1 || review.Chapters[0].Sections[0].ID != "login" {
+		t.Errorf("expected only the section with a remaining hunk, got %+v", review.Chapters)
+	}
+	if review.CreatedAt.IsZero() {
+		t.Error("expected a creation time")
+	}
+}
+
+func TestIncrementalPlanAssemble_MissingHunksAreUnclassified(t *testing.T) {
+	previous, hunks := incrementalFixture(t)
+	plan := planIncremental(previous, hunks)
+	response := &LLMResponse{Chapters: []LLMChapter{{ID: "auth", Sections: []LLMSection{
+		{ID: "logout", Hunks: []LLMHunkRef{{ID: "auth.go::22", Importance: "high"}}},
+	}}}}
+
+	review := plan.assemble("/test", response, []string{"session.go::1"})
+
+	last := review.Chapters[len(review.Chapters)-1]
+	if last.Title != "Unclassified" || last.Sections[0].Hunks[0].ID != "session.go::1" {
+		t.Errorf("expected the missing hunk in an unclassified chapter, got %+v", last)
+	}
+}
+
+func TestIncrementalPlanOutlineJSON(t *testing.T) {
+	previous, hunks := incrementalFixture(t)
+
+	outline, err := planIncremental(previous, hunks).outlineJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{`"id": "login"`, `"what": "Adds login"`, `"id": "logout"`} {
+		if !strings.Contains(outline, want) {
+			t.Errorf("outline should contain %s:\n%s", want, outline)
+		}
+	}
+	if strings.Contains(outline, "@@") {
+		t.Error("outline should not include hunks")
+	}
+}
+
+func TestGenerateReviewCmd_IncrementalWithoutNewHunksSkipsLLM(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+
+	previous, _ := incrementalFixture(t)
+	previous.WorkingDirectory = workDir
+	previous.DiffSource = "Patch"
+	previous.CreatedAt = time.Now().Add(-time.Hour)
+	if err := store.Write(previous); err != nil {
+		t.Fatalf("failed to write previous review: %v", err)
+	}
+
+	patch := "diff --git a/auth.go b/auth.go\n--- a/auth.go\n+++ b/auth.go\n@@ -9 +9 @@\n-a\n+b\n"
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(patch), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	params := GenerateParams{
+		DiffCommand: []string{"cat", "changes.patch"},
+		DiffSource:  "Patch",
+		LLM:         llm.Command{Args: []string{"false"}}, // Fails if called
+		Incremental: true,
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	if _, ok := msg.(GenerateSuccessMsg); !ok {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+
+	stored := storedReview(store, workDir, "", "Patch")
+	if stored == nil {
+		t.Fatal("expected the updated review to be stored")
+	}
+	section := stored.Chapters[0].Sections[0]
+	if section.ID != "login" || section.Hunks[0].ID != "auth.go::9" || !section.Hunks[0].Reviewed {
+		t.Errorf("expected the moved hunk in its section, still reviewed, got %+v", section)
+	}
+}
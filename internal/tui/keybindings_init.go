@@ -13,7 +13,15 @@ func initKeybindings() *KeybindingRegistry {
 	r.Register(Keybinding{Key: "h/l", Description: "Cycle panel focus", Context: "global"})
 	r.Register(Keybinding{Key: "f", Description: "Cycle importance filter", Context: "global"})
 	r.Register(Keybinding{Key: "t", Description: "Cycle test filter", Context: "global"})
+	r.Register(Keybinding{Key: "space", Description: "Toggle section reviewed (hunk in diff panel)", Context: "global"})
+	r.Register(Keybinding{Key: "v", Description: "Hide/show reviewed sections", Context: "global"})
+	r.Register(Keybinding{Key: "c", Description: "Add note to the hunk at the top of the diff", Context: "global"})
+	r.Register(Keybinding{Key: "C", Description: "Add note to the line at the top of the diff", Context: "global"})
+	r.Register(Keybinding{Key: "N", Description: "Browse notes", Context: "global"})
+	r.Register(Keybinding{Key: "i", Description: "Show how the review was generated", Context: "global"})
 	r.Register(Keybinding{Key: "G", Description: "Generate review (LLM)", Context: "global"})
+	r.Register(Keybinding{Key: "H", Description: "Browse review history", Context: "global"})
+	r.Register(Keybinding{Key: "R", Description: "Switch between stored reviews", Context: "global"})
 
 	// Navigation
 	r.Register(Keybinding{Key: "j/k", Description: "Navigate up/down", Context: "navigation"})
@@ -1,10 +1,13 @@
 package tui
 
 import (
+	"cmp"
 	"fmt"
+	"os"
 	"os/exec"
 
 	"github.com/mchowning/diffstory/internal/config"
+	"github.com/mchowning/diffstory/internal/llm"
 )
 
 // DefaultLookPath uses exec.LookPath to find commands on PATH
@@ -17,14 +20,38 @@ type LookPathFunc func(file string) (string, error)
 
 // LLMCommandResult represents the result of resolving the LLM command
 type LLMCommandResult struct {
-	Command []string
-	Error   string
+	Command  []string     // Set when the LLM is a command-line tool
+	Provider llm.Provider // The LLM to generate with
+	Error    string
 }
 
-// ResolveLLMCommand determines which LLM command to use.
+// ResolveLLMCommand determines which LLM to use.
+// If config has an llmProvider, it builds that HTTP client.
 // If config has an explicit llmCommand, it validates that command exists.
-// If no llmCommand is configured, it checks for claude on PATH.
+// If neither is configured, it checks for claude on PATH.
+// Commands receive the prompt as configured by promptDelivery.
 func ResolveLLMCommand(cfg *config.Config, lookPath LookPathFunc) LLMCommandResult {
+	// Case 0: Built-in HTTP provider configured
+	if cfg != nil && cfg.LLMProvider != nil {
+		if len(cfg.LLMCommand) > 0 {
+			return LLMCommandResult{Error: "Both llmCommand and llmProvider are configured.\n\nRemove one of them from your config."}
+		}
+		provider, err := httpProvider(*cfg.LLMProvider, os.Getenv)
+		if err != nil {
+			return LLMCommandResult{Error: fmt.Sprintf("Invalid llmProvider: %v", err)}
+		}
+		return LLMCommandResult{Provider: provider}
+	}
+
+	// Commands may take the prompt in different ways
+	delivery := llm.DeliverStdin
+	if cfg != nil {
+		var err error
+		if delivery, err = llm.ParseDelivery(cfg.PromptDelivery); err != nil {
+			return LLMCommandResult{Error: fmt.Sprintf("Invalid promptDelivery: %v", err)}
+		}
+	}
+
 	// Case 1: Explicit llmCommand configured
 	if cfg != nil && len(cfg.LLMCommand) > 0 {
 		cmd := cfg.LLMCommand[0]
@@ -33,7 +60,7 @@ func ResolveLLMCommand(cfg *config.Config, lookPath LookPathFunc) LLMCommandResu
 				Error: fmt.Sprintf("LLM command not found: %q\n\nCheck that the command is installed and on your PATH.", cmd),
 			}
 		}
-		return LLMCommandResult{Command: cfg.LLMCommand}
+		return LLMCommandResult{Command: cfg.LLMCommand, Provider: llm.Command{Args: cfg.LLMCommand, Delivery: delivery}}
 	}
 
 	// Case 2: No llmCommand configured - try claude as default
@@ -44,5 +71,45 @@ func ResolveLLMCommand(cfg *config.Config, lookPath LookPathFunc) LLMCommandResu
 		}
 	}
 
-	return LLMCommandResult{Command: []string{"claude", "-p"}}
+	command := []string{"claude", "-p"}
+	return LLMCommandResult{Command: command, Provider: llm.Command{Args: command, Delivery: delivery}}
+}
+
+// Default environment variables holding API keys
+const (
+	anthropicAPIKeyEnv = "ANTHROPIC_API_KEY"
+	openAIAPIKeyEnv    = "OPENAI_API_KEY"
+)
+
+// httpProvider builds the HTTP client configured by p, reading its API key
+// with getenv. Anthropic always needs a key; an OpenAI-compatible server
+// needs one unless it is a local server (a baseURL is set) and no key
+// variable is named.
+func httpProvider(p config.LLMProvider, getenv func(string) string) (llm.Provider, error) {
+	if p.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	keyEnv := p.APIKeyEnv
+	requireKey := p.APIKeyEnv != ""
+	switch p.Type {
+	case "anthropic":
+		keyEnv = cmp.Or(keyEnv, anthropicAPIKeyEnv)
+		requireKey = true
+	case "openai":
+		keyEnv = cmp.Or(keyEnv, openAIAPIKeyEnv)
+		requireKey = requireKey || p.BaseURL == ""
+	default:
+		return nil, fmt.Errorf("unknown type %q (use \"anthropic\" or \"openai\")", p.Type)
+	}
+
+	key := getenv(keyEnv)
+	if requireKey && key == "" {
+		return nil, fmt.Errorf("no API key: set $%s", keyEnv)
+	}
+
+	if p.Type == "anthropic" {
+		return llm.Anthropic{BaseURL: p.BaseURL, Model: p.Model, APIKey: key, MaxTokens: p.MaxTokens}, nil
+	}
+	return llm.OpenAI{BaseURL: p.BaseURL, Model: p.Model, APIKey: key, MaxTokens: p.MaxTokens}, nil
 }
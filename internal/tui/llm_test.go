@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/mchowning/diffstory/internal/config"
+	"github.com/mchowning/diffstory/internal/llm"
 )
 
 func TestResolveLLMCommand_ExplicitCommandFound(t *testing.T) {
@@ -116,3 +117,172 @@ func TestResolveLLMCommand_EmptyLLMCommandClaudeNotFound(t *testing.T) {
 		t.Errorf("expected error to mention Claude Code, got %q", result.Error)
 	}
 }
+
+func TestResolveLLMCommand_CommandProvider(t *testing.T) {
+	cfg := &config.Config{LLMCommand: []string{"my-llm", "--flag"}}
+	lookPath := func(cmd string) (string, error) { return "/usr/bin/" + cmd, nil }
+
+	result := ResolveLLMCommand(cfg, lookPath)
+
+	command, ok := result.Provider.(llm.Command)
+	if !ok || len(command.Args) != 2 || command.Args[0] != "my-llm" {
+		t.Errorf("expected a command provider running my-llm, got %#v", result.Provider)
+	}
+}
+
+func TestResolveLLMCommand_HTTPProvider(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "secret")
+	cfg := &config.Config{LLMProvider: &config.LLMProvider{Type: "anthropic", Model: "claude-test"}}
+	lookPath := func(cmd string) (string, error) {
+		return "", errors.New("not found")
+	}
+
+	result := ResolveLLMCommand(cfg, lookPath)
+
+	if result.Error != "" {
+		t.Fatalf("expected no error, got %q", result.Error)
+	}
+	provider, ok := result.Provider.(llm.Anthropic)
+	if !ok || provider.Model != "claude-test" || provider.APIKey != "secret" {
+		t.Errorf("expected an Anthropic provider with the key from the environment, got %#v", result.Provider)
+	}
+	if result.Command != nil {
+		t.Errorf("expected no command, got %v", result.Command)
+	}
+}
+
+func TestResolveLLMCommand_CommandAndProviderConflict(t *testing.T) {
+	cfg := &config.Config{
+		LLMCommand:  []string{"claude", "-p"},
+		LLMProvider: &config.LLMProvider{Type: "openai", Model: "gpt", BaseURL: "http://localhost:11434/v1"},
+	}
+	lookPath := func(cmd string) (string, error) { return "/usr/bin/" + cmd, nil }
+
+	result := ResolveLLMCommand(cfg, lookPath)
+
+	if result.Provider != nil || !strings.Contains(result.Error, "llmCommand and llmProvider") {
+		t.Errorf("expected an error naming both options, got %#v", result)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	env := map[string]string{"ANTHROPIC_API_KEY": "a-key", "OPENAI_API_KEY": "o-key", "GROQ_KEY": "g-key"}
+	getenv := func(name string) string { return env[name] }
+	noEnv := func(string) string { return "" }
+
+	tests := []struct {
+		name     string
+		config   config.LLMProvider
+		getenv   func(string) string
+		expected llm.Provider
+		err      string
+	}{
+		{
+			name:     "anthropic",
+			config:   config.LLMProvider{Type: "anthropic", Model: "claude-test", MaxTokens: 8000},
+			getenv:   getenv,
+			expected: llm.Anthropic{Model: "claude-test", APIKey: "a-key", MaxTokens: 8000},
+		},
+		{
+			name:   "anthropic without key",
+			config: config.LLMProvider{Type: "anthropic", Model: "claude-test", BaseURL: "http://localhost:8080"},
+			getenv: noEnv,
+			err:    "$ANTHROPIC_API_KEY",
+		},
+		{
+			name:     "openai",
+			config:   config.LLMProvider{Type: "openai", Model: "gpt-test"},
+			getenv:   getenv,
+			expected: llm.OpenAI{Model: "gpt-test", APIKey: "o-key"},
+		},
+		{
+			name:   "openai without key",
+			config: config.LLMProvider{Type: "openai", Model: "gpt-test"},
+			getenv: noEnv,
+			err:    "$OPENAI_API_KEY",
+		},
+		{
+			name:     "local server without key",
+			config:   config.LLMProvider{Type: "openai", Model: "llama3", BaseURL: "http://localhost:11434/v1"},
+			getenv:   noEnv,
+			expected: llm.OpenAI{BaseURL: "http://localhost:11434/v1", Model: "llama3"},
+		},
+		{
+			name:     "custom key variable",
+			config:   config.LLMProvider{Type: "openai", Model: "llama3", BaseURL: "https://api.groq.com/openai/v1", APIKeyEnv: "GROQ_KEY"},
+			getenv:   getenv,
+			expected: llm.OpenAI{BaseURL: "https://api.groq.com/openai/v1", Model: "llama3", APIKey: "g-key"},
+		},
+		{
+			name:   "custom key variable unset",
+			config: config.LLMProvider{Type: "openai", Model: "llama3", BaseURL: "http://localhost:11434/v1", APIKeyEnv: "MISSING"},
+			getenv: getenv,
+			err:    "$MISSING",
+		},
+		{
+			name:   "missing model",
+			config: config.LLMProvider{Type: "anthropic"},
+			getenv: getenv,
+			err:    "model is required",
+		},
+		{
+			name:   "unknown type",
+			config: config.LLMProvider{Type: "gemini", Model: "m"},
+			getenv: getenv,
+			err:    `unknown type "gemini"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := httpProvider(tt.config, tt.getenv)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if provider != tt.expected {
+				t.Errorf("expected %#v, got %#v", tt.expected, provider)
+			}
+		})
+	}
+}
+
+// claude -p can't read files outside its working directory, so the default
+// command must not be sent its hunks in a file
+func TestResolveLLMCommand_DefaultCommandReadsHunksFromThePrompt(t *testing.T) {
+	lookPath := func(cmd string) (string, error) { return "/usr/bin/" + cmd, nil }
+
+	for _, cfg := range []*config.Config{nil, config.Default()} {
+		result := ResolveLLMCommand(cfg, lookPath)
+		command, ok := result.Provider.(llm.Command)
+		if !ok || strings.Join(command.Args, " ") != "claude -p" {
+			t.Fatalf("expected claude -p, got %#v", result)
+		}
+		if command.Delivery != llm.DeliverStdin || llm.ReadsInputFile(command) {
+			t.Errorf("expected the hunks in the prompt on stdin, got %q delivery", command.Delivery)
+		}
+	}
+}
+
+func TestResolveLLMCommand_PromptDelivery(t *testing.T) {
+	lookPath := func(cmd string) (string, error) { return "/usr/bin/" + cmd, nil }
+
+	result := ResolveLLMCommand(&config.Config{PromptDelivery: "stdin"}, lookPath)
+	if command, ok := result.Provider.(llm.Command); !ok || command.Delivery != llm.DeliverStdin {
+		t.Errorf("expected claude with stdin delivery, got %#v", result.Provider)
+	}
+
+	result = ResolveLLMCommand(&config.Config{PromptDelivery: "file"}, lookPath)
+	if command, ok := result.Provider.(llm.Command); !ok || command.Delivery != llm.DeliverFile {
+		t.Errorf("expected file delivery when configured, got %#v", result.Provider)
+	}
+
+	result = ResolveLLMCommand(&config.Config{LLMCommand: []string{"llm"}, PromptDelivery: "pipe"}, lookPath)
+	if result.Provider != nil || !strings.Contains(result.Error, "promptDelivery") {
+		t.Errorf("expected an invalid promptDelivery error, got %#v", result)
+	}
+}
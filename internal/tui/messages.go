@@ -1,13 +1,17 @@
 package tui
 
 import (
+	"time"
+
 	"github.com/mchowning/diffstory/internal/diff"
 	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
 )
 
 // ReviewReceivedMsg is sent when a review file is created/updated
 type ReviewReceivedMsg struct {
-	Review model.Review
+	Review  model.Review
+	Watched bool // Delivered by the watcher, rather than opened by the reader
 }
 
 // ReviewClearedMsg is sent when the review file is deleted
@@ -23,12 +27,19 @@ type ErrorMsg struct {
 	Err error
 }
 
+// ReviewSavedMsg is sent when saving reader state finishes
+type ReviewSavedMsg struct {
+	Err error
+}
+
 // ClearStatusMsg is sent to clear the status bar message
 type ClearStatusMsg struct{}
 
 // GenerateSuccessMsg signals that LLM generation completed successfully.
 // The review is written to disk and will be delivered via the watcher.
-type GenerateSuccessMsg struct{}
+type GenerateSuccessMsg struct {
+	Cached bool // The classification came from the LLM cache
+}
 
 // GenerateErrorMsg indicates LLM generation failed
 type GenerateErrorMsg struct {
@@ -48,20 +59,23 @@ type CommitListErrorMsg struct {
 	Err error
 }
 
-// GenerateNeedsRetryMsg indicates validation failed and retry is needed
-type GenerateNeedsRetryMsg struct {
-	Hunks      []diff.ParsedHunk
-	MissingIDs []string
-	Context    string
-}
-
-// GenerateValidationFailedMsg indicates validation failed after retry
+// GenerateValidationFailedMsg indicates the classification still left hunks
+// out after the repair attempts
 type GenerateValidationFailedMsg struct {
 	Hunks      []diff.ParsedHunk
 	Missing    []string
-	Duplicates []string
-	Invalid    []string
-	Response   *LLMResponse // The partial response for "proceed with partial" option
+	Response   *LLMResponse // The repaired partial response, for retrying or "proceed with partial"
+	Provenance *model.Provenance
+	Attempts   int               // Repair attempts made
+	Record     *GenerationRecord // What the generation did, continued by a retry
+}
+
+// TranscriptLoadedMsg delivers the transcript of the loaded review's
+// generation, for the generation overlay
+type TranscriptLoadedMsg struct {
+	ID         string
+	Transcript *model.Transcript
+	Err        error
 }
 
 // CheckUntrackedMsg delivers the result of checking for untracked files
@@ -70,5 +84,38 @@ type CheckUntrackedMsg struct {
 	Err   error
 }
 
+// StoredSourceMsg reports whether a review of a diff source is stored for
+// the current branch, so regenerating the source can update it
+type StoredSourceMsg struct {
+	DiffSource string
+	Exists     bool
+}
+
 // StageCompleteMsg signals that git add completed successfully
 type StageCompleteMsg struct{}
+
+// HistoryListMsg delivers the archived reviews for the working directory
+type HistoryListMsg struct {
+	Entries []storage.ReviewEntry
+}
+
+// StoredReviewsMsg delivers the current reviews for the working directory,
+// one per branch and diff source
+type StoredReviewsMsg struct {
+	Entries []storage.ReviewEntry
+}
+
+// ReviewStalenessMsg delivers the result of comparing the review created at
+// CreatedAt with the current working tree. Reason is empty when it still
+// matches.
+type ReviewStalenessMsg struct {
+	CreatedAt time.Time
+	Reason    string
+	Err       error
+}
+
+// StaleCheckTickMsg triggers a periodic staleness re-check of the review
+// created at CreatedAt
+type StaleCheckTickMsg struct {
+	CreatedAt time.Time
+}
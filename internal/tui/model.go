@@ -14,7 +14,9 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mchowning/diffstory/internal/config"
 	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
 	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/prompt"
 	"github.com/mchowning/diffstory/internal/storage"
 )
 
@@ -40,14 +42,15 @@ const (
 	GenerateUIStateUntrackedWarning
 )
 
-// DefaultReviewerInstructions is the default content shown in the context input textarea.
+// DefaultReviewerInstructions is the default content shown in the context input textarea,
+// unless reviewerInstructions is configured.
 // Users can edit or extend these instructions before generating a review.
 const DefaultReviewerInstructions = `Section sizing: Combine trivial hunks (imports, formatting, small fixes) with the substantial changes they support. Don't leave trivial hunks isolated, but also don't combine unrelated hunks just to reduce section count. A substantial hunk can stand alone; trivial hunks should join their related work.
 
 Keep section narratives to 1-2 sentences explaining what and why. `
 
 type Model struct {
-	review       *model.Review
+	review       *model.Review // Review as displayed; see loadedReview
 	selected     int
 	width        int
 	height       int
@@ -73,16 +76,54 @@ type Model struct {
 	// Keybinding registry for help display
 	keybindings *KeybindingRegistry
 
+	// Review progress state. loadedReview is the review as stored; review is
+	// derived from it, leaving out reviewed sections when hideReviewed is set.
+	// sectionRefs maps each displayed section to its position in loadedReview.
+	loadedReview *model.Review
+	sectionRefs  []sectionRef
+	hideReviewed bool
+
+	// What each line of the diff viewport shows, for finding the current hunk
+	diffLineRefs []diffLineRef
+
+	// Notes state: noteTarget is set while a note is being written
+	noteInput     textinput.Model
+	noteTarget    *noteTarget
+	showNotes     bool
+	notesSelected int
+
+	// Generation overlay, nil when closed
+	generationView *generationView
+
+	// Reader state saves: one runs at a time, and pendingSave holds the
+	// latest state to save once it finishes
+	saving      bool
+	pendingSave *model.Review
+
+	// Why the displayed review no longer matches the working tree, if it doesn't
+	staleReason    string
+	skipStaleCheck bool // The review came from a file, so its commands aren't run
+
+	// Told of each review the viewer shows, so the watcher can follow it
+	onShow func(model.Review)
+
+	// Review list (history / stored reviews) state
+	reviewListMode     ReviewListMode
+	reviewListEntries  []storage.ReviewEntry
+	reviewListSelected int
+
 	// LLM generation state
-	config             *config.Config
-	store              *storage.Store
-	lookPath           LookPathFunc // For testing, defaults to DefaultLookPath
-	resolvedLLMCommand []string     // Resolved LLM command to use for generation
-	isGenerating       bool
-	generateStartTime  time.Time
-	cancelGenerate     context.CancelFunc
-	showCancelPrompt   bool
-	spinner            spinner.Model
+	config            *config.Config
+	store             *storage.Store
+	lookPath          LookPathFunc      // For testing, defaults to DefaultLookPath
+	resolvedLLM       llm.Provider      // Resolved LLM to use for generation
+	prompts           *prompt.Templates // Prompt templates; nil uses the built-in ones
+	isGenerating      bool
+	generateStartTime time.Time
+	cancelGenerate    context.CancelFunc
+	generatingSource  string // Diff source of the review being generated, shown when the watcher delivers it
+	showCancelPrompt  bool
+	spinner           spinner.Model
 
 	// Generate UI state
 	generateUIState    GenerateUIState
@@ -98,8 +139,11 @@ type Model struct {
 	rangeStartCommit  string // For commit range selection
 
 	// Context input state
-	contextInput textarea.Model
-	lastContext  string // Preserved for retry
+	contextInput         textarea.Model
+	lastContext          string // Preserved for retry
+	incrementalGenerate  bool   // Update the stored review instead of replacing it
+	incrementalAvailable bool   // A review of the selected source is stored
+	forceGenerate        bool   // Ask the LLM even if the classification is cached
 
 	// Untracked files warning state
 	untrackedFiles []string
@@ -107,7 +151,10 @@ type Model struct {
 	// Validation error state
 	parsedHunks     []diff.ParsedHunk
 	missingHunkIDs  []string
-	lastLLMResponse *LLMResponse // Cached for "proceed with partial" option
+	repairAttempts  int               // Repair attempts made before giving up
+	lastLLMResponse *LLMResponse      // Cached for retrying and the "proceed with partial" option
+	lastProvenance  *model.Provenance // Provenance of parsedHunks
+	lastRecord      *GenerationRecord // What the generation did so far, continued by a retry
 
 	// Logging
 	logger *slog.Logger
@@ -119,7 +166,31 @@ type ModelOption func(*Model)
 // WithInitialReview sets an initial review to display (bypasses watcher)
 func WithInitialReview(review *model.Review) ModelOption {
 	return func(m *Model) {
-		m.review = review
+		m.setReview(review)
+	}
+}
+
+// WithoutStaleCheck turns off checking the review against its working
+// directory, for reviews loaded from a file rather than generated here
+func WithoutStaleCheck() ModelOption {
+	return func(m *Model) {
+		m.skipStaleCheck = true
+	}
+}
+
+// WithReviewShown sets a function told of each review the viewer shows,
+// such as the watcher's Show
+func WithReviewShown(onShow func(model.Review)) ModelOption {
+	return func(m *Model) {
+		m.onShow = onShow
+	}
+}
+
+// WithPrompts sets the prompt templates used for generation, in place of the
+// built-in ones
+func WithPrompts(prompts *prompt.Templates) ModelOption {
+	return func(m *Model) {
+		m.prompts = prompts
 	}
 }
 
@@ -140,12 +211,22 @@ func NewModel(workDir string, cfg *config.Config, store *storage.Store, logger *
 	ci.Placeholder = "commit hash or ref"
 	ci.CharLimit = 64
 
+	// Initialize note input
+	ni := textinput.New()
+	ni.Placeholder = "Write a note..."
+	ni.CharLimit = 1000
+	ni.Width = 60
+
 	// Initialize context textarea
 	ctx := textarea.New()
 	ctx.Placeholder = "Additional context for the reviewer (optional)..."
 	ctx.CharLimit = 2000
 	ctx.SetWidth(60)
-	ctx.SetValue(DefaultReviewerInstructions)
+	instructions := DefaultReviewerInstructions
+	if cfg != nil && cfg.ReviewerInstructions != "" {
+		instructions = cfg.ReviewerInstructions
+	}
+	ctx.SetValue(instructions)
 	ctx.SetHeight(calcTextareaHeight(ctx.Value(), 60))
 
 	// Remove cursor line background highlight to avoid highlighting entire wrapped text
@@ -155,9 +236,11 @@ func NewModel(workDir string, cfg *config.Config, store *storage.Store, logger *
 	ctx.BlurredStyle = blurredStyle
 	ctx.ShowLineNumbers = false
 
-	// Initialize filter level from config
+	// Initialize filter level and diff sources from config
 	filterLevel := FilterLevelLow // default
+	diffSources := DefaultDiffSources(DetectBaseBranch(workDir))
 	if cfg != nil {
+		diffSources = withConfiguredDiffSources(diffSources, cfg.DiffSources)
 		switch cfg.DefaultFilterLevel {
 		case "medium":
 			filterLevel = FilterLevelMedium
@@ -178,8 +261,9 @@ func NewModel(workDir string, cfg *config.Config, store *storage.Store, logger *
 		lookPath:     DefaultLookPath,
 		spinner:      s,
 		logger:       logger,
-		diffSources:  DefaultDiffSources(DetectBaseBranch(workDir)),
+		diffSources:  diffSources,
 		commitInput:  ci,
+		noteInput:    ni,
 		contextInput: ctx,
 	}
 
@@ -191,7 +275,7 @@ func NewModel(workDir string, cfg *config.Config, store *storage.Store, logger *
 }
 
 func (m Model) Init() tea.Cmd {
-	return m.spinner.Tick
+	return tea.Batch(m.spinner.Tick, m.staleCheck())
 }
 
 func (m Model) Width() int {
@@ -230,6 +314,32 @@ func (m Model) StatusMsg() string {
 	return m.statusMsg
 }
 
+// StaleReason describes why the displayed review no longer matches the
+// working tree, or is empty if it still does
+func (m Model) StaleReason() string {
+	return m.staleReason
+}
+
+// ShowNotes reports whether the notes overlay is open
+func (m Model) ShowNotes() bool {
+	return m.showNotes
+}
+
+// ShowGeneration reports whether the generation overlay is open
+func (m Model) ShowGeneration() bool {
+	return m.generationView != nil
+}
+
+// IsWritingNote reports whether the note dialog is open
+func (m Model) IsWritingNote() bool {
+	return m.noteTarget != nil
+}
+
+// HideReviewed reports whether reviewed sections are hidden
+func (m Model) HideReviewed() bool {
+	return m.hideReviewed
+}
+
 func (m Model) FocusedPanel() Panel {
 	return m.focusedPanel
 }
@@ -300,6 +410,12 @@ func (m Model) GenerateUIState() GenerateUIState {
 	return m.generateUIState
 }
 
+// IncrementalGenerate reports whether generation will update the stored
+// review of the selected source rather than replace it
+func (m Model) IncrementalGenerate() bool {
+	return m.incrementalGenerate
+}
+
 func (m Model) FilterLevel() FilterLevel {
 	return m.filterLevel
 }
@@ -338,6 +454,18 @@ func (m Model) SetCancelFunc(cancel func()) Model {
 	return m
 }
 
+func (m Model) ShowHistory() bool {
+	return m.reviewListMode == ReviewListHistory
+}
+
+func (m Model) ShowStoredReviews() bool {
+	return m.reviewListMode == ReviewListStored
+}
+
+func (m Model) ReviewListEntries() []storage.ReviewEntry {
+	return m.reviewListEntries
+}
+
 func (m Model) ShowCancelPrompt() bool {
 	return m.showCancelPrompt
 }
@@ -371,6 +499,7 @@ func (m Model) Commits() []CommitInfo {
 }
 
 func (m *Model) updateViewportContent() {
+	m.diffLineRefs = nil
 	if m.review == nil {
 		m.viewport.SetContent("")
 		return
@@ -382,19 +511,21 @@ func (m *Model) updateViewportContent() {
 	}
 
 	section := sections[m.selected]
-	var content string
+	include := func(model.Hunk) bool { return true }
 
 	if m.flattenedFiles != nil && m.selectedFile < len(m.flattenedFiles) {
 		selectedNode := m.flattenedFiles[m.selectedFile]
 		if selectedNode.IsDir {
-			content = m.renderDiffForDirectory(section, selectedNode.FullPath)
+			include = func(h model.Hunk) bool {
+				return strings.HasPrefix(h.File, selectedNode.FullPath+"/") || h.File == selectedNode.FullPath
+			}
 		} else {
-			content = m.renderDiffForFile(section, selectedNode.FullPath)
+			include = func(h model.Hunk) bool { return h.File == selectedNode.FullPath }
 		}
-	} else {
-		content = m.renderDiffContent(section)
 	}
 
+	content, refs := m.renderHunks(section, include)
+	m.diffLineRefs = refs
 	m.viewport.SetContent(content)
 }
 
@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/schema"
+)
+
+const outlinePromptAddendum = `
+
+## Outline Only
+
+This is the first of two passes. Each section's what and why are rewritten afterwards, in a
+request of their own that reads only that section's hunks, so spend this request on the story's
+structure: which hunks belong together, in what order, and under which titles. Keep the what and
+why of each section you write to a short phrase.`
+
+const narrativePromptTemplate = `You are a code review assistant writing one section of a review titled %q.
+The review's chapters and sections, in reading order, are:
+%s
+
+Write the narrative of the section with id %q, titled %q, whose hunks are below. Each of the other
+sections is written in a request of its own, so describe only this one, and refer to other sections
+by title where this change depends on them.
+
+%s
+
+Respond with JSON in this exact format (no markdown fences, no explanation text):
+{
+  "what": "What the section's hunks change",
+  "why": "Why they change it"
+}
+
+- what: a short paragraph (two to four sentences) on what the hunks change, naming the functions,
+  types and behaviour involved.
+- why: a short paragraph on the motivation, and on what a reviewer should check, such as edge
+  cases, compatibility or missing tests.
+- Base both on the hunks. When the reason is not evident from them, say so rather than guess.
+
+## Response Schema
+
+Your response must validate against this JSON Schema:
+%s
+%s`
+
+// narrativeResponse is the narrative pass's what and why for one section
+type narrativeResponse struct {
+	What string `json:"what" desc:"A short paragraph describing what the section's hunks change"`
+	Why  string `json:"why" desc:"A short paragraph explaining why, and what a reviewer should check"`
+}
+
+// narrativeSchema returns the JSON Schema of the narrative pass's response
+func narrativeSchema() *schema.Schema {
+	return schema.For(narrativeResponse{}, "diffstory section narrative",
+		"What and why of one section of a review, returned by the LLM")
+}
+
+// narrateSections rewrites the what and why of each of response's sections
+// that narrate accepts, with a request per section, running up to
+// concurrency at once. Hunks are read from files in inputDir when it is set.
+// Exchanges are recorded in section order, whichever request finishes first.
+// A section whose request fails keeps the outline's text. It returns the
+// number of sections narrated and the number attempted.
+func narrateSections(caller llmCaller, response *LLMResponse, hunks []diff.ParsedHunk, inputDir, contextAddendum string, concurrency int, narrate func(LLMSection) bool) (int, int) {
+	outline, err := responseOutlineJSON(*response)
+	if err != nil {
+		if caller.logger != nil {
+			caller.logger.Warn("narrative pass skipped", "error", err)
+		}
+		return 0, 0
+	}
+	schemaJSON, err := json.Marshal(narrativeSchema())
+	if err != nil {
+		if caller.logger != nil {
+			caller.logger.Warn("narrative pass skipped", "error", err)
+		}
+		return 0, 0
+	}
+	byID := make(map[string]diff.ParsedHunk, len(hunks))
+	for _, h := range hunks {
+		byID[h.ID] = h
+	}
+
+	type job struct {
+		section   *LLMSection
+		record    *GenerationRecord // The exchange, until the requests finish
+		narrative *narrativeResponse
+		err       error
+	}
+	var jobs []*job
+	for ci := range response.Chapters {
+		for si := range response.Chapters[ci].Sections {
+			if section := &response.Chapters[ci].Sections[si]; narrate(*section) {
+				jobs = append(jobs, &job{section: section, record: &GenerationRecord{}})
+			}
+		}
+	}
+
+	semaphore := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		var sectionHunks []diff.ParsedHunk
+		for _, ref := range j.section.Hunks {
+			if h, ok := byID[ref.ID]; ok {
+				sectionHunks = append(sectionHunks, h)
+			}
+		}
+		sectionCaller := caller
+		sectionCaller.record = j.record
+		wg.Go(func() {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			j.narrative, j.err = narrateSection(sectionCaller, response.Title, outline, *j.section, sectionHunks,
+				inputDir, fmt.Sprintf("section-%d.json", i+1), string(schemaJSON), contextAddendum)
+		})
+	}
+	wg.Wait()
+
+	narrated := 0
+	for _, j := range jobs {
+		if caller.record != nil {
+			caller.record.absorb(j.record)
+		}
+		if j.err != nil {
+			if caller.logger != nil {
+				caller.logger.Warn("narrative request failed, keeping the outline's text", "section", j.section.ID, "error", j.err)
+			}
+			continue
+		}
+		if j.narrative.What != "" {
+			j.section.What = j.narrative.What
+		}
+		if j.narrative.Why != "" {
+			j.section.Why = j.narrative.Why
+		}
+		narrated++
+	}
+	return narrated, len(jobs)
+}
+
+// narrateSection asks the LLM for the what and why of section, whose hunks
+// are written to inputName in inputDir, or put in the prompt without one
+func narrateSection(caller llmCaller, title, outline string, section LLMSection, hunks []diff.ParsedHunk, inputDir, inputName, schemaJSON, contextAddendum string) (*narrativeResponse, error) {
+	hunksJSON, err := buildHunksJSON(hunks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build hunks JSON: %w", err)
+	}
+	input, recorded := fmt.Sprintf(inlineInputTemplate, hunksJSON), ""
+	if inputDir != "" {
+		path, err := writeHunksInput(inputDir, inputName, hunksJSON, caller.logger)
+		if err != nil {
+			return nil, err
+		}
+		input, recorded = fmt.Sprintf(fileInputTemplate, path), hunksJSON
+	}
+
+	narrativePrompt := fmt.Sprintf(narrativePromptTemplate, title, outline, section.ID, section.Title, input, schemaJSON, contextAddendum)
+	output, err := caller.call("narrative: "+section.Title, narrativePrompt, recorded, schemaJSON)
+	if err != nil {
+		return nil, err
+	}
+	var narrative narrativeResponse
+	repaired, err := decodeLLMJSON(output, &narrative, caller.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+	if repaired && caller.record != nil {
+		caller.record.markJSONRepaired()
+	}
+	return &narrative, nil
+}
@@ -0,0 +1,143 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+const twoSectionOutline = `{"title":"T","chapters":[{"id":"c","title":"C","sections":[` +
+	`{"id":"a","title":"A","what":"a short","why":"a short","hunks":[{"id":"a.go::1","importance":"high"}]},` +
+	`{"id":"b","title":"B","what":"b short","why":"b short","hunks":[{"id":"b.go::1","importance":"low"}]}]}]}`
+
+// narrativeScript answers the outline request with twoSectionOutline and
+// each narrative request with the what and why of its section, answering
+// section a last. Section b's request fails when failB is set.
+func narrativeScript(failB bool) string {
+	b := `echo '{"what":"B in depth","why":"B because"}'`
+	if failB {
+		b = "exit 1"
+	}
+	return `case "$1" in
+*"Outline Only"*) echo '` + twoSectionOutline + `' ;;
+*'id "a"'*) sleep 0.2; echo '{"what":"A in depth","why":"A because"}' ;;
+*'id "b"'*) ` + b + ` ;;
+esac`
+}
+
+func twoPassParams(t *testing.T, failB bool) (*storage.Store, string, GenerateParams) {
+	t.Helper()
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(twoFilePatch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return store, workDir, GenerateParams{
+		DiffCommand:          []string{"cat", "changes.patch"},
+		DiffSource:           "Patch",
+		LLM:                  llm.Command{Args: []string{"sh", "-c", narrativeScript(failB), "llm"}, Delivery: llm.DeliverArgument},
+		CacheMaxBytes:        1000000,
+		TwoPass:              true,
+		NarrativeConcurrency: 2,
+	}
+}
+
+func TestGenerateReviewCmd_TwoPassNarratesEachSection(t *testing.T) {
+	store, workDir, params := twoPassParams(t, false)
+
+	if msg := generateReviewCmd(context.Background(), workDir, store, nil, params)(); msg != (GenerateSuccessMsg{}) {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+	review := storedReview(store, workDir, "", "Patch")
+	sections := review.Chapters[0].Sections
+	if sections[0].What != "A in depth" || sections[0].Why != "A because" || sections[1].What != "B in depth" || sections[1].Why != "B because" {
+		t.Errorf("expected each section's narrative, got %+v", sections)
+	}
+	if len(sections[0].Hunks) != 1 || sections[0].Hunks[0].ID != "a.go::1" {
+		t.Errorf("expected the outline's hunk assignment, got %+v", sections[0].Hunks)
+	}
+
+	generation := review.Generation
+	if !generation.TwoPass || generation.Narrated != 2 || generation.Requests != 3 {
+		t.Errorf("unexpected generation record %+v", generation)
+	}
+	// Exchanges follow the sections, though section a's narrative came last
+	transcript, err := store.ReadTranscript(workDir, generation.Transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var purposes []string
+	for _, exchange := range transcript.Exchanges {
+		purposes = append(purposes, exchange.Purpose)
+	}
+	if len(purposes) != 3 || purposes[0] != "classification" || purposes[1] != "narrative: A" || purposes[2] != "narrative: B" {
+		t.Errorf("unexpected exchanges %v", purposes)
+	}
+
+	// The narrated classification is cached
+	if msg := generateReviewCmd(context.Background(), workDir, store, nil, params)(); msg != (GenerateSuccessMsg{Cached: true}) {
+		t.Fatalf("expected a cached GenerateSuccessMsg, got %#v", msg)
+	}
+	if what := storedReview(store, workDir, "", "Patch").Chapters[0].Sections[0].What; what != "A in depth" {
+		t.Errorf("expected the cached narrative, got %q", what)
+	}
+}
+
+func TestGenerateReviewCmd_FailedNarrativeKeepsOutline(t *testing.T) {
+	store, workDir, params := twoPassParams(t, true)
+
+	if msg := generateReviewCmd(context.Background(), workDir, store, nil, params)(); msg != (GenerateSuccessMsg{}) {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+	review := storedReview(store, workDir, "", "Patch")
+	sections := review.Chapters[0].Sections
+	if sections[0].What != "A in depth" || sections[1].What != "b short" || sections[1].Why != "b short" {
+		t.Errorf("expected section b to keep the outline's text, got %+v", sections)
+	}
+	if review.Generation.Narrated != 1 {
+		t.Errorf("Narrated = %d, want 1", review.Generation.Narrated)
+	}
+
+	// A partly narrated classification is not cached
+	if msg := generateReviewCmd(context.Background(), workDir, store, nil, params)(); msg != (GenerateSuccessMsg{}) {
+		t.Errorf("expected the classification to be made again, got %#v", msg)
+	}
+}
+
+func TestNarrateSections_SkipsSectionsNotToNarrate(t *testing.T) {
+	hunks, err := diff.Parse(twoFilePatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := LLMResponse{Title: "T", Chapters: []LLMChapter{{ID: "c", Title: "C", Sections: []LLMSection{
+		{ID: "a", Title: "A", What: "kept", Why: "kept", Hunks: []LLMHunkRef{{ID: "a.go::1"}}},
+		{ID: "b", Title: "B", What: "b short", Why: "b short", Hunks: []LLMHunkRef{{ID: "b.go::1"}}},
+	}}}}
+	record := newGenerationRecord(nil, nil)
+	caller := llmCaller{
+		ctx:      context.Background(),
+		workDir:  t.TempDir(),
+		provider: llm.Command{Args: []string{"sh", "-c", narrativeScript(false), "llm"}, Delivery: llm.DeliverArgument},
+		record:   record,
+	}
+
+	narrated, attempted := narrateSections(caller, &response, hunks, "", "", 1, func(s LLMSection) bool { return s.ID != "a" })
+	if narrated != 1 || attempted != 1 {
+		t.Errorf("narrated %d of %d sections, want 1 of 1", narrated, attempted)
+	}
+	sections := response.Chapters[0].Sections
+	if sections[0].What != "kept" || sections[1].What != "B in depth" {
+		t.Errorf("unexpected sections %+v", sections)
+	}
+	if record.Generation.Requests != 1 {
+		t.Errorf("Requests = %d, want 1", record.Generation.Requests)
+	}
+}
@@ -0,0 +1,344 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// noteTarget is the hunk or line a note is being written for
+type noteTarget struct {
+	Hunk model.Hunk
+	Line int // 1-based line within the hunk's diff, 0 for the whole hunk
+}
+
+// noteEntry is a note as listed in the notes overlay
+type noteEntry struct {
+	Index    int // Index into the loaded review's notes
+	Location string
+	Text     string
+}
+
+// noteLocation describes where a note points, e.g. "auth.go:42"
+func noteLocation(hunk model.Hunk, line int) string {
+	number := hunk.StartLine
+	if line > 0 {
+		if n := diff.LineNumber(hunk.Diff, line-1); n > 0 {
+			number = n
+		}
+	}
+	return fmt.Sprintf("%s:%d", hunk.File, number)
+}
+
+// findHunk returns the hunk with the given identity in review, along with
+// its section's position
+func findHunk(review *model.Review, hunkID string) (model.Hunk, sectionRef, int, bool) {
+	for ci, ch := range review.Chapters {
+		for si, s := range ch.Sections {
+			for hi, h := range s.Hunks {
+				if h.Identity() == hunkID {
+					return h, sectionRef{Chapter: ci, Section: si}, hi, true
+				}
+			}
+		}
+	}
+	return model.Hunk{}, sectionRef{}, -1, false
+}
+
+// notesByHunk groups the loaded review's notes by hunk identity
+func (m Model) notesByHunk() map[string][]model.Note {
+	if m.loadedReview == nil || len(m.loadedReview.Notes) == 0 {
+		return nil
+	}
+	byHunk := make(map[string][]model.Note)
+	for _, n := range m.loadedReview.Notes {
+		byHunk[n.HunkID] = append(byHunk[n.HunkID], n)
+	}
+	return byHunk
+}
+
+// currentDiffLine returns the hunk and 0-based diff line shown first at or
+// below the top of the diff viewport, or -1, -1 if there is none
+func (m Model) currentDiffLine() (int, int) {
+	for i := m.viewport.YOffset; i < len(m.diffLineRefs); i++ {
+		if ref := m.diffLineRefs[i]; ref.Line >= 0 {
+			return ref.Hunk, ref.Line
+		}
+	}
+	return -1, -1
+}
+
+// startNote opens the note dialog for the hunk at the top of the diff
+// viewport, or for its top line when onLine is set
+func (m Model) startNote(onLine bool) (Model, tea.Cmd) {
+	sections := m.review.AllSections()
+	if m.selected >= len(sections) {
+		return m, nil
+	}
+	hunkIdx, lineIdx := m.currentHunkIndex(), -1
+	if onLine {
+		hunkIdx, lineIdx = m.currentDiffLine()
+	}
+	if hunkIdx < 0 {
+		m.statusMsg = "No hunk to annotate"
+		return m, tea.Tick(3*time.Second, func(time.Time) tea.Msg {
+			return ClearStatusMsg{}
+		})
+	}
+
+	target := &noteTarget{Hunk: sections[m.selected].Hunks[hunkIdx]}
+	if lineIdx >= 0 {
+		// Start below the @@ header, on the first line of actual changes
+		if lineIdx == 0 && strings.Count(target.Hunk.Diff, "\n") > 0 {
+			lineIdx = 1
+		}
+		target.Line = lineIdx + 1
+	}
+
+	m.noteTarget = target
+	m.noteInput.SetValue("")
+	m.noteInput.Focus()
+	return m, textinput.Blink
+}
+
+// updateNoteInput handles key events while a note is being written
+func (m Model) updateNoteInput(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		text := strings.TrimSpace(m.noteInput.Value())
+		target := m.noteTarget
+		m.noteTarget = nil
+		m.noteInput.Blur()
+		if text == "" || m.loadedReview == nil {
+			return m, nil
+		}
+		updated := cloneReview(*m.loadedReview)
+		updated.Notes = append(updated.Notes, model.Note{
+			HunkID:      target.Hunk.Identity(),
+			File:        target.Hunk.File,
+			Line:        target.Line,
+			Text:        text,
+			CreatedAt:   time.Now(),
+			Fingerprint: target.Hunk.ContentFingerprint(),
+		})
+		m.setReview(&updated)
+		m.updateViewportContent()
+		return m, m.saveReviewState(updated)
+	case tea.KeyEsc:
+		m.noteTarget = nil
+		m.noteInput.Blur()
+		return m, nil
+	case tea.KeyUp, tea.KeyDown:
+		// Move a line note to the previous or next line of the hunk
+		if m.noteTarget.Line > 0 {
+			target := *m.noteTarget
+			lineCount := strings.Count(target.Hunk.Diff, "\n") + 1
+			if msg.Type == tea.KeyUp {
+				target.Line = max(1, target.Line-1)
+			} else {
+				target.Line = min(lineCount, target.Line+1)
+			}
+			m.noteTarget = &target
+		}
+		return m, nil
+	default:
+		var cmd tea.Cmd
+		m.noteInput, cmd = m.noteInput.Update(msg)
+		return m, cmd
+	}
+}
+
+// renderNoteInput renders the dialog for writing a note
+func (m Model) renderNoteInput() string {
+	var sb strings.Builder
+	target := m.noteTarget
+	diffLines := strings.Split(target.Hunk.Diff, "\n")
+	kind, context, help := "hunk", diffLines[0], "Enter  save\nEsc  cancel"
+	if target.Line > 0 {
+		kind, context, help = "line", diffLines[target.Line-1], "Enter  save\n↑/↓  move to previous/next line\nEsc  cancel"
+	}
+	sb.WriteString(fmt.Sprintf("Note on %s %s\n\n", kind, noteLocation(target.Hunk, target.Line)))
+
+	dialogWidth := min(m.width-4, 100)
+	sb.WriteString(dimStyle.Render(truncate(context, max(dialogWidth-8, 10))))
+	sb.WriteString("\n\n")
+	sb.WriteString(m.noteInput.View())
+	sb.WriteString("\n\n")
+	sb.WriteString(helpStyle.Render(help))
+
+	dialog := dialogStyle.Width(dialogWidth).Render(sb.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
+}
+
+// noteEntries lists the loaded review's notes in story order: by section,
+// hunk and line. Notes whose hunk is no longer in the review come last.
+func (m Model) noteEntries() []noteEntry {
+	if m.loadedReview == nil {
+		return nil
+	}
+	var entries []noteEntry
+	listed := make(map[int]bool)
+	for _, section := range m.loadedReview.AllSections() {
+		for _, hunk := range section.Hunks {
+			id := hunk.Identity()
+			var hunkEntries []noteEntry
+			for i, n := range m.loadedReview.Notes {
+				if n.HunkID == id && !listed[i] {
+					listed[i] = true
+					hunkEntries = append(hunkEntries, noteEntry{Index: i, Location: noteLocation(hunk, n.Line), Text: n.Text})
+				}
+			}
+			// Hunk notes first, then line notes from top to bottom
+			sort.SliceStable(hunkEntries, func(a, b int) bool {
+				return m.loadedReview.Notes[hunkEntries[a].Index].Line < m.loadedReview.Notes[hunkEntries[b].Index].Line
+			})
+			entries = append(entries, hunkEntries...)
+		}
+	}
+	for i, n := range m.loadedReview.Notes {
+		if !listed[i] {
+			entries = append(entries, noteEntry{Index: i, Location: n.File + " (hunk no longer in review)", Text: n.Text})
+		}
+	}
+	return entries
+}
+
+// openNotes shows the notes overlay, or a status message when there are none
+func (m Model) openNotes() (Model, tea.Cmd) {
+	if len(m.noteEntries()) == 0 {
+		m.statusMsg = "No notes yet: press c on a hunk or C on a line to add one"
+		return m, tea.Tick(3*time.Second, func(time.Time) tea.Msg {
+			return ClearStatusMsg{}
+		})
+	}
+	m.showNotes = true
+	m.notesSelected = 0
+	return m, nil
+}
+
+// updateNotes handles key events while the notes overlay is open
+func (m Model) updateNotes(msg tea.KeyMsg) (Model, tea.Cmd) {
+	entries := m.noteEntries()
+	switch msg.String() {
+	case "j", "down":
+		if m.notesSelected < len(entries)-1 {
+			m.notesSelected++
+		}
+	case "k", "up":
+		if m.notesSelected > 0 {
+			m.notesSelected--
+		}
+	case "enter":
+		if m.notesSelected < len(entries) {
+			m.showNotes = false
+			m.jumpToNote(m.loadedReview.Notes[entries[m.notesSelected].Index])
+		}
+	case "d":
+		if m.notesSelected < len(entries) {
+			updated := cloneReview(*m.loadedReview)
+			idx := entries[m.notesSelected].Index
+			updated.Notes = append(updated.Notes[:idx], updated.Notes[idx+1:]...)
+			m.setReview(&updated)
+			m.updateViewportContent()
+			if m.notesSelected >= len(entries)-1 {
+				m.notesSelected = max(0, len(entries)-2)
+			}
+			if len(updated.Notes) == 0 {
+				m.showNotes = false
+			}
+			return m, m.saveReviewState(updated)
+		}
+	case "esc", "q", "N":
+		m.showNotes = false
+	}
+	return m, nil
+}
+
+// jumpToNote selects the section and file containing a note's hunk and
+// scrolls the diff to the annotated line
+func (m *Model) jumpToNote(note model.Note) {
+	hunk, ref, hunkIdx, ok := findHunk(m.loadedReview, note.HunkID)
+	if !ok {
+		return
+	}
+	if m.hideReviewed && m.loadedReview.Chapters[ref.Chapter].Sections[ref.Section].IsReviewed() {
+		m.toggleHideReviewed()
+	}
+	for i, r := range m.sectionRefs {
+		if r == ref {
+			m.selected = i
+			break
+		}
+	}
+	m.sectionScrollOffset = CalculateScrollOffset(
+		m.sectionScrollOffset,
+		m.selected,
+		m.review.SectionCount(),
+		EstimateSectionVisibleCount(m.sectionPanelHeight()),
+	)
+	m.updateFileTree()
+	for i, node := range m.flattenedFiles {
+		if !node.IsDir && node.FullPath == hunk.File {
+			m.selectedFile = i
+			break
+		}
+	}
+	m.filesScrollOffset = CalculateScrollOffset(
+		m.filesScrollOffset,
+		m.selectedFile,
+		len(m.flattenedFiles),
+		EstimateFilesVisibleCount(m.filesPanelHeight()),
+	)
+	m.updateViewportContent()
+	m.focusedPanel = PanelDiff
+
+	m.viewport.GotoTop()
+	for i, r := range m.diffLineRefs {
+		if r.Hunk == hunkIdx && (note.Line == 0 || r.Line == note.Line-1) {
+			m.viewport.SetYOffset(i)
+			break
+		}
+	}
+}
+
+// renderNotes renders the notes overlay
+func (m Model) renderNotes() string {
+	var sb strings.Builder
+	sb.WriteString("Notes\n\n")
+
+	entries := m.noteEntries()
+	dialogWidth := min(m.width-4, 100)
+	maxDisplay := max(min(m.height-12, 20), 5)
+
+	start := 0
+	if m.notesSelected >= maxDisplay {
+		start = m.notesSelected - maxDisplay + 1
+	}
+
+	for i := start; i < len(entries) && i < start+maxDisplay; i++ {
+		entry := entries[i]
+		prefix := "  "
+		style := normalStyle
+		if i == m.notesSelected {
+			prefix = "› "
+			style = selectedStyle
+		}
+		location := truncate(entry.Location, 40)
+		text := truncate(strings.ReplaceAll(entry.Text, "\n", " "), max(dialogWidth-len(location)-12, 10))
+		sb.WriteString(style.Render(prefix+text) + dimStyle.Render("  "+location))
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(helpStyle.Render("j/k  navigate\nEnter  go to note\nd  delete\nEsc  close"))
+
+	dialog := dialogStyle.Width(dialogWidth).Render(sb.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
+}
@@ -0,0 +1,164 @@
+package tui_test
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mchowning/diffstory/internal/storage"
+	"github.com/mchowning/diffstory/internal/tui"
+)
+
+func typeText(m tui.Model, text string) tui.Model {
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	return updated.(tui.Model)
+}
+
+func pressType(m tui.Model, keyType tea.KeyType) (tui.Model, tea.Cmd) {
+	updated, cmd := m.Update(tea.KeyMsg{Type: keyType})
+	return updated.(tui.Model), cmd
+}
+
+func TestNotes_AddHunkNote(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+
+	m, _ = pressKey(m, "c")
+	if !m.IsWritingNote() {
+		t.Fatal("expected note dialog to open")
+	}
+	if !strings.Contains(m.View(), "Note on hunk a.go:1") {
+		t.Error("expected dialog to name the annotated hunk")
+	}
+	m = typeText(m, "check the error path")
+	m, _ = pressType(m, tea.KeyEnter)
+
+	if m.IsWritingNote() {
+		t.Fatal("expected dialog to close after saving")
+	}
+	notes := m.Review().Notes
+	if len(notes) != 1 {
+		t.Fatalf("expected 1 note, got %d", len(notes))
+	}
+	if notes[0].HunkID != "a.go::1" || notes[0].Line != 0 || notes[0].Text != "check the error path" {
+		t.Errorf("unexpected note %+v", notes[0])
+	}
+	if !strings.Contains(m.View(), "✎ check the error path") {
+		t.Error("expected note marker in the diff pane")
+	}
+}
+
+func TestNotes_AddLineNoteAndMoveLine(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+
+	m, _ = pressKey(m, "C")
+	if !strings.Contains(m.View(), "Note on line a.go:1") {
+		t.Fatalf("expected line note to start on the first changed line:\n%s", m.View())
+	}
+	m, _ = pressType(m, tea.KeyUp)
+	m = typeText(m, "header")
+	m, _ = pressType(m, tea.KeyEnter)
+
+	notes := m.Review().Notes
+	if len(notes) != 1 || notes[0].Line != 1 {
+		t.Fatalf("expected note on the first diff line, got %+v", notes)
+	}
+}
+
+func TestNotes_EscCancels(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+
+	m, _ = pressKey(m, "c")
+	m = typeText(m, "discard me")
+	m, _ = pressType(m, tea.KeyEsc)
+
+	if m.IsWritingNote() || len(m.Review().Notes) != 0 {
+		t.Error("expected esc to discard the note")
+	}
+}
+
+func TestNotes_OverlayListsAndJumpsToNotes(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+	m, _ = pressKey(m, "j")
+	m, _ = pressKey(m, "c")
+	m = typeText(m, "second section note")
+	m, _ = pressType(m, tea.KeyEnter)
+	m, _ = pressKey(m, "k")
+
+	m, _ = pressKey(m, "N")
+	if !m.ShowNotes() {
+		t.Fatal("expected notes overlay to open")
+	}
+	view := m.View()
+	if !strings.Contains(view, "second section note") || !strings.Contains(view, "b.go:1") {
+		t.Errorf("expected overlay to list the note with its location:\n%s", view)
+	}
+
+	m, _ = pressType(m, tea.KeyEnter)
+	if m.ShowNotes() {
+		t.Error("expected overlay to close after jumping")
+	}
+	if m.Selected() != 1 {
+		t.Errorf("expected jump to select the note's section, got %d", m.Selected())
+	}
+	if m.FocusedPanel() != tui.PanelDiff {
+		t.Errorf("expected jump to focus the diff panel, got %v", m.FocusedPanel())
+	}
+}
+
+func TestNotes_DeleteFromOverlay(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+	m, _ = pressKey(m, "c")
+	m = typeText(m, "temporary")
+	m, _ = pressType(m, tea.KeyEnter)
+
+	m, _ = pressKey(m, "N")
+	m, _ = pressKey(m, "d")
+
+	if len(m.Review().Notes) != 0 {
+		t.Errorf("expected note to be deleted, got %+v", m.Review().Notes)
+	}
+	if m.ShowNotes() {
+		t.Error("expected overlay to close when the last note is deleted")
+	}
+}
+
+func TestNotes_OverlayWithoutNotesShowsStatus(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+
+	m, _ = pressKey(m, "N")
+
+	if m.ShowNotes() {
+		t.Error("overlay should not open without notes")
+	}
+	if !strings.Contains(m.StatusMsg(), "No notes") {
+		t.Errorf("expected status message, got %q", m.StatusMsg())
+	}
+}
+
+func TestNotes_PersistWithReview(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+	review := progressTestReview(workDir)
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	m := newProgressModel(t, store, review)
+
+	m, _ = pressKey(m, "c")
+	m = typeText(m, "persist me")
+	_, cmd := pressType(m, tea.KeyEnter)
+	if msg := cmd(); msg != (tui.ReviewSavedMsg{}) {
+		t.Fatalf("expected save to succeed, got %#v", msg)
+	}
+
+	loaded, err := store.Read(workDir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(loaded.Notes) != 1 || loaded.Notes[0].Text != "persist me" {
+		t.Errorf("expected note to be stored with the review, got %+v", loaded.Notes)
+	}
+}
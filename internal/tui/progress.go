@@ -0,0 +1,257 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+// sectionRef locates a section within the loaded review
+type sectionRef struct {
+	Chapter int
+	Section int
+}
+
+// sameReview reports whether a and b are versions of the same generated
+// review, as happens when the store is updated with the reader's progress.
+// Reviews without a creation time cannot be told apart and never match.
+func sameReview(a, b model.Review) bool {
+	return !a.CreatedAt.IsZero() &&
+		a.WorkingDirectory == b.WorkingDirectory &&
+		a.Branch == b.Branch &&
+		a.DiffSource == b.DiffSource &&
+		a.CreatedAt.Equal(b.CreatedAt)
+}
+
+// sameReviewKey reports whether a and b are reviews of the same directory,
+// branch and diff source, which are stored in one file
+func sameReviewKey(a, b model.Review) bool {
+	return a.WorkingDirectory == b.WorkingDirectory &&
+		a.Branch == b.Branch &&
+		a.DiffSource == b.DiffSource
+}
+
+// setReview replaces the loaded review and rebuilds the displayed one
+func (m *Model) setReview(review *model.Review) {
+	m.loadedReview = review
+	m.applyReviewedFilter()
+}
+
+// applyReviewedFilter derives the displayed review from the loaded one,
+// leaving out reviewed sections (and chapters left empty) when hideReviewed
+// is set
+func (m *Model) applyReviewedFilter() {
+	m.sectionRefs = nil
+	if m.loadedReview == nil {
+		m.review = nil
+		return
+	}
+
+	displayed := *m.loadedReview
+	displayed.Chapters = nil
+	for ci, ch := range m.loadedReview.Chapters {
+		chapter := ch
+		chapter.Sections = nil
+		for si, s := range ch.Sections {
+			if m.hideReviewed && s.IsReviewed() {
+				continue
+			}
+			chapter.Sections = append(chapter.Sections, s)
+			m.sectionRefs = append(m.sectionRefs, sectionRef{Chapter: ci, Section: si})
+		}
+		if len(chapter.Sections) > 0 || !m.hideReviewed {
+			displayed.Chapters = append(displayed.Chapters, chapter)
+		}
+	}
+	m.review = &displayed
+}
+
+// clampSelection keeps the selected section within the displayed review
+func (m *Model) clampSelection() {
+	count := 0
+	if m.review != nil {
+		count = m.review.SectionCount()
+	}
+	if m.selected >= count {
+		m.selected = max(0, count-1)
+		m.sectionScrollOffset = CalculateScrollOffset(
+			m.sectionScrollOffset,
+			m.selected,
+			count,
+			EstimateSectionVisibleCount(m.sectionPanelHeight()),
+		)
+		m.updateFileTree()
+		m.viewport.GotoTop()
+	}
+}
+
+// cloneReview copies a review deeply enough that its chapters, sections,
+// hunks and notes can be modified without affecting the original
+func cloneReview(r model.Review) model.Review {
+	clone := r
+	clone.Notes = append([]model.Note(nil), r.Notes...)
+	clone.Chapters = make([]model.Chapter, len(r.Chapters))
+	for ci, ch := range r.Chapters {
+		clone.Chapters[ci] = ch
+		clone.Chapters[ci].Sections = make([]model.Section, len(ch.Sections))
+		for si, s := range ch.Sections {
+			clone.Chapters[ci].Sections[si] = s
+			clone.Chapters[ci].Sections[si].Hunks = append([]model.Hunk(nil), s.Hunks...)
+		}
+	}
+	return clone
+}
+
+// toggleSectionReviewed flips the reviewed state of the selected section.
+// Unmarking a section also unmarks its hunks, so it is no longer counted as
+// reviewed through them.
+func (m *Model) toggleSectionReviewed() tea.Cmd {
+	if m.loadedReview == nil || m.selected >= len(m.sectionRefs) {
+		return nil
+	}
+	ref := m.sectionRefs[m.selected]
+	updated := cloneReview(*m.loadedReview)
+	section := &updated.Chapters[ref.Chapter].Sections[ref.Section]
+
+	reviewed := !section.IsReviewed()
+	section.Reviewed = reviewed
+	if !reviewed {
+		for i := range section.Hunks {
+			section.Hunks[i].Reviewed = false
+		}
+	}
+
+	m.setReview(&updated)
+	if m.hideReviewed && reviewed {
+		// The section has disappeared; the next one takes its place
+		m.clampSelection()
+		m.updateFileTree()
+		m.viewport.GotoTop()
+	}
+	m.updateViewportContent()
+	return m.saveReviewState(updated)
+}
+
+// toggleHunkReviewed flips the reviewed state of the hunk at the top of the
+// diff viewport
+func (m *Model) toggleHunkReviewed() tea.Cmd {
+	hunkIdx := m.currentHunkIndex()
+	if m.loadedReview == nil || hunkIdx < 0 || m.selected >= len(m.sectionRefs) {
+		return nil
+	}
+	ref := m.sectionRefs[m.selected]
+	updated := cloneReview(*m.loadedReview)
+	section := &updated.Chapters[ref.Chapter].Sections[ref.Section]
+	hunk := &section.Hunks[hunkIdx]
+	hunk.Reviewed = !hunk.Reviewed
+	if !hunk.Reviewed {
+		// A section marked as a whole is no longer fully reviewed
+		section.Reviewed = false
+	}
+
+	m.setReview(&updated)
+	if m.hideReviewed && section.IsReviewed() {
+		m.clampSelection()
+		m.updateFileTree()
+		m.viewport.GotoTop()
+	}
+	m.updateViewportContent()
+	return m.saveReviewState(updated)
+}
+
+// toggleHideReviewed shows or hides reviewed sections, keeping the selected
+// section selected when it remains visible
+func (m *Model) toggleHideReviewed() {
+	if m.loadedReview == nil {
+		m.hideReviewed = !m.hideReviewed
+		return
+	}
+	var selectedRef *sectionRef
+	if m.selected < len(m.sectionRefs) {
+		ref := m.sectionRefs[m.selected]
+		selectedRef = &ref
+	}
+
+	m.hideReviewed = !m.hideReviewed
+	m.applyReviewedFilter()
+
+	m.selected = 0
+	if selectedRef != nil {
+		// Select the same section, or the first one after it if it was hidden
+		for i, ref := range m.sectionRefs {
+			if ref.Chapter > selectedRef.Chapter || (ref.Chapter == selectedRef.Chapter && ref.Section >= selectedRef.Section) {
+				m.selected = i
+				break
+			}
+		}
+	}
+	m.clampSelection()
+	m.sectionScrollOffset = CalculateScrollOffset(
+		m.sectionScrollOffset,
+		m.selected,
+		m.review.SectionCount(),
+		EstimateSectionVisibleCount(m.sectionPanelHeight()),
+	)
+	m.updateFileTree()
+	m.viewport.GotoTop()
+	m.updateViewportContent()
+}
+
+// currentHunkIndex returns the index, within the selected section, of the
+// first hunk shown at or below the top of the diff viewport, or -1 if none is
+func (m Model) currentHunkIndex() int {
+	for i := m.viewport.YOffset; i < len(m.diffLineRefs); i++ {
+		if m.diffLineRefs[i].Hunk >= 0 {
+			return m.diffLineRefs[i].Hunk
+		}
+	}
+	return -1
+}
+
+// saveReviewState persists reader state (reviewed marks and notes) of
+// review to the store. Only one save runs at a time, so saves cannot land
+// out of order: while one runs, review waits to be saved after it, replacing
+// any state that was already waiting.
+func (m *Model) saveReviewState(review model.Review) tea.Cmd {
+	if m.store == nil {
+		return nil
+	}
+	if m.saving {
+		m.pendingSave = &review
+		return nil
+	}
+	m.saving = true
+	return saveReviewStateCmd(m.store, review)
+}
+
+// finishSave handles the end of a save, starting the one waiting, if any
+func (m *Model) finishSave(msg ReviewSavedMsg) tea.Cmd {
+	m.saving = false
+	var cmds []tea.Cmd
+	if msg.Err != nil {
+		cmds = append(cmds, func() tea.Msg { return ErrorMsg{Err: msg.Err} })
+	}
+	if m.pendingSave != nil {
+		review := *m.pendingSave
+		m.pendingSave = nil
+		cmds = append(cmds, m.saveReviewState(review))
+	}
+	return tea.Batch(cmds...)
+}
+
+// saveReviewStateCmd writes review to the store. The watcher then delivers
+// the saved review, which keeps the reader's place.
+func saveReviewStateCmd(store *storage.Store, review model.Review) tea.Cmd {
+	return func() tea.Msg {
+		if err := store.Overwrite(review); err != nil {
+			if errors.Is(err, storage.ErrNotCurrent) {
+				return ReviewSavedMsg{Err: fmt.Errorf("changes not saved: only the current review can be updated")}
+			}
+			return ReviewSavedMsg{Err: fmt.Errorf("failed to save changes: %w", err)}
+		}
+		return ReviewSavedMsg{}
+	}
+}
@@ -0,0 +1,243 @@
+package tui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+	"github.com/mchowning/diffstory/internal/tui"
+)
+
+func progressTestReview(workDir string) model.Review {
+	review := model.NewReviewWithSections(workDir, "Progress", []model.Section{
+		{ID: "1", Title: "First", Hunks: []model.Hunk{
+			{File: "a.go", StartLine: 1, Diff: "@@ -1 +1 @@\n+a"},
+			{File: "a.go", StartLine: 20, Diff: "@@ -20 +20 @@\n+b"},
+		}},
+		{ID: "2", Title: "Second", Hunks: []model.Hunk{{File: "b.go", StartLine: 1, Diff: "@@ -1 +1 @@\n+c"}}},
+		{ID: "3", Title: "Third", Hunks: []model.Hunk{{File: "c.go", StartLine: 1, Diff: "@@ -1 +1 @@\n+d"}}},
+	})
+	review.CreatedAt = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	return review
+}
+
+func newProgressModel(t *testing.T, store *storage.Store, review model.Review) tui.Model {
+	t.Helper()
+	m := tui.NewModel(review.WorkingDirectory, nil, store, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	return updated.(tui.Model)
+}
+
+func pressKey(m tui.Model, key string) (tui.Model, tea.Cmd) {
+	var msg tea.KeyMsg
+	if key == " " {
+		msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	} else {
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	}
+	updated, cmd := m.Update(msg)
+	return updated.(tui.Model), cmd
+}
+
+func TestProgress_SpaceTogglesSectionReviewed(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+
+	m, _ = pressKey(m, " ")
+	if !m.Review().AllSections()[0].IsReviewed() {
+		t.Fatal("expected first section to be marked reviewed")
+	}
+	if !strings.Contains(m.View(), "1/3 reviewed") {
+		t.Error("expected header to show progress count")
+	}
+
+	m, _ = pressKey(m, " ")
+	if m.Review().AllSections()[0].IsReviewed() {
+		t.Error("expected second press to unmark the section")
+	}
+	if !strings.Contains(m.View(), "0/3 reviewed") {
+		t.Error("expected header progress to go back to zero")
+	}
+}
+
+func TestProgress_SpaceInDiffPanelTogglesTopHunk(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+	m, _ = pressKey(m, "0")
+
+	m, _ = pressKey(m, " ")
+
+	hunks := m.Review().AllSections()[0].Hunks
+	if !hunks[0].Reviewed || hunks[1].Reviewed {
+		t.Fatalf("expected only the top hunk to be reviewed, got %v, %v", hunks[0].Reviewed, hunks[1].Reviewed)
+	}
+	if !strings.Contains(m.View(), "✓ Reviewed") {
+		t.Error("expected reviewed hunk marker in the diff pane")
+	}
+	if m.Review().AllSections()[0].IsReviewed() {
+		t.Error("section should not be reviewed until all of its hunks are")
+	}
+}
+
+func TestProgress_HideReviewedSkipsReviewedSections(t *testing.T) {
+	m := newProgressModel(t, nil, progressTestReview("/test/project"))
+	m, _ = pressKey(m, " ")
+
+	m, _ = pressKey(m, "v")
+
+	if !m.HideReviewed() {
+		t.Fatal("expected reviewed sections to be hidden")
+	}
+	sections := m.Review().AllSections()
+	if len(sections) != 2 || sections[0].ID != "2" {
+		t.Fatalf("expected only unreviewed sections, got %+v", sections)
+	}
+	if m.Selected() != 0 {
+		t.Errorf("expected the next section to be selected, got %d", m.Selected())
+	}
+	if !strings.Contains(m.View(), "1/3 reviewed") {
+		t.Error("progress should count hidden sections")
+	}
+
+	// Marking the selected section while hidden moves on to the next one
+	m, _ = pressKey(m, " ")
+	sections = m.Review().AllSections()
+	if len(sections) != 1 || sections[0].ID != "3" {
+		t.Fatalf("expected section 3 to remain, got %+v", sections)
+	}
+
+	m, _ = pressKey(m, "v")
+	if m.Review().SectionCount() != 3 {
+		t.Errorf("expected all sections after showing reviewed again, got %d", m.Review().SectionCount())
+	}
+	if m.Selected() != 2 {
+		t.Errorf("expected selection to stay on section 3, got %d", m.Selected())
+	}
+}
+
+func TestProgress_PersistsToStore(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+	review := progressTestReview(workDir)
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	m := newProgressModel(t, store, review)
+
+	m, cmd := pressKey(m, " ")
+	if cmd == nil {
+		t.Fatal("expected a command to save progress")
+	}
+	if msg := cmd(); msg != (tui.ReviewSavedMsg{}) {
+		t.Fatalf("expected save to succeed, got %#v", msg)
+	}
+
+	loaded, err := store.Read(workDir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !loaded.Chapters[0].Sections[0].Reviewed {
+		t.Error("expected reviewed state to be persisted")
+	}
+}
+
+func TestProgress_ArchivedReviewIsNotSaved(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+	archived := progressTestReview(workDir)
+	current := progressTestReview(workDir)
+	current.CreatedAt = archived.CreatedAt.Add(time.Hour)
+	for _, r := range []model.Review{archived, current} {
+		if err := store.Write(r); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	m := newProgressModel(t, store, archived)
+
+	_, cmd := pressKey(m, " ")
+	msg, ok := cmd().(tui.ReviewSavedMsg)
+	if !ok || msg.Err == nil || !strings.Contains(msg.Err.Error(), "changes not saved") {
+		t.Fatalf("expected changes-not-saved error, got %#v", msg)
+	}
+}
+
+func TestProgress_ReloadOfSameReviewKeepsSelection(t *testing.T) {
+	review := progressTestReview("/test/project")
+	m := newProgressModel(t, nil, review)
+	m, _ = pressKey(m, "j")
+	m, _ = pressKey(m, " ")
+
+	saved := review
+	saved.Chapters = []model.Chapter{{ID: "default", Title: "Changes", Sections: m.Review().AllSections()}}
+	updated, _ := m.Update(tui.ReviewReceivedMsg{Review: saved})
+	m = updated.(tui.Model)
+
+	if m.Selected() != 1 {
+		t.Errorf("expected selection to be kept on reload, got %d", m.Selected())
+	}
+}
+
+func TestProgress_RapidTogglesSaveLatestState(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStoreWithDir failed: %v", err)
+	}
+	workDir := t.TempDir()
+	review := progressTestReview(workDir)
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	m := newProgressModel(t, store, review)
+
+	// Mark sections 1 and 2, unmark section 1, all before any save finishes
+	var cmds []tea.Cmd
+	for _, key := range []string{" ", "j", " ", "k", " "} {
+		var cmd tea.Cmd
+		m, cmd = pressKey(m, key)
+		if key == " " && cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+	}
+	if len(cmds) != 1 {
+		t.Fatalf("expected one save in flight, got %d", len(cmds))
+	}
+
+	// The watcher's reload of the first save must not undo later toggles
+	first := cmds[0]()
+	firstSaved, err := store.Read(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := m.Update(tui.ReviewReceivedMsg{Review: *firstSaved})
+	m = updated.(tui.Model)
+	if sections := m.Review().AllSections(); sections[0].IsReviewed() || !sections[1].IsReviewed() {
+		t.Fatal("expected the reload of an older save to be ignored")
+	}
+
+	// Finishing a save starts the next with the latest state, until none wait
+	for msg := first; msg != nil; {
+		updated, cmd := m.Update(msg)
+		m = updated.(tui.Model)
+		msg = nil
+		if cmd != nil {
+			msg = cmd()
+		}
+	}
+
+	loaded, err := store.Read(workDir)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if loaded.Chapters[0].Sections[0].Reviewed || !loaded.Chapters[0].Sections[1].Reviewed {
+		t.Errorf("expected the latest state to be saved, got %+v", loaded.Chapters[0].Sections)
+	}
+}
@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// staleCheckInterval is how often the viewer re-checks whether the displayed
+// review still matches the working tree
+const staleCheckInterval = 10 * time.Second
+
+// hashDiff returns the hex SHA-256 of a diff command's output
+func hashDiff(diffOutput string) string {
+	sum := sha256.Sum256([]byte(diffOutput))
+	return hex.EncodeToString(sum[:])
+}
+
+// buildProvenance records the diff command, its output hash and the commits
+// it was taken between. SHAs are left empty outside a git repository.
+func buildProvenance(ctx context.Context, workDir string, diffCommand []string, diffOutput string) *model.Provenance {
+	return &model.Provenance{
+		DiffCommand: append([]string{}, diffCommand...),
+		BaseSHA:     resolveBaseSHA(ctx, workDir, diffCommand),
+		HeadSHA:     resolveCommit(ctx, workDir, "HEAD"),
+		DiffHash:    hashDiff(diffOutput),
+	}
+}
+
+// diffBaseRevision returns the revision a git diff command compares against:
+// the left side of a range, the parent of a shown commit, or HEAD for
+// working tree and index diffs. A symmetric range (a...b) is returned as-is
+// so the caller can resolve its merge base. Non-git commands return "".
+func diffBaseRevision(diffCommand []string) string {
+	if len(diffCommand) < 2 || diffCommand[0] != "git" {
+		return ""
+	}
+	subcommand := diffCommand[1]
+	if subcommand != "diff" && subcommand != "show" {
+		return ""
+	}
+
+	for _, arg := range diffCommand[2:] {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if subcommand == "show" {
+			return arg + "^"
+		}
+		if strings.Contains(arg, "...") {
+			return arg
+		}
+		if left, _, ok := strings.Cut(arg, ".."); ok {
+			if left == "" {
+				return "HEAD"
+			}
+			return left
+		}
+		return arg
+	}
+
+	if subcommand == "show" {
+		return "HEAD^"
+	}
+	return "HEAD"
+}
+
+// resolveBaseSHA resolves the base revision of a diff command to a commit SHA
+func resolveBaseSHA(ctx context.Context, workDir string, diffCommand []string) string {
+	rev := diffBaseRevision(diffCommand)
+	if rev == "" {
+		return ""
+	}
+	if left, right, ok := strings.Cut(rev, "..."); ok {
+		if left == "" {
+			left = "HEAD"
+		}
+		if right == "" {
+			right = "HEAD"
+		}
+		output, err := runCommand(ctx, workDir, []string{"git", "merge-base", left, right}, nil)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(output)
+	}
+	return resolveCommit(ctx, workDir, rev)
+}
+
+// resolveCommit returns the full SHA of rev, or "" if it cannot be resolved
+func resolveCommit(ctx context.Context, workDir, rev string) string {
+	output, err := runCommand(ctx, workDir, []string{"git", "rev-parse", "--verify", "-q", rev + "^{commit}"}, nil)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// diffFlags are the flags of the git diff and show commands diffstory builds
+// itself, by subcommand
+var diffFlags = map[string]map[string]bool{
+	"diff": {"--no-color": true, "--no-ext-diff": true, "--cached": true},
+	"show": {"--no-color": true, "--no-ext-diff": true, "--format=": true},
+}
+
+// revisionPattern matches revisions and ranges of them, and nothing git could
+// read as an option
+var revisionPattern = regexp.MustCompile(`^[A-Za-z0-9._/~^@{}-]+$`)
+
+// knownDiffCommand reports whether command has the form of a diff source
+// diffstory builds itself (git diff or git show, the flags it passes and at
+// most one revision), returning the subcommand and revision. Provenance is
+// read from review files, so any other command is never run.
+func knownDiffCommand(command []string) (subcommand, revision string, ok bool) {
+	if len(command) < 2 || command[0] != "git" || diffFlags[command[1]] == nil {
+		return "", "", false
+	}
+	subcommand = command[1]
+	for _, arg := range command[2:] {
+		if strings.HasPrefix(arg, "-") {
+			if !diffFlags[subcommand][arg] {
+				return "", "", false
+			}
+			continue
+		}
+		if revision != "" || !revisionPattern.MatchString(arg) {
+			return "", "", false
+		}
+		revision = arg
+	}
+	if subcommand == "show" && revision == "" {
+		return "", "", false
+	}
+	return subcommand, revision, true
+}
+
+// dependsOnHead reports whether a known diff command's output can change
+// when HEAD moves: diffs of the working tree or index, and revisions given
+// relative to HEAD. A commit or a range of fixed refs is not.
+func dependsOnHead(subcommand, revision string) bool {
+	if subcommand == "diff" && !strings.Contains(revision, "..") {
+		return true
+	}
+	if strings.Contains(revision, "HEAD") {
+		return true
+	}
+	left, right, isRange := strings.Cut(strings.Replace(revision, "...", "..", 1), "..")
+	return isRange && (left == "" || right == "")
+}
+
+// detectStaleness compares a review's provenance with the current state of
+// workDir and describes what has changed, or returns "" if nothing has. Only
+// diff commands of the forms diffstory builds are run again; for any other,
+// only HEAD is compared.
+func detectStaleness(ctx context.Context, workDir string, p *model.Provenance) (string, error) {
+	subcommand, revision, known := knownDiffCommand(p.DiffCommand)
+	if p.HeadSHA != "" && (!known || dependsOnHead(subcommand, revision)) &&
+		resolveCommit(ctx, workDir, "HEAD") != p.HeadSHA {
+		return "HEAD has moved", nil
+	}
+	if !known {
+		return "", nil
+	}
+	if p.BaseSHA != "" && resolveBaseSHA(ctx, workDir, p.DiffCommand) != p.BaseSHA {
+		return "base commit has moved", nil
+	}
+	output, err := runCommand(ctx, workDir, p.DiffCommand, nil)
+	if err != nil {
+		return "", err
+	}
+	if hashDiff(output) != p.DiffHash {
+		return "diff has changed", nil
+	}
+	return "", nil
+}
+
+// checkStalenessCmd checks whether review still matches its working directory
+func checkStalenessCmd(review *model.Review) tea.Cmd {
+	if review == nil || review.Provenance == nil {
+		return nil
+	}
+	workDir := review.WorkingDirectory
+	provenance := review.Provenance
+	createdAt := review.CreatedAt
+	return func() tea.Msg {
+		reason, err := detectStaleness(context.Background(), workDir, provenance)
+		return ReviewStalenessMsg{CreatedAt: createdAt, Reason: reason, Err: err}
+	}
+}
+
+// staleCheck checks the shown review for staleness, unless checks are off
+func (m Model) staleCheck() tea.Cmd {
+	if m.skipStaleCheck {
+		return nil
+	}
+	return checkStalenessCmd(m.review)
+}
+
+// scheduleStalenessCheck re-checks review after staleCheckInterval
+func scheduleStalenessCheck(review *model.Review) tea.Cmd {
+	if review == nil || review.Provenance == nil {
+		return nil
+	}
+	createdAt := review.CreatedAt
+	return tea.Tick(staleCheckInterval, func(time.Time) tea.Msg {
+		return StaleCheckTickMsg{CreatedAt: createdAt}
+	})
+}
@@ -0,0 +1,202 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffBaseRevision(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    string
+	}{
+		{"uncommitted", []string{"git", "diff", "HEAD", "--no-color"}, "HEAD"},
+		{"staged", []string{"git", "diff", "--cached", "--no-color"}, "HEAD"},
+		{"since branch", []string{"git", "diff", "main...HEAD", "--no-color"}, "main...HEAD"},
+		{"commit range", []string{"git", "diff", "abc123..def456"}, "abc123"},
+		{"open range", []string{"git", "diff", "..def456"}, "HEAD"},
+		{"specific commit", []string{"git", "show", "abc123", "--format="}, "abc123^"},
+		{"pathspec only", []string{"git", "diff", "--", "main.go"}, "HEAD"},
+		{"not git", []string{"diff", "-u", "a", "b"}, ""},
+		{"other git command", []string{"git", "log", "-p"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffBaseRevision(tt.command); got != tt.want {
+				t.Errorf("diffBaseRevision(%v) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+// commitFile writes content to name in dir and commits it
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	for _, args := range [][]string{{"add", name}, {"commit", "-m", "update " + name}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+}
+
+func TestBuildProvenance_RecordsCommitsAndDiffHash(t *testing.T) {
+	dir := setupTempGitRepo(t)
+	commitFile(t, dir, "file.txt", "one\n")
+	ctx := context.Background()
+	command := []string{"git", "diff", "HEAD", "--no-color"}
+
+	p := buildProvenance(ctx, dir, command, "diff output")
+
+	head := resolveCommit(ctx, dir, "HEAD")
+	if head == "" {
+		t.Fatal("expected HEAD to resolve")
+	}
+	if p.HeadSHA != head || p.BaseSHA != head {
+		t.Errorf("expected base and head %q, got base %q head %q", head, p.BaseSHA, p.HeadSHA)
+	}
+	if p.DiffHash != hashDiff("diff output") {
+		t.Errorf("unexpected diff hash %q", p.DiffHash)
+	}
+	if len(p.DiffCommand) != len(command) {
+		t.Errorf("expected diff command %v, got %v", command, p.DiffCommand)
+	}
+}
+
+func TestDetectStaleness_UnchangedTreeIsFresh(t *testing.T) {
+	dir := setupTempGitRepo(t)
+	commitFile(t, dir, "file.txt", "one\n")
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("two\n"), 0644)
+	ctx := context.Background()
+	command := []string{"git", "diff", "HEAD", "--no-color"}
+	output, err := runCommand(ctx, dir, command, nil)
+	if err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	p := buildProvenance(ctx, dir, command, output)
+
+	reason, err := detectStaleness(ctx, dir, p)
+	if err != nil {
+		t.Fatalf("detectStaleness failed: %v", err)
+	}
+	if reason != "" {
+		t.Errorf("expected fresh review, got stale reason %q", reason)
+	}
+}
+
+func TestDetectStaleness_EditedFileIsStale(t *testing.T) {
+	dir := setupTempGitRepo(t)
+	commitFile(t, dir, "file.txt", "one\n")
+	ctx := context.Background()
+	command := []string{"git", "diff", "HEAD", "--no-color"}
+	output, _ := runCommand(ctx, dir, command, nil)
+	p := buildProvenance(ctx, dir, command, output)
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("edited\n"), 0644)
+
+	reason, err := detectStaleness(ctx, dir, p)
+	if err != nil {
+		t.Fatalf("detectStaleness failed: %v", err)
+	}
+	if reason != "diff has changed" {
+		t.Errorf("expected diff change to be detected, got %q", reason)
+	}
+}
+
+func TestDetectStaleness_NewCommitIsStale(t *testing.T) {
+	dir := setupTempGitRepo(t)
+	commitFile(t, dir, "file.txt", "one\n")
+	ctx := context.Background()
+	command := []string{"git", "diff", "HEAD", "--no-color"}
+	output, _ := runCommand(ctx, dir, command, nil)
+	p := buildProvenance(ctx, dir, command, output)
+
+	commitFile(t, dir, "other.txt", "two\n")
+
+	reason, err := detectStaleness(ctx, dir, p)
+	if err != nil {
+		t.Fatalf("detectStaleness failed: %v", err)
+	}
+	if reason != "HEAD has moved" {
+		t.Errorf("expected HEAD move to be detected, got %q", reason)
+	}
+}
+
+func TestKnownDiffCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    bool
+	}{
+		{"uncommitted", DefaultDiffSources("main")[0].Command, true},
+		{"staged", DefaultDiffSources("main")[1].Command, true},
+		{"since branch", DefaultDiffSources("main")[2].Command, true},
+		{"commit", CommitDiffSource("abc123").Command, true},
+		{"range", RangeDiffSource("abc123", "def456").Command, true},
+		{"default diff command", []string{"git", "diff", "HEAD"}, true},
+		{"not git", []string{"sh", "-c", "touch pwned"}, false},
+		{"other flag", []string{"git", "diff", "--output=/tmp/x", "HEAD"}, false},
+		{"two revisions", []string{"git", "diff", "HEAD", "main"}, false},
+		{"pathspec", []string{"git", "diff", "HEAD", "--", "main.go"}, false},
+		{"show without commit", []string{"git", "show", "--format="}, false},
+		{"other git command", []string{"git", "log", "-p"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, ok := knownDiffCommand(tt.command); ok != tt.want {
+				t.Errorf("knownDiffCommand(%v) = %v, want %v", tt.command, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectStaleness_DoesNotRunOtherCommands(t *testing.T) {
+	dir := setupTempGitRepo(t)
+	commitFile(t, dir, "file.txt", "one\n")
+	ctx := context.Background()
+	marker := filepath.Join(dir, "pwned")
+	p := buildProvenance(ctx, dir, []string{"touch", marker}, "")
+	p.DiffHash = hashDiff("something else")
+
+	reason, err := detectStaleness(ctx, dir, p)
+	if err != nil {
+		t.Fatalf("detectStaleness failed: %v", err)
+	}
+	if reason != "" {
+		t.Errorf("expected only HEAD to be compared, got stale reason %q", reason)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("expected the recorded command not to run, got %v", err)
+	}
+}
+
+func TestDetectStaleness_CommitStaysFreshAfterLaterCommit(t *testing.T) {
+	dir := setupTempGitRepo(t)
+	commitFile(t, dir, "file.txt", "one\n")
+	commitFile(t, dir, "file.txt", "two\n")
+	ctx := context.Background()
+	command := CommitDiffSource(resolveCommit(ctx, dir, "HEAD")).Command
+	output, err := runCommand(ctx, dir, command, nil)
+	if err != nil {
+		t.Fatalf("show failed: %v", err)
+	}
+	p := buildProvenance(ctx, dir, command, output)
+
+	commitFile(t, dir, "other.txt", "three\n")
+
+	reason, err := detectStaleness(ctx, dir, p)
+	if err != nil {
+		t.Fatalf("detectStaleness failed: %v", err)
+	}
+	if reason != "" {
+		t.Errorf("expected a commit's review to stay fresh, got stale reason %q", reason)
+	}
+}
@@ -0,0 +1,81 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/model"
+)
+
+// settleClassification fixes what can be fixed without asking the LLM again:
+// references to hunks that are not in the input are dropped, a hunk listed
+// more than once keeps its first place, and importance is normalized,
+// defaulting to medium. Sections and chapters left without hunks are
+// dropped. Hunks the response left out are still missing afterwards.
+func settleClassification(response LLMResponse, hunks []diff.ParsedHunk) LLMResponse {
+	inputIDs := make(map[string]bool, len(hunks))
+	for _, h := range hunks {
+		inputIDs[h.ID] = true
+	}
+
+	settled := LLMResponse{Title: response.Title}
+	placed := make(map[string]bool)
+	for _, ch := range response.Chapters {
+		chapter := LLMChapter{ID: ch.ID, Title: ch.Title}
+		for _, s := range ch.Sections {
+			section := LLMSection{ID: s.ID, Title: s.Title, What: s.What, Why: s.Why}
+			for _, ref := range s.Hunks {
+				if !inputIDs[ref.ID] || placed[ref.ID] {
+					continue
+				}
+				placed[ref.ID] = true
+				ref.Importance = model.NormalizeImportance(ref.Importance)
+				if ref.Importance == "" {
+					ref.Importance = model.ImportanceMedium
+				}
+				section.Hunks = append(section.Hunks, ref)
+			}
+			if len(section.Hunks) > 0 {
+				chapter.Sections = append(chapter.Sections, section)
+			}
+		}
+		if len(chapter.Sections) > 0 {
+			settled.Chapters = append(settled.Chapters, chapter)
+		}
+	}
+	return settled
+}
+
+// missingHunks returns the hunks the response does not classify, in order
+func missingHunks(hunks []diff.ParsedHunk, missingIDs []string) []diff.ParsedHunk {
+	missing := make(map[string]bool, len(missingIDs))
+	for _, id := range missingIDs {
+		missing[id] = true
+	}
+	var result []diff.ParsedHunk
+	for _, h := range hunks {
+		if missing[h.ID] {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// responseOutlineJSON renders the response's chapters and sections, without
+// their hunks, for a repair prompt
+func responseOutlineJSON(response LLMResponse) (string, error) {
+	outline := []outlineChapter{}
+	for _, ch := range response.Chapters {
+		chapter := outlineChapter{ID: ch.ID, Title: ch.Title, Sections: []outlineSection{}}
+		for _, s := range ch.Sections {
+			chapter.Sections = append(chapter.Sections, outlineSection{ID: s.ID, Title: s.Title, What: s.What, Why: s.Why})
+		}
+		outline = append(outline, chapter)
+	}
+	data, err := json.MarshalIndent(outline, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to build classification outline: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,157 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/diff"
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestSettleClassification(t *testing.T) {
+	hunks := []diff.ParsedHunk{{ID: "a.go::1"}, {ID: "b.go::1"}, {ID: "c.go::1"}}
+	response := LLMResponse{Title: "T", Chapters: []LLMChapter{
+		{ID: "one", Sections: []LLMSection{
+			{ID: "first", Hunks: []LLMHunkRef{{ID: "a.go::1", Importance: "HIGH"}, {ID: "made-up.go::1", Importance: "high"}}},
+			{ID: "only-unknown", Hunks: []LLMHunkRef{{ID: "made-up.go::2", Importance: "low"}}},
+		}},
+		{ID: "two", Sections: []LLMSection{
+			{ID: "second", Hunks: []LLMHunkRef{{ID: "a.go::1", Importance: "low"}, {ID: "b.go::1", Importance: "urgent"}}},
+		}},
+	}}
+
+	settled := settleClassification(response, hunks)
+
+	expected := LLMResponse{Title: "T", Chapters: []LLMChapter{
+		{ID: "one", Sections: []LLMSection{{ID: "first", Hunks: []LLMHunkRef{{ID: "a.go::1", Importance: "high"}}}}},
+		{ID: "two", Sections: []LLMSection{{ID: "second", Hunks: []LLMHunkRef{{ID: "b.go::1", Importance: "medium"}}}}},
+	}}
+	if !reflect.DeepEqual(settled, expected) {
+		t.Errorf("expected %+v, got %+v", expected, settled)
+	}
+	validation := validateClassification(hunks, settled)
+	if !reflect.DeepEqual(validation.MissingIDs, []string{"c.go::1"}) || len(validation.DuplicateIDs) > 0 || len(validation.InvalidImportance) > 0 {
+		t.Errorf("expected only c.go::1 left to repair, got %+v", validation)
+	}
+}
+
+// twoFilePatch changes a.go and b.go, one hunk each
+const twoFilePatch = classifyAPatch + "diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-a\n+b\n"
+
+// repairScript answers classification requests with classifyA, leaving b.go
+// out, and repair requests with placement. It counts requests in the file
+// named by its first argument; the prompt is the second.
+func repairScript(placement string) string {
+	return `echo x >> "$1"
+case "$2" in
+*"Placing Missing Hunks"*) echo '` + placement + `' ;;
+*) echo '` + classifyA + `' ;;
+esac`
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestGenerateReviewCmd_RepairsMissingHunks(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(twoFilePatch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	calls := filepath.Join(t.TempDir(), "calls")
+
+	// The repair places b.go in the section classifyA created
+	placement := `{"title":"T","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"S","what":"w","why":"y","hunks":[{"id":"b.go::1","importance":"bogus"}]}]}]}`
+	params := GenerateParams{
+		DiffCommand:       []string{"cat", "changes.patch"},
+		DiffSource:        "Patch",
+		LLM:               llm.Command{Args: []string{"sh", "-c", repairScript(placement), "llm", calls}, Delivery: llm.DeliverArgument},
+		MaxRepairAttempts: 2,
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	if _, ok := msg.(GenerateSuccessMsg); !ok {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+
+	stored := storedReview(store, workDir, "", "Patch")
+	if stored == nil || len(stored.Chapters) != 1 || len(stored.Chapters[0].Sections) != 1 {
+		t.Fatalf("expected one section, got %+v", stored)
+	}
+	hunks := stored.Chapters[0].Sections[0].Hunks
+	if len(hunks) != 2 || hunks[1].ID != "b.go::1" || hunks[1].Importance != "medium" {
+		t.Errorf("expected b.go placed after a.go with medium importance, got %+v", hunks)
+	}
+	if n := countLines(t, calls); n != 2 {
+		t.Errorf("expected a classification and one repair request, got %d requests", n)
+	}
+}
+
+func TestGenerateReviewCmd_GivesUpAfterRepairAttempts(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(twoFilePatch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	calls := filepath.Join(t.TempDir(), "calls")
+
+	// Every repair classifies a.go again instead of b.go
+	params := GenerateParams{
+		DiffCommand:       []string{"cat", "changes.patch"},
+		DiffSource:        "Patch",
+		LLM:               llm.Command{Args: []string{"sh", "-c", repairScript(classifyA), "llm", calls}, Delivery: llm.DeliverArgument},
+		MaxRepairAttempts: 2,
+	}
+	msg := generateReviewCmd(context.Background(), workDir, store, nil, params)()
+	failed, ok := msg.(GenerateValidationFailedMsg)
+	if !ok {
+		t.Fatalf("expected GenerateValidationFailedMsg, got %#v", msg)
+	}
+	if !reflect.DeepEqual(failed.Missing, []string{"b.go::1"}) || failed.Attempts != 2 {
+		t.Errorf("expected b.go::1 missing after 2 attempts, got %v after %d", failed.Missing, failed.Attempts)
+	}
+	if n := countLines(t, calls); n != 3 {
+		t.Errorf("expected a classification and two repair requests, got %d requests", n)
+	}
+	if len(failed.Response.Chapters) != 1 || len(failed.Response.Chapters[0].Sections[0].Hunks) != 1 {
+		t.Errorf("expected the repaired response without duplicates, got %+v", failed.Response)
+	}
+
+	// Retrying repairs the failed classification rather than starting over
+	placement := `{"title":"T","chapters":[{"id":"c","title":"C","sections":[{"id":"new","title":"N","what":"w","why":"y","hunks":[{"id":"b.go::1","importance":"low"}]}]}]}`
+	retry := GenerateParams{
+		DiffSource:        "Patch",
+		LLM:               llm.Command{Args: []string{"sh", "-c", repairScript(placement), "llm", calls}, Delivery: llm.DeliverArgument},
+		Repair:            failed.Response,
+		ParsedHunks:       failed.Hunks,
+		Provenance:        failed.Provenance,
+		MaxRepairAttempts: 2,
+	}
+	msg = generateReviewCmd(context.Background(), workDir, store, nil, retry)()
+	if _, ok := msg.(GenerateSuccessMsg); !ok {
+		t.Fatalf("expected GenerateSuccessMsg on retry, got %#v", msg)
+	}
+	if n := countLines(t, calls); n != 4 {
+		t.Errorf("expected the retry to send one repair request, got %d requests in total", n)
+	}
+	stored := storedReview(store, workDir, "", "Patch")
+	if stored == nil || stored.Provenance == nil || len(stored.Chapters[0].Sections) != 2 {
+		t.Errorf("expected b.go in a new section and the provenance kept, got %+v", stored)
+	}
+}
@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mchowning/diffstory/internal/storage"
+	"github.com/mchowning/diffstory/internal/timeutil"
+)
+
+// ReviewListMode selects which list of stored reviews is being browsed
+type ReviewListMode int
+
+const (
+	ReviewListNone    ReviewListMode = iota
+	ReviewListHistory                // Archived reviews for the directory
+	ReviewListStored                 // Current reviews per branch and diff source
+)
+
+// loadHistoryCmd returns a command that loads the review history for workDir
+func loadHistoryCmd(store *storage.Store, workDir string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := store.History(workDir)
+		if err != nil {
+			return ErrorMsg{Err: fmt.Errorf("failed to load history: %w", err)}
+		}
+		return HistoryListMsg{Entries: entries}
+	}
+}
+
+// loadStoredReviewsCmd returns a command that loads the reviews stored for workDir
+func loadStoredReviewsCmd(store *storage.Store, workDir string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := store.Reviews(workDir)
+		if err != nil {
+			return ErrorMsg{Err: fmt.Errorf("failed to load reviews: %w", err)}
+		}
+		return StoredReviewsMsg{Entries: entries}
+	}
+}
+
+// openStoredReviewCmd returns a command that loads a stored or archived review for display
+func openStoredReviewCmd(store *storage.Store, path string) tea.Cmd {
+	return func() tea.Msg {
+		review, err := store.ReadPath(path)
+		if err != nil {
+			return ErrorMsg{Err: fmt.Errorf("failed to open review: %w", err)}
+		}
+		return ReviewReceivedMsg{Review: *review}
+	}
+}
+
+// openReviewList shows the review list overlay, or a status message when empty
+func (m Model) openReviewList(mode ReviewListMode, entries []storage.ReviewEntry, emptyMsg string) (Model, tea.Cmd) {
+	if len(entries) == 0 {
+		m.statusMsg = emptyMsg
+		return m, tea.Tick(3*time.Second, func(time.Time) tea.Msg {
+			return ClearStatusMsg{}
+		})
+	}
+	m.reviewListMode = mode
+	m.reviewListEntries = entries
+	m.reviewListSelected = 0
+	return m, nil
+}
+
+// updateReviewList handles key events while a review list is open
+func (m Model) updateReviewList(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "j", "down":
+		if m.reviewListSelected < len(m.reviewListEntries)-1 {
+			m.reviewListSelected++
+		}
+	case "k", "up":
+		if m.reviewListSelected > 0 {
+			m.reviewListSelected--
+		}
+	case "enter":
+		if m.reviewListSelected < len(m.reviewListEntries) {
+			entry := m.reviewListEntries[m.reviewListSelected]
+			m.reviewListMode = ReviewListNone
+			return m, openStoredReviewCmd(m.store, entry.Path)
+		}
+	case "esc", "q":
+		m.reviewListMode = ReviewListNone
+	case "H":
+		if m.reviewListMode == ReviewListHistory {
+			m.reviewListMode = ReviewListNone
+		}
+	case "R":
+		if m.reviewListMode == ReviewListStored {
+			m.reviewListMode = ReviewListNone
+		}
+	}
+	return m, nil
+}
+
+// renderReviewList renders the history or stored review list
+func (m Model) renderReviewList() string {
+	var sb strings.Builder
+	if m.reviewListMode == ReviewListHistory {
+		sb.WriteString("Review history\n\n")
+	} else {
+		sb.WriteString("Reviews for this repository\n\n")
+	}
+
+	dialogWidth := min(m.width-4, 100)
+	titleWidth := max(dialogWidth-60, 20)
+	maxDisplay := max(min(m.height-12, 20), 5)
+
+	// Keep the selection visible when the list is longer than the dialog
+	start := 0
+	if m.reviewListSelected >= maxDisplay {
+		start = m.reviewListSelected - maxDisplay + 1
+	}
+
+	now := time.Now()
+	for i := start; i < len(m.reviewListEntries) && i < start+maxDisplay; i++ {
+		entry := m.reviewListEntries[i]
+		prefix := "  "
+		style := normalStyle
+		if i == m.reviewListSelected {
+			prefix = "› "
+			style = selectedStyle
+		}
+
+		age := timeutil.FormatRelative(entry.CreatedAt, now)
+		title := truncate(entry.Title, titleWidth)
+		line := fmt.Sprintf("%-16s %s", age, title)
+
+		suffix := describeReviewOrigin(entry.DiffSource, entry.Branch)
+		if suffix != "" {
+			suffix = " (" + suffix + ")"
+		}
+		if m.review != nil && entry.CreatedAt.Equal(m.review.CreatedAt) {
+			suffix += " • viewing"
+		}
+		sb.WriteString(style.Render(prefix+line) + dimStyle.Render(suffix))
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(helpStyle.Render("j/k  navigate\nEnter  open\nEsc  close"))
+
+	dialog := dialogStyle.Width(dialogWidth).Render(sb.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
+}
+
+// describeReviewOrigin formats the diff source and branch of a review,
+// e.g. "Staged changes on feature-x"
+func describeReviewOrigin(diffSource, branch string) string {
+	switch {
+	case diffSource != "" && branch != "":
+		return diffSource + " on " + branch
+	case branch != "":
+		return "on " + branch
+	default:
+		return diffSource
+	}
+}
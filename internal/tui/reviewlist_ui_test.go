@@ -0,0 +1,268 @@
+package tui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+	"github.com/mchowning/diffstory/internal/tui"
+)
+
+func storeWithHistory(t *testing.T, workDir string, titles ...string) *storage.Store {
+	t.Helper()
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, title := range titles {
+		review := model.NewReviewWithSections(workDir, title, []model.Section{{ID: "1", What: title}})
+		review.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		review.DiffSource = "Staged changes"
+		if err := store.Write(review); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	return store
+}
+
+func TestUpdate_HKeyWithoutStoreShowsError(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("H")})
+	result := updated.(tui.Model)
+
+	if result.StatusMsg() != "Storage not initialized" {
+		t.Errorf("StatusMsg() = %q, want %q", result.StatusMsg(), "Storage not initialized")
+	}
+}
+
+func TestUpdate_HKeyLoadsHistory(t *testing.T) {
+	store := storeWithHistory(t, "/test/project", "First", "Second")
+	m := tui.NewModel("/test/project", nil, store, nil)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("H")})
+	if cmd == nil {
+		t.Fatal("expected command to load history")
+	}
+
+	msg, ok := cmd().(tui.HistoryListMsg)
+	if !ok {
+		t.Fatalf("expected HistoryListMsg, got %T", cmd())
+	}
+	if len(msg.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(msg.Entries))
+	}
+	if msg.Entries[0].Title != "Second" {
+		t.Errorf("first entry = %q, want newest %q", msg.Entries[0].Title, "Second")
+	}
+}
+
+func TestUpdate_HistoryListMsgOpensBrowser(t *testing.T) {
+	store := storeWithHistory(t, "/test/project", "First", "Second")
+	m := tui.NewModel("/test/project", nil, store, nil)
+	entries, _ := store.History("/test/project")
+
+	updated, _ := m.Update(tui.HistoryListMsg{Entries: entries})
+	result := updated.(tui.Model)
+
+	if !result.ShowHistory() {
+		t.Error("expected history browser to be shown")
+	}
+}
+
+func TestUpdate_EmptyHistoryListShowsStatus(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+
+	updated, _ := m.Update(tui.HistoryListMsg{})
+	result := updated.(tui.Model)
+
+	if result.ShowHistory() {
+		t.Error("expected history browser to stay closed")
+	}
+	if !strings.Contains(result.StatusMsg(), "No review history") {
+		t.Errorf("StatusMsg() = %q, want no-history message", result.StatusMsg())
+	}
+}
+
+func TestUpdate_HistoryBrowserEnterOpensSelectedReview(t *testing.T) {
+	store := storeWithHistory(t, "/test/project", "First", "Second")
+	m := tui.NewModel("/test/project", nil, store, nil)
+	entries, _ := store.History("/test/project")
+
+	updated, _ := m.Update(tui.HistoryListMsg{Entries: entries})
+	m = updated.(tui.Model)
+
+	// Move to the older entry and open it
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	m = updated.(tui.Model)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(tui.Model)
+
+	if m.ShowHistory() {
+		t.Error("expected history browser to close after selection")
+	}
+	if cmd == nil {
+		t.Fatal("expected command to open review")
+	}
+	received, ok := cmd().(tui.ReviewReceivedMsg)
+	if !ok {
+		t.Fatalf("expected ReviewReceivedMsg, got %T", cmd())
+	}
+	if received.Review.Title != "First" {
+		t.Errorf("opened review = %q, want %q", received.Review.Title, "First")
+	}
+}
+
+func TestUpdate_HistoryBrowserEscCloses(t *testing.T) {
+	store := storeWithHistory(t, "/test/project", "First")
+	m := tui.NewModel("/test/project", nil, store, nil)
+	entries, _ := store.History("/test/project")
+
+	updated, _ := m.Update(tui.HistoryListMsg{Entries: entries})
+	m = updated.(tui.Model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	result := updated.(tui.Model)
+
+	if result.ShowHistory() {
+		t.Error("expected history browser to close on escape")
+	}
+}
+
+func TestView_HistoryBrowserListsEntries(t *testing.T) {
+	store := storeWithHistory(t, "/test/project", "First", "Second")
+	m := tui.NewModel("/test/project", nil, store, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+	entries, _ := store.History("/test/project")
+
+	updated, _ = m.Update(tui.HistoryListMsg{Entries: entries})
+	m = updated.(tui.Model)
+	view := m.View()
+
+	for _, want := range []string{"Review history", "First", "Second", "Staged changes"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("history view should contain %q", want)
+		}
+	}
+}
+
+func TestUpdate_RKeyLoadsStoredReviews(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	for _, source := range []string{"Staged changes", "Uncommitted changes"} {
+		review := model.NewReviewWithSections("/test/project", source, []model.Section{{ID: "1", What: "x"}})
+		review.DiffSource = source
+		review.Branch = "main"
+		review.CreatedAt = time.Now()
+		if err := store.Write(review); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	m := tui.NewModel("/test/project", nil, store, nil)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("R")})
+	if cmd == nil {
+		t.Fatal("expected command to load stored reviews")
+	}
+	msg, ok := cmd().(tui.StoredReviewsMsg)
+	if !ok {
+		t.Fatalf("expected StoredReviewsMsg, got %T", cmd())
+	}
+	if len(msg.Entries) != 2 {
+		t.Fatalf("expected 2 stored reviews, got %d", len(msg.Entries))
+	}
+
+	updated, _ := m.Update(msg)
+	result := updated.(tui.Model)
+	if !result.ShowStoredReviews() {
+		t.Error("expected stored review list to be shown")
+	}
+}
+
+func TestView_StoredReviewListShowsBranchAndSource(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+
+	entries := []storage.ReviewEntry{
+		{Title: "Auth rework", Branch: "feature-x", DiffSource: "Changes since main", CreatedAt: time.Now()},
+	}
+	updated, _ = m.Update(tui.StoredReviewsMsg{Entries: entries})
+	m = updated.(tui.Model)
+	view := m.View()
+
+	if !strings.Contains(view, "Reviews for this repository") {
+		t.Error("view should contain stored review list title")
+	}
+	if !strings.Contains(view, "Changes since main on feature-x") {
+		t.Error("view should show diff source and branch")
+	}
+}
+
+func TestView_TimestampShowsReviewOrigin(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+
+	review := model.NewReviewWithSections("/test/project", "Test", []model.Section{{ID: "1", What: "Section"}})
+	review.CreatedAt = time.Now().Add(-time.Hour)
+	review.DiffSource = "Staged changes"
+	review.Branch = "feature-x"
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	if !strings.Contains(m.View(), "Staged changes on feature-x") {
+		t.Error("timestamp line should show diff source and branch")
+	}
+}
+
+func TestUpdate_WatchedReviewOfAnotherSourceDoesNotTakeOver(t *testing.T) {
+	var shown []string
+	m := tui.NewModel("/test/project", nil, nil, nil, tui.WithReviewShown(func(r model.Review) {
+		shown = append(shown, r.Title)
+	}))
+	reading := model.NewReviewWithSections("/test/project", "Reading", []model.Section{{ID: "1"}})
+	reading.DiffSource = "Uncommitted changes"
+	reading.CreatedAt = time.Now()
+	updated, _ := m.Update(tui.ReviewReceivedMsg{Review: reading, Watched: true})
+	m = updated.(tui.Model)
+
+	// A review of another source, generated in the background
+	other := model.NewReviewWithSections("/test/project", "Background", []model.Section{{ID: "1"}})
+	other.DiffSource = "Staged changes"
+	other.CreatedAt = time.Now()
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: other, Watched: true})
+	m = updated.(tui.Model)
+	if m.Review().Title != "Reading" {
+		t.Errorf("expected the review being read to stay, got %q", m.Review().Title)
+	}
+	if !strings.Contains(m.StatusMsg(), "Staged changes") || !strings.Contains(m.StatusMsg(), "press R") {
+		t.Errorf("expected a hint about the updated review, got %q", m.StatusMsg())
+	}
+
+	// A regeneration of the review being read replaces it
+	regenerated := reading
+	regenerated.Title = "Regenerated"
+	regenerated.CreatedAt = reading.CreatedAt.Add(time.Minute)
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: regenerated, Watched: true})
+	m = updated.(tui.Model)
+	if m.Review().Title != "Regenerated" {
+		t.Errorf("expected the regenerated review, got %q", m.Review().Title)
+	}
+
+	// The reader opening another review switches to it, and the watcher is told
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: other})
+	m = updated.(tui.Model)
+	if m.Review().Title != "Background" {
+		t.Errorf("expected the opened review, got %q", m.Review().Title)
+	}
+	if strings.Join(shown, ",") != "Reading,Regenerated,Background" {
+		t.Errorf("expected the watcher to be told of each review shown, got %v", shown)
+	}
+}
@@ -10,6 +10,7 @@ var (
 	// Section list - indented to align with chapter title text
 	selectedPrefix = "  "
 	normalPrefix   = "  "
+	reviewedPrefix = "✓ "
 
 	// Chapter headers in section pane
 	chapterPrefix = "▼ "
@@ -44,6 +45,27 @@ var (
 			Foreground(lipgloss.Color("244")).
 			Italic(true)
 
+	// Badge shown next to the timestamp when the review no longer matches the working tree
+	staleBadgeStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214")).
+			Bold(true)
+
+	// Reviewed markers in the section and diff panes, and the header progress count
+	reviewedMarkerStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("114"))
+
+	// Reader notes shown in the diff pane
+	noteStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("221")).
+			Italic(true)
+
+	// Summary block shown in place of a binary file's diff
+	binarySummaryStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("110")).
+				Border(lipgloss.NormalBorder(), false, false, false, true).
+				BorderForeground(lipgloss.Color("240")).
+				PaddingLeft(1)
+
 	// Description pane labels (WHAT/WHY)
 	descriptionLabelStyle = lipgloss.NewStyle().
 				Foreground(lipgloss.Color("183")) // Soft lavender
@@ -0,0 +1,83 @@
+package tui
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+// GenerationRecord collects what happens during a generation: the metadata
+// saved in the review and the exchanges with the LLM archived beside it. A
+// retry continues the record of the generation it retries.
+type GenerationRecord struct {
+	Generation model.Generation
+	Transcript model.Transcript
+}
+
+// newGenerationRecord starts the record of a generation with provider, or
+// continues a copy of prior when it is set
+func newGenerationRecord(prior *GenerationRecord, provider llm.Provider) *GenerationRecord {
+	if prior != nil {
+		record := *prior
+		record.Transcript.Exchanges = slices.Clone(prior.Transcript.Exchanges)
+		return &record
+	}
+	record := &GenerationRecord{Generation: model.Generation{StartedAt: time.Now()}}
+	if provider != nil {
+		record.Generation.LLM = llm.Describe(provider)
+	}
+	return record
+}
+
+// addExchange records one request to the LLM
+func (r *GenerationRecord) addExchange(exchange model.Exchange) {
+	r.Transcript.Exchanges = append(r.Transcript.Exchanges, exchange)
+	r.Generation.Requests++
+}
+
+// absorb adds the exchanges of other, the record of requests made alongside
+// this generation's
+func (r *GenerationRecord) absorb(other *GenerationRecord) {
+	for _, exchange := range other.Transcript.Exchanges {
+		r.addExchange(exchange)
+		if exchange.JSONRepaired {
+			r.Generation.JSONRepaired = true
+		}
+	}
+}
+
+// markJSONRepaired notes that the latest response was malformed JSON that
+// had to be repaired
+func (r *GenerationRecord) markJSONRepaired() {
+	r.Generation.JSONRepaired = true
+	if n := len(r.Transcript.Exchanges); n > 0 {
+		r.Transcript.Exchanges[n-1].JSONRepaired = true
+	}
+}
+
+// addElapsed adds the time since started to the generation's duration
+func (r *GenerationRecord) addElapsed(started time.Time) {
+	r.Generation.DurationMs += time.Since(started).Milliseconds()
+}
+
+// finish archives the transcript for workDir, if any requests were made, and
+// returns the metadata to save in the review. A transcript that can't be
+// archived is left out rather than failing the generation.
+func (r *GenerationRecord) finish(store *storage.Store, workDir string, logger *slog.Logger) *model.Generation {
+	generation := r.Generation
+	if len(r.Transcript.Exchanges) > 0 && store != nil {
+		id, err := store.WriteTranscript(workDir, r.Transcript)
+		if err != nil {
+			if logger != nil {
+				logger.Warn("failed to archive generation transcript", "error", err)
+			}
+		} else {
+			generation.Transcript = id
+		}
+	}
+	return &generation
+}
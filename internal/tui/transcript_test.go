@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mchowning/diffstory/internal/llm"
+	"github.com/mchowning/diffstory/internal/storage"
+)
+
+func TestGenerateReviewCmd_RecordsGeneration(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(classifyAPatch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The reply has a trailing comma, so it needs JSON repair
+	malformed := strings.TrimSuffix(classifyA, "}") + ",}"
+	params := GenerateParams{
+		DiffCommand:   []string{"cat", "changes.patch"},
+		DiffSource:    "Patch",
+		LLM:           llm.Command{Args: []string{"sh", "-c", "echo '" + malformed + "'", "llm"}, Delivery: llm.DeliverFile},
+		CacheMaxBytes: 1000000,
+	}
+
+	if msg := generateReviewCmd(context.Background(), workDir, store, nil, params)(); msg != (GenerateSuccessMsg{}) {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+	review := storedReview(store, workDir, "", "Patch")
+	if review == nil || review.Generation == nil {
+		t.Fatalf("expected a review with a generation record, got %+v", review)
+	}
+	generation := *review.Generation
+	if generation.LLM != "sh -c echo '"+malformed+"' llm" || generation.HunkCount != 1 || generation.Batches != 1 ||
+		generation.Requests != 1 || !generation.JSONRepaired || generation.Cached || generation.StartedAt.IsZero() {
+		t.Errorf("unexpected generation record %+v", generation)
+	}
+
+	transcript, err := store.ReadTranscript(workDir, generation.Transcript)
+	if err != nil {
+		t.Fatalf("expected the transcript to be archived: %v", err)
+	}
+	if len(transcript.Exchanges) != 1 {
+		t.Fatalf("expected 1 exchange, got %d", len(transcript.Exchanges))
+	}
+	exchange := transcript.Exchanges[0]
+	if exchange.Purpose != "classification" || !strings.Contains(exchange.Prompt, "Read the input hunks from this JSON file") ||
+		!strings.Contains(exchange.Input, `"id": "a.go::1"`) || strings.TrimSpace(exchange.Response) != malformed || !exchange.JSONRepaired {
+		t.Errorf("unexpected exchange %+v", exchange)
+	}
+
+	// Generating again uses the cache, making no requests to record
+	if msg := generateReviewCmd(context.Background(), workDir, store, nil, params)(); msg != (GenerateSuccessMsg{Cached: true}) {
+		t.Fatalf("expected a cached GenerateSuccessMsg, got %#v", msg)
+	}
+	generation = *storedReview(store, workDir, "", "Patch").Generation
+	if !generation.Cached || generation.Requests != 0 || generation.Transcript != "" {
+		t.Errorf("unexpected generation record for a cached classification %+v", generation)
+	}
+}
+
+func TestGenerateReviewCmd_RetryContinuesGenerationRecord(t *testing.T) {
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "changes.patch"), []byte(twoFilePatch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	calls := filepath.Join(t.TempDir(), "calls")
+	placeB := `{"title":"T","chapters":[{"id":"c","title":"C","sections":[{"id":"s","title":"S","what":"w","why":"y","hunks":[{"id":"b.go::1","importance":"low"}]}]}]}`
+	params := GenerateParams{
+		DiffCommand: []string{"cat", "changes.patch"},
+		DiffSource:  "Patch",
+		LLM:         llm.Command{Args: []string{"sh", "-c", repairScript(""), "llm", calls}, Delivery: llm.DeliverArgument},
+	}
+
+	failed, ok := generateReviewCmd(context.Background(), workDir, store, nil, params)().(GenerateValidationFailedMsg)
+	if !ok {
+		t.Fatal("expected GenerateValidationFailedMsg")
+	}
+	if failed.Record == nil || failed.Record.Generation.Requests != 1 {
+		t.Fatalf("expected the failed generation's record, got %+v", failed.Record)
+	}
+
+	params.Repair = failed.Response
+	params.ParsedHunks = failed.Hunks
+	params.Provenance = failed.Provenance
+	params.Record = failed.Record
+	params.MaxRepairAttempts = 1
+	params.LLM = llm.Command{Args: []string{"sh", "-c", repairScript(placeB), "llm", calls}, Delivery: llm.DeliverArgument}
+	if msg := generateReviewCmd(context.Background(), workDir, store, nil, params)(); msg != (GenerateSuccessMsg{}) {
+		t.Fatalf("expected GenerateSuccessMsg, got %#v", msg)
+	}
+
+	generation := storedReview(store, workDir, "", "Patch").Generation
+	if generation.Requests != 2 || generation.RepairAttempts != 1 {
+		t.Errorf("expected the retry to continue the record, got %+v", generation)
+	}
+	transcript, err := store.ReadTranscript(workDir, generation.Transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcript.Exchanges) != 2 || transcript.Exchanges[1].Purpose != "repair, attempt 1" {
+		t.Errorf("unexpected exchanges %+v", transcript.Exchanges)
+	}
+}
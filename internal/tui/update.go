@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -69,6 +70,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateUntrackedWarning(msg)
 		}
 
+		if m.reviewListMode != ReviewListNone {
+			return m.updateReviewList(msg)
+		}
+
+		if m.noteTarget != nil {
+			return m.updateNoteInput(msg)
+		}
+
+		if m.showNotes {
+			return m.updateNotes(msg)
+		}
+
+		if m.generationView != nil {
+			return m.updateGeneration(msg)
+		}
+
 		// Handle arrow keys for panel focus cycling
 		switch msg.Type {
 		case tea.KeyLeft:
@@ -192,6 +209,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.flattenedFiles = Flatten(m.fileTree, m.collapsedPaths)
 				}
 			}
+		case " ":
+			if m.review == nil {
+				return m, nil
+			}
+			if m.focusedPanel == PanelDiff {
+				return m, m.toggleHunkReviewed()
+			}
+			return m, m.toggleSectionReviewed()
+		case "v":
+			if m.review != nil {
+				m.toggleHideReviewed()
+			}
+		case "c", "C":
+			if m.review != nil {
+				return m.startNote(msg.String() == "C")
+			}
+		case "N":
+			if m.review != nil {
+				return m.openNotes()
+			}
+		case "i":
+			if m.review != nil {
+				return m.openGeneration()
+			}
 		case "?":
 			m.showHelp = !m.showHelp
 		case "f":
@@ -227,10 +268,28 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.statusMsg = "Storage not initialized"
 				return m, nil
 			}
-			m.resolvedLLMCommand = result.Command
+			m.resolvedLLM = result.Provider
 			m.generateUIState = GenerateUIStateSourcePicker
 			m.diffSourceSelected = 0
 			return m, nil
+		case "H":
+			if m.isGenerating {
+				return m, nil
+			}
+			if m.store == nil {
+				m.statusMsg = "Storage not initialized"
+				return m, nil
+			}
+			return m, loadHistoryCmd(m.store, m.workDir)
+		case "R":
+			if m.isGenerating {
+				return m, nil
+			}
+			if m.store == nil {
+				m.statusMsg = "Storage not initialized"
+				return m, nil
+			}
+			return m, loadStoredReviewsCmd(m.store, m.workDir)
 		case "y":
 			if m.showCancelPrompt && m.cancelGenerate != nil {
 				m.cancelGenerate()
@@ -388,19 +447,75 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.viewport.Height = viewportHeight
 		}
 		m.updateViewportContent()
+	case ReviewSavedMsg:
+		return m, m.finishSave(msg)
 	case ReviewReceivedMsg:
-		m.review = &msg.Review
+		generated := m.generatingSource != "" && msg.Review.DiffSource == m.generatingSource
+		if msg.Watched && m.loadedReview != nil && !sameReviewKey(*m.loadedReview, msg.Review) && !generated {
+			// Another source or branch, such as one generated in the
+			// background: don't take over the review being read
+			m.statusMsg = "Another review was updated: press R to switch to it"
+			if origin := describeReviewOrigin(msg.Review.DiffSource, msg.Review.Branch); origin != "" {
+				m.statusMsg = fmt.Sprintf("Another review was updated (%s): press R to switch to it", origin)
+			}
+			return m, tea.Tick(5*time.Second, func(time.Time) tea.Msg {
+				return ClearStatusMsg{}
+			})
+		}
+		if generated {
+			m.generatingSource = ""
+		}
+		if m.onShow != nil {
+			m.onShow(msg.Review)
+		}
+		if m.loadedReview != nil && sameReview(*m.loadedReview, msg.Review) {
+			if m.saving {
+				// One of our own saves, older than the reader's state
+				return m, nil
+			}
+			// Reloaded after saving progress: keep the reader's place
+			m.setReview(&msg.Review)
+			m.clampSelection()
+			m.updateViewportContent()
+			return m, nil
+		}
+		m.setReview(&msg.Review)
+		m.noteTarget = nil
+		m.showNotes = false
+		m.generationView = nil
 		m.selected = 0
 		m.sectionScrollOffset = 0
 		m.filesScrollOffset = 0
 		m.viewport.GotoTop()
 		m.updateFileTree()
 		m.updateViewportContent()
-		return m, nil
+		m.staleReason = ""
+		return m, m.staleCheck()
 	case ReviewClearedMsg:
-		m.review = nil
+		m.setReview(nil)
+		m.noteTarget = nil
+		m.showNotes = false
+		m.generationView = nil
 		m.selected = 0
+		m.staleReason = ""
 		return m, nil
+	case ReviewStalenessMsg:
+		if m.review == nil || !m.review.CreatedAt.Equal(msg.CreatedAt) {
+			return m, nil // Result for a review that is no longer shown
+		}
+		if msg.Err != nil {
+			if m.logger != nil {
+				m.logger.Warn("staleness check failed", "error", msg.Err)
+			}
+			return m, nil
+		}
+		m.staleReason = msg.Reason
+		return m, scheduleStalenessCheck(m.review)
+	case StaleCheckTickMsg:
+		if m.review == nil || !m.review.CreatedAt.Equal(msg.CreatedAt) {
+			return m, nil
+		}
+		return m, m.staleCheck()
 	case WatchErrorMsg:
 		if m.logger != nil {
 			m.logger.Error("watch error", "error", msg.Err)
@@ -424,6 +539,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Just stop the spinner - the watcher will deliver the review
 		m.isGenerating = false
 		m.cancelGenerate = nil
+		if msg.Cached {
+			m.statusMsg = "Used the cached classification of this diff (press f in the source picker to ask again)"
+			return m, tea.Tick(5*time.Second, func(time.Time) tea.Msg {
+				return ClearStatusMsg{}
+			})
+		}
 		return m, nil
 	case GenerateErrorMsg:
 		if m.logger != nil {
@@ -431,11 +552,25 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.isGenerating = false
 		m.cancelGenerate = nil
-		m.review = nil // Clear stale review
+		m.setReview(nil) // Clear stale review
 		m.statusMsg = "Error: " + msg.Err.Error()
 		return m, tea.Tick(5*time.Second, func(time.Time) tea.Msg {
 			return ClearStatusMsg{}
 		})
+	case GenerateValidationFailedMsg:
+		if m.logger != nil {
+			m.logger.Warn("classification incomplete after repair", "missing", len(msg.Missing), "attempts", msg.Attempts)
+		}
+		m.isGenerating = false
+		m.cancelGenerate = nil
+		m.parsedHunks = msg.Hunks
+		m.missingHunkIDs = msg.Missing
+		m.repairAttempts = msg.Attempts
+		m.lastLLMResponse = msg.Response
+		m.lastProvenance = msg.Provenance
+		m.lastRecord = msg.Record
+		m.generateUIState = GenerateUIStateValidationError
+		return m, nil
 	case GenerateCancelledMsg:
 		m.isGenerating = false
 		m.cancelGenerate = nil
@@ -443,6 +578,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Tick(3*time.Second, func(time.Time) tea.Msg {
 			return ClearStatusMsg{}
 		})
+	case TranscriptLoadedMsg:
+		if m.generationView == nil || m.loadedReview == nil || m.loadedReview.Generation == nil ||
+			m.loadedReview.Generation.Transcript != msg.ID {
+			return m, nil // The overlay has moved on
+		}
+		view := *m.generationView
+		view.transcript, view.err = msg.Transcript, msg.Err
+		m.generationView = &view
+		return m, nil
+	case HistoryListMsg:
+		return m.openReviewList(ReviewListHistory, msg.Entries, "No review history for this directory")
+	case StoredReviewsMsg:
+		return m.openReviewList(ReviewListStored, msg.Entries, "No stored reviews for this directory")
 	case CommitListMsg:
 		m.commits = msg.Commits
 		m.commitSelected = 0
@@ -473,6 +621,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// No untracked files, proceed with generation
 		m.generateUIState = GenerateUIStateNone
 		return m, m.startGeneration()
+	case StoredSourceMsg:
+		// Ignore answers for a source the user has since moved away from
+		if m.generateUIState == GenerateUIStateContextInput && m.selectedDiffSource != nil &&
+			m.selectedDiffSource.Label == msg.DiffSource {
+			m.incrementalAvailable = msg.Exists
+			m.incrementalGenerate = msg.Exists
+		}
+		return m, nil
 	case StageCompleteMsg:
 		// Staging complete, start generation
 		m.generateUIState = GenerateUIStateNone
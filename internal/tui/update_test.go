@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mchowning/diffstory/internal/config"
@@ -2423,6 +2424,28 @@ func TestNewModel_WithInitialReview_SetsReview(t *testing.T) {
 	}
 }
 
+func TestNewModel_WithoutStaleCheck_DoesNotCheckTheReview(t *testing.T) {
+	review := &model.Review{
+		WorkingDirectory: t.TempDir(),
+		Title:            "From a file",
+		Provenance:       &model.Provenance{DiffCommand: []string{"git", "diff", "HEAD"}, DiffHash: "x"},
+	}
+	m := tui.NewModel("/test/project", nil, nil, nil, tui.WithInitialReview(review), tui.WithoutStaleCheck())
+
+	msgs := []tea.Msg{m.Init()()}
+	if batch, ok := msgs[0].(tea.BatchMsg); ok {
+		msgs = nil
+		for _, cmd := range batch {
+			msgs = append(msgs, cmd())
+		}
+	}
+	for _, msg := range msgs {
+		if _, ok := msg.(tui.ReviewStalenessMsg); ok {
+			t.Error("expected no staleness check for a review loaded from a file")
+		}
+	}
+}
+
 func TestNewModel_WithInitialReview_NilReviewIsNoOp(t *testing.T) {
 	m := tui.NewModel("/test/project", nil, nil, nil, tui.WithInitialReview(nil))
 
@@ -3091,3 +3114,231 @@ func TestUpdate_ClickOnDiffFocusesDiffPanel(t *testing.T) {
 		t.Errorf("FocusedPanel() = %d, want PanelDiff after clicking on diff panel", result.FocusedPanel())
 	}
 }
+
+func TestUpdate_StalenessForOtherReviewIsIgnored(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	review := model.NewReviewWithSections("/test/project", "Test Review", []model.Section{{ID: "1"}})
+	review.CreatedAt = time.Now()
+	updated, _ := m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	updated, _ = m.Update(tui.ReviewStalenessMsg{CreatedAt: review.CreatedAt.Add(-time.Hour), Reason: "HEAD has moved"})
+	m = updated.(tui.Model)
+
+	if m.StaleReason() != "" {
+		t.Errorf("expected staleness of another review to be ignored, got %q", m.StaleReason())
+	}
+}
+
+func TestUpdate_NewReviewClearsStaleReason(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	review := model.NewReviewWithSections("/test/project", "Test Review", []model.Section{{ID: "1"}})
+	review.CreatedAt = time.Now().Add(-time.Hour)
+	updated, _ := m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+	updated, _ = m.Update(tui.ReviewStalenessMsg{CreatedAt: review.CreatedAt, Reason: "HEAD has moved"})
+	m = updated.(tui.Model)
+	if m.StaleReason() != "HEAD has moved" {
+		t.Fatalf("expected stale reason to be set, got %q", m.StaleReason())
+	}
+
+	fresh := model.NewReviewWithSections("/test/project", "Fresh", []model.Section{{ID: "1"}})
+	fresh.CreatedAt = time.Now()
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: fresh})
+	m = updated.(tui.Model)
+
+	if m.StaleReason() != "" {
+		t.Errorf("expected new review to clear stale reason, got %q", m.StaleReason())
+	}
+}
+
+func TestUpdate_RegeneratingLoadedSourceDefaultsToIncremental(t *testing.T) {
+	cfg := &config.Config{LLMCommand: []string{"echo", "test"}}
+	store, _ := storage.NewStoreWithDir(t.TempDir())
+	m := tui.NewModel("/test/project", cfg, store, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+
+	review := model.NewReviewWithSections("/test/project", "Test", []model.Section{
+		{ID: "1", Hunks: []model.Hunk{{File: "a.go", Diff: "@@ -1 +1 @@\n-a\n+b", Importance: "high"}}},
+	})
+	review.DiffSource = "Uncommitted changes"
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	// G, then Enter on "Uncommitted changes"
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	m = updated.(tui.Model)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = runCmd(updated.(tui.Model), cmd)
+
+	if !m.IncrementalGenerate() {
+		t.Fatal("expected incremental mode when regenerating the loaded review's source")
+	}
+	if !strings.Contains(m.View(), "Incremental: keep the current story") {
+		t.Error("expected the context input to show incremental mode")
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updated.(tui.Model)
+	if m.IncrementalGenerate() {
+		t.Error("expected Tab to switch to full regeneration")
+	}
+	if !strings.Contains(m.View(), "Full: classify every hunk again") {
+		t.Error("expected the context input to show full mode")
+	}
+}
+
+func TestUpdate_OtherSourceIsNotIncremental(t *testing.T) {
+	cfg := &config.Config{LLMCommand: []string{"echo", "test"}}
+	store, _ := storage.NewStoreWithDir(t.TempDir())
+	m := tui.NewModel("/test/project", cfg, store, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+
+	review := model.NewReviewWithSections("/test/project", "Test", []model.Section{
+		{ID: "1", Hunks: []model.Hunk{{File: "a.go", Diff: "@@ -1 +1 @@\n-a\n+b", Importance: "high"}}},
+	})
+	review.DiffSource = "Staged changes"
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	m = updated.(tui.Model)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = runCmd(updated.(tui.Model), cmd)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updated.(tui.Model)
+
+	if m.IncrementalGenerate() {
+		t.Error("expected full regeneration for a source other than the loaded review's")
+	}
+	if strings.Contains(m.View(), "full/incremental") {
+		t.Error("expected no mode switch to be offered")
+	}
+}
+
+func TestUpdate_IncrementalFollowsTheStoreNotTheLoadedReview(t *testing.T) {
+	cfg := &config.Config{LLMCommand: []string{"echo", "test"}}
+	store, _ := storage.NewStoreWithDir(t.TempDir())
+	m := tui.NewModel("/test/project", cfg, store, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+
+	// The loaded review is of the selected source but was never stored for
+	// this branch, so there is nothing for the generator to update
+	review := model.NewReviewWithSections("/test/project", "Test", []model.Section{
+		{ID: "1", Hunks: []model.Hunk{{File: "a.go", Diff: "@@ -1 +1 @@\n-a\n+b", Importance: "high"}}},
+	})
+	review.DiffSource = "Uncommitted changes"
+	review.Branch = "elsewhere"
+	if err := store.Write(review); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	m = updated.(tui.Model)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = runCmd(updated.(tui.Model), cmd)
+
+	if m.IncrementalGenerate() {
+		t.Error("expected full regeneration when no review of the source is stored for the branch")
+	}
+	if strings.Contains(m.View(), "full/incremental") {
+		t.Error("expected no mode switch to be offered")
+	}
+}
+
+func TestUpdate_GenerateValidationFailedMsgShowsMissingHunks(t *testing.T) {
+	cfg := &config.Config{LLMCommand: []string{"echo", "test"}}
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	m := tui.NewModel("/test/project", cfg, store, nil)
+	m = m.SetGenerating(true)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	m = updated.(tui.Model)
+
+	updated, _ = m.Update(tui.GenerateValidationFailedMsg{
+		Missing:  []string{"b.go::1"},
+		Response: &tui.LLMResponse{Title: "Partial"},
+		Attempts: 2,
+	})
+	result := updated.(tui.Model)
+
+	if result.IsGenerating() {
+		t.Error("expected IsGenerating() to be false after validation failure")
+	}
+	if result.GenerateUIState() != tui.GenerateUIStateValidationError {
+		t.Fatalf("expected GenerateUIStateValidationError, got %v", result.GenerateUIState())
+	}
+	view := result.View()
+	if !strings.Contains(view, "Still missing after 2 repair attempts") || !strings.Contains(view, "b.go::1") {
+		t.Errorf("expected the missing hunks and attempts in the view, got:\n%s", view)
+	}
+
+	updated, _ = result.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if state := updated.(tui.Model).GenerateUIState(); state != tui.GenerateUIStateNone {
+		t.Errorf("expected Esc to dismiss the dialog, got %v", state)
+	}
+}
+
+func TestUpdate_FInSourcePickerIgnoresCachedResults(t *testing.T) {
+	cfg := &config.Config{LLMCommand: []string{"echo", "test"}}
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	m := tui.NewModel("/test/project", cfg, store, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	m = updated.(tui.Model)
+
+	for _, key := range []string{"G", "f"} {
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(tui.Model)
+	}
+
+	if m.GenerateUIState() != tui.GenerateUIStateContextInput {
+		t.Fatalf("expected f to select the source, got %v", m.GenerateUIState())
+	}
+	if !strings.Contains(m.View(), "Ignoring cached results") {
+		t.Errorf("expected the context input to say cached results are ignored, got:\n%s", m.View())
+	}
+
+	// Selecting with Enter uses the cache again
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(tui.Model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(tui.Model)
+	if strings.Contains(m.View(), "Ignoring cached results") {
+		t.Error("expected Enter to use cached results")
+	}
+}
+
+func TestUpdate_CachedGenerateSuccessMsgShowsStatus(t *testing.T) {
+	cfg := &config.Config{LLMCommand: []string{"echo", "test"}}
+	store, err := storage.NewStoreWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	m := tui.NewModel("/test/project", cfg, store, nil).SetGenerating(true)
+
+	updated, _ := m.Update(tui.GenerateSuccessMsg{Cached: true})
+	result := updated.(tui.Model)
+
+	if result.IsGenerating() {
+		t.Error("expected IsGenerating() to be false after success")
+	}
+	if !strings.Contains(result.StatusMsg(), "cached classification") {
+		t.Errorf("StatusMsg() = %q, want to mention the cached classification", result.StatusMsg())
+	}
+}
@@ -3,11 +3,12 @@ package tui
 import (
 	"github.com/mchowning/diffstory/internal/diff"
 	"github.com/mchowning/diffstory/internal/model"
+	"github.com/mchowning/diffstory/internal/schema"
 )
 
 // LLMResponse is the classification returned by the LLM
 type LLMResponse struct {
-	Title    string       `json:"title"`
+	Title    string       `json:"title" desc:"Brief title for this review"`
 	Chapters []LLMChapter `json:"chapters"`
 }
 
@@ -22,16 +23,23 @@ type LLMChapter struct {
 type LLMSection struct {
 	ID    string       `json:"id"`
 	Title string       `json:"title"`
-	What  string       `json:"what"`
-	Why   string       `json:"why"`
+	What  string       `json:"what" desc:"One sentence describing what changed"`
+	Why   string       `json:"why" desc:"One sentence explaining why the change was made"`
 	Hunks []LLMHunkRef `json:"hunks"`
 }
 
 // LLMHunkRef references a hunk by ID with its classified importance
 type LLMHunkRef struct {
-	ID         string `json:"id"`
-	Importance string `json:"importance"`
-	IsTest     *bool  `json:"isTest,omitempty"`
+	ID         string `json:"id" desc:"id of an input hunk; every input hunk must appear exactly once"`
+	Importance string `json:"importance" enum:"high,medium,low"`
+	IsTest     *bool  `json:"isTest,omitempty" desc:"true for test code, false for production code"`
+}
+
+// ClassificationSchema returns the JSON Schema of LLMResponse, which is
+// included in the classification prompt
+func ClassificationSchema() *schema.Schema {
+	return schema.For(LLMResponse{}, "diffstory classification",
+		"Classification of diff hunks into chapters and sections, returned by the LLM")
 }
 
 // ValidationResult holds the results of classification validation
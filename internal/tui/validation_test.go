@@ -2,6 +2,7 @@ package tui
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/mchowning/diffstory/internal/diff"
@@ -264,3 +265,18 @@ func TestValidateClassification_EmptyInput(t *testing.T) {
 		t.Errorf("expected Valid to be true for empty input")
 	}
 }
+
+func TestClassificationSchema_MatchesLLMResponse(t *testing.T) {
+	s := ClassificationSchema()
+
+	if got := strings.Join(s.Required, ","); got != "title,chapters" {
+		t.Errorf("required = %s, want title,chapters", got)
+	}
+	hunkRef := s.Defs["LLMHunkRef"]
+	if hunkRef == nil {
+		t.Fatal("expected LLMHunkRef in $defs")
+	}
+	if got := strings.Join(hunkRef.Required, ","); got != "id,importance" {
+		t.Errorf("hunk ref required = %s, want id,importance", got)
+	}
+}
@@ -2,10 +2,12 @@ package tui
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mchowning/diffstory/internal/diff"
 	"github.com/mchowning/diffstory/internal/highlight"
 	"github.com/mchowning/diffstory/internal/model"
 	"github.com/mchowning/diffstory/internal/timeutil"
@@ -43,6 +45,22 @@ func (m Model) View() string {
 		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
 	}
 
+	if m.reviewListMode != ReviewListNone {
+		return m.renderReviewList()
+	}
+
+	if m.noteTarget != nil {
+		return m.renderNoteInput()
+	}
+
+	if m.showNotes {
+		return m.renderNotes()
+	}
+
+	if m.generationView != nil {
+		return m.renderGeneration()
+	}
+
 	if m.review == nil {
 		return m.renderEmptyState()
 	}
@@ -73,6 +91,8 @@ func (m Model) renderEmptyState() string {
     ` + m.workDir + `
 
     Generate: Press Shift+G
+    History:  Press Shift+H
+    Reviews:  Press Shift+R
 ` + status + `
     q: quit | ?: help
 `
@@ -119,7 +139,7 @@ func (m Model) renderReviewState() string {
 	// Join Description and Diff vertically to create right column
 	rightColumn := lipgloss.JoinVertical(lipgloss.Left, descriptionPane, diffPane)
 
-	header := headerStyle.Render("diffstory - " + m.review.Title)
+	header := headerStyle.Render("diffstory - "+m.review.Title) + m.renderProgress()
 	filterLine := m.renderFilterIndicator()
 	footer := "j/k: navigate | J/K: scroll | h/l: panels | f: importance filter | t: test filter | q: quit | ?: help"
 	if m.statusMsg != "" {
@@ -154,12 +174,36 @@ func (m Model) renderTestFilterIndicator() string {
 	}
 }
 
+// renderProgress renders the reviewed section count shown after the title
+func (m Model) renderProgress() string {
+	if m.loadedReview == nil {
+		return ""
+	}
+	total := m.loadedReview.SectionCount()
+	if total == 0 {
+		return ""
+	}
+	progress := fmt.Sprintf("  %d/%d reviewed", m.loadedReview.ReviewedSectionCount(), total)
+	if m.hideReviewed {
+		progress += " (reviewed hidden)"
+	}
+	return reviewedMarkerStyle.Render(progress)
+}
+
 func (m Model) renderTimestamp() string {
 	if m.review == nil || m.review.CreatedAt.IsZero() {
 		return ""
 	}
 	relative := timeutil.FormatRelative(m.review.CreatedAt, time.Now())
-	return timestampStyle.Render("Review generated " + relative)
+	line := "Review generated " + relative
+	if origin := describeReviewOrigin(m.review.DiffSource, m.review.Branch); origin != "" {
+		line += " · " + origin
+	}
+	rendered := timestampStyle.Render(line)
+	if m.staleReason != "" {
+		rendered += " " + staleBadgeStyle.Render("stale: "+m.staleReason)
+	}
+	return rendered
 }
 
 func (m Model) sectionHasVisibleHunks(section model.Section) bool {
@@ -321,11 +365,22 @@ func (m Model) renderSection(section model.Section, flatIdx, contentWidth int) s
 	maxTitleWidth := contentWidth - len(selectedPrefix)
 	displayTitle := Truncate(title, maxTitleWidth)
 
+	prefix := normalPrefix
+	if isSelected {
+		prefix = selectedPrefix
+	}
+	if section.IsReviewed() {
+		prefix = reviewedPrefix
+	}
+
 	if isSelected {
-		return selectedStyle.Render(selectedPrefix + displayTitle)
+		return selectedStyle.Render(prefix + displayTitle)
+	}
+	if section.IsReviewed() {
+		return reviewedMarkerStyle.Render(prefix + displayTitle)
 	}
 
-	return normalStyle.Render(normalPrefix + displayTitle)
+	return normalStyle.Render(prefix + displayTitle)
 }
 
 func (m Model) renderDescriptionPane(width, height int) string {
@@ -539,6 +594,11 @@ func (m Model) renderFilesContent(width int) string {
 		endIdx = len(m.flattenedFiles)
 	}
 
+	var changeLabels map[string]string
+	if sections := m.review.AllSections(); m.selected < len(sections) {
+		changeLabels = fileChangeLabels(sections[m.selected])
+	}
+
 	var lines []string
 	for i := startIdx; i < endIdx; i++ {
 		node := m.flattenedFiles[i]
@@ -562,6 +622,9 @@ func (m Model) renderFilesContent(width int) string {
 		}
 
 		line := prefix + indentStr + indicator + node.Name
+		if label := changeLabels[node.FullPath]; label != "" && !node.IsDir {
+			line += dimStyle.Render(" (" + label + ")")
+		}
 
 		if i == m.selectedFile {
 			line = selectedStyle.Width(width).Render(line)
@@ -608,87 +671,127 @@ func (m Model) renderDiffPaneWithTitle(width, height int) string {
 	return renderBorderedPanelWithScrollbar(title, content, width, height, m.focusedPanel == PanelDiff, scrollbar)
 }
 
-func (m Model) renderDiffContent(section model.Section) string {
-	var content strings.Builder
-	var lastFile string
+// diffLineRef identifies what a rendered line of the diff viewport shows
+type diffLineRef struct {
+	Hunk int // Index into the section's hunks, or -1 for spacing between hunks
+	Line int // Index into the hunk's diff lines, or -1 for headers and markers
+}
 
-	for _, hunk := range section.Hunks {
-		if !m.hunkPassesFilters(hunk) {
+// renderHunks renders the hunks of section that pass the active filters and
+// include, grouped under file headers. It returns the content along with a
+// reference for every rendered line so positions in the viewport can be
+// mapped back to hunks.
+func (m Model) renderHunks(section model.Section, include func(model.Hunk) bool) (string, []diffLineRef) {
+	var lines []string
+	var refs []diffLineRef
+	add := func(line string, ref diffLineRef) {
+		lines = append(lines, line)
+		refs = append(refs, ref)
+	}
+	spacing := diffLineRef{Hunk: -1, Line: -1}
+	notes := m.notesByHunk()
+
+	var lastFile string
+	for i, hunk := range section.Hunks {
+		if !include(hunk) || !m.hunkPassesFilters(hunk) {
 			continue
 		}
+		header := diffLineRef{Hunk: i, Line: -1}
+		if len(lines) > 0 {
+			add("", spacing)
+			add("", spacing)
+			add("", spacing)
+		}
 		if hunk.File != lastFile {
-			if lastFile != "" {
-				content.WriteString("\n\n\n")
-			}
-			content.WriteString(hunk.File + "\n")
-			content.WriteString(strings.Repeat("─", 40) + "\n")
+			add(fileHeading(hunk), header)
+			add(strings.Repeat("─", 40), header)
 			lastFile = hunk.File
-		} else {
-			content.WriteString("\n\n\n")
 		}
-		coloredDiff := highlight.ColorizeDiff(hunk.Diff)
-		content.WriteString(coloredDiff + "\n")
-	}
-
-	if content.Len() == 0 {
-		return "(all hunks filtered)"
-	}
-
-	return content.String()
-}
-
-func (m Model) renderDiffForFile(section model.Section, filePath string) string {
-	var content strings.Builder
-	first := true
-
-	for _, hunk := range section.Hunks {
-		if hunk.File == filePath && m.hunkPassesFilters(hunk) {
-			if first {
-				content.WriteString(hunk.File + "\n")
-				content.WriteString(strings.Repeat("─", 40) + "\n")
-				first = false
+		if hunk.Reviewed {
+			add(reviewedMarkerStyle.Render("✓ Reviewed"), header)
+		}
+		hunkNotes := notes[hunk.Identity()]
+		for _, n := range hunkNotes {
+			if n.Line == 0 {
+				add(noteStyle.Render("✎ "+n.Text), header)
+			}
+		}
+		parents := diff.Parents(hunk.Diff)
+		for j, line := range strings.Split(hunk.Diff, "\n") {
+			if hunk.Binary && isBinaryMarker(line) {
+				add(binarySummaryStyle.Render(binarySummary(hunk)), diffLineRef{Hunk: i, Line: j})
+			} else if hunk.IsFileHeader() {
+				add(dimStyle.Render(line), diffLineRef{Hunk: i, Line: j})
+			} else if parents > 1 {
+				add(highlight.ColorizeCombinedDiffLine(line, parents), diffLineRef{Hunk: i, Line: j})
 			} else {
-				content.WriteString("\n\n\n")
+				add(highlight.ColorizeDiffLine(line), diffLineRef{Hunk: i, Line: j})
+			}
+			for _, n := range hunkNotes {
+				if n.Line == j+1 {
+					add(noteStyle.Render("  ✎ "+n.Text), header)
+				}
 			}
-			coloredDiff := highlight.ColorizeDiff(hunk.Diff)
-			content.WriteString(coloredDiff + "\n")
 		}
 	}
 
-	if content.Len() == 0 {
-		return "(all hunks filtered)"
+	if len(lines) == 0 {
+		return "(all hunks filtered)", nil
 	}
 
-	return content.String()
+	return strings.Join(lines, "\n") + "\n", refs
 }
 
-func (m Model) renderDiffForDirectory(section model.Section, dirPath string) string {
-	var content strings.Builder
-	var lastFile string
+// fileHeading names a hunk's file above its diff, along with how the file
+// changed when it was not simply modified
+func fileHeading(hunk model.Hunk) string {
+	switch hunk.ChangeKind {
+	case model.ChangeAdded:
+		return hunk.File + " (new file)"
+	case model.ChangeDeleted:
+		return hunk.File + " (deleted)"
+	case model.ChangeRenamed, model.ChangeCopied:
+		return fmt.Sprintf("%s → %s (%s)", hunk.OldFile, hunk.File, hunk.ChangeKind)
+	case model.ChangeModeChanged:
+		return hunk.File + " (mode changed)"
+	default:
+		return hunk.File
+	}
+}
 
-	for _, hunk := range section.Hunks {
-		inDir := strings.HasPrefix(hunk.File, dirPath+"/") || hunk.File == dirPath
-		if inDir && m.hunkPassesFilters(hunk) {
-			if hunk.File != lastFile {
-				if lastFile != "" {
-					content.WriteString("\n\n\n")
-				}
-				content.WriteString(hunk.File + "\n")
-				content.WriteString(strings.Repeat("─", 40) + "\n")
-				lastFile = hunk.File
-			} else {
-				content.WriteString("\n\n\n")
-			}
-			coloredDiff := highlight.ColorizeDiff(hunk.Diff)
-			content.WriteString(coloredDiff + "\n")
+// fileChangeLabels maps each file in a section to a short label for the files
+// pane describing how it changed, for files that were not simply modified
+func fileChangeLabels(section model.Section) map[string]string {
+	labels := make(map[string]string)
+	for _, h := range section.Hunks {
+		switch h.ChangeKind {
+		case model.ChangeAdded:
+			labels[h.File] = "new"
+		case model.ChangeDeleted:
+			labels[h.File] = "deleted"
+		case model.ChangeRenamed, model.ChangeCopied:
+			labels[h.File] = h.ChangeKind
+		case model.ChangeModeChanged:
+			labels[h.File] = "mode"
+		}
+		if h.Binary {
+			addFileLabel(labels, h.File, "binary")
+		}
+		if diff.Parents(h.Diff) > 1 {
+			addFileLabel(labels, h.File, "merge")
 		}
 	}
+	return labels
+}
 
-	if content.Len() == 0 {
-		return "(all hunks filtered)"
+// addFileLabel appends label to a file's labels, if it has not already got it
+func addFileLabel(labels map[string]string, file, label string) {
+	switch existing := labels[file]; {
+	case existing == "":
+		labels[file] = label
+	case !slices.Contains(strings.Split(existing, ", "), label):
+		labels[file] = existing + ", " + label
 	}
-
-	return content.String()
 }
 
 func (m Model) renderHelpOverlay(base string) string {
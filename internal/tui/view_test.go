@@ -2002,3 +2002,119 @@ func countDescriptionPaneLines(view string) int {
 
 	return count
 }
+
+func TestView_StaleReviewShowsBadge(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(tui.Model)
+
+	review := model.NewReviewWithSections("/test/project", "Test Review", []model.Section{{ID: "1", What: "Section"}})
+	review.CreatedAt = time.Now().Add(-2 * time.Hour)
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	if strings.Contains(m.View(), "stale") {
+		t.Fatal("fresh review should not show a stale badge")
+	}
+
+	updated, _ = m.Update(tui.ReviewStalenessMsg{CreatedAt: review.CreatedAt, Reason: "diff has changed"})
+	m = updated.(tui.Model)
+
+	if !strings.Contains(m.View(), "stale: diff has changed") {
+		t.Error("stale review should show a stale badge next to the timestamp")
+	}
+}
+
+func TestView_ShowsFileChangeKinds(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 160, Height: 50})
+	m = updated.(tui.Model)
+
+	review := model.NewReviewWithSections("/test/project", "Test", []model.Section{
+		{
+			ID:   "1",
+			What: "File changes",
+			Hunks: []model.Hunk{
+				{File: "pkg/renamed.go", OldFile: "pkg/original.go", ChangeKind: model.ChangeRenamed, Diff: "similarity index 100%\nrename from pkg/original.go\nrename to pkg/renamed.go"},
+				{File: "pkg/added.go", ChangeKind: model.ChangeAdded, StartLine: 1, Diff: "@@ -0,0 +1 @@\n+package added"},
+				{File: "pkg/run.sh", ChangeKind: model.ChangeModeChanged, Diff: "old mode 100644\nnew mode 100755"},
+			},
+		},
+	})
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	// The pkg directory is selected first, so the diff shows every file
+	view := m.View()
+
+	for _, want := range []string{
+		"pkg/original.go → pkg/renamed.go (renamed)",
+		"pkg/added.go (new file)",
+		"pkg/run.sh (mode changed)",
+		"rename from pkg/original.go",
+		"new mode 100755",
+		"renamed.go (renamed)",
+		"added.go (new)",
+		"run.sh (mode)",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view should contain %q", want)
+		}
+	}
+}
+
+func TestView_BinaryHunkShowsSummary(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 160, Height: 50})
+	m = updated.(tui.Model)
+
+	review := model.NewReviewWithSections("/test/project", "Test", []model.Section{
+		{
+			ID:   "1",
+			What: "Update logo",
+			Hunks: []model.Hunk{
+				{File: "logo.png", Binary: true, OldSize: 1024, NewSize: 2048, Diff: "Binary files a/logo.png and b/logo.png differ"},
+			},
+		},
+	})
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	view := m.View()
+
+	if !strings.Contains(view, "Binary file changed: 1.0 KB → 2.0 KB (+1.0 KB)") {
+		t.Error("diff should show the binary summary")
+	}
+	if strings.Contains(view, "Binary files a/logo.png") {
+		t.Error("diff should not show git's raw binary line")
+	}
+	if !strings.Contains(view, "logo.png (binary)") {
+		t.Error("files pane should mark the file as binary")
+	}
+}
+
+func TestView_CombinedHunkIsLabelledMerge(t *testing.T) {
+	m := tui.NewModel("/test/project", nil, nil, nil)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 160, Height: 50})
+	m = updated.(tui.Model)
+
+	review := model.NewReviewWithSections("/test/project", "Test", []model.Section{
+		{
+			ID:   "1",
+			What: "Resolve timeout conflict",
+			Hunks: []model.Hunk{
+				{File: "config.go", StartLine: 1, Diff: "@@@ -1,1 -1,1 +1,1 @@@\n- const Timeout = 10\n -const Timeout = 30\n++const Timeout = 20"},
+				{File: "config.go", StartLine: 9, Diff: "@@@ -9,1 -9,1 +9,1 @@@\n- a\n -b\n++c"},
+			},
+		},
+	})
+	updated, _ = m.Update(tui.ReviewReceivedMsg{Review: review})
+	m = updated.(tui.Model)
+
+	view := m.View()
+	for _, want := range []string{"config.go (merge)", "++const Timeout = 20"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view should contain %q", want)
+		}
+	}
+}
@@ -1,26 +1,33 @@
 package watcher
 
 import (
-	"encoding/json"
 	"log/slog"
 	"os"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/mchowning/diffstory/internal/model"
 	"github.com/mchowning/diffstory/internal/storage"
 )
 
-// Watcher watches for review file changes for a specific directory
+// Watcher watches for review file changes for a specific directory.
+// Reviews for every branch and diff source of the directory are delivered;
+// removal is only reported for the review being shown, which is the one
+// loaded on Start until the viewer reports another with Show.
 type Watcher struct {
 	workDir    string
 	reviewPath string
 	reviewDir  string
+	store      *storage.Store
 	fsWatcher  *fsnotify.Watcher
 	logger     *slog.Logger
 	Reviews    chan model.Review
 	Cleared    chan struct{}
 	Errors     chan error
 	done       chan struct{}
+
+	mu          sync.Mutex
+	currentPath string // file of the review being shown
 }
 
 // New creates a watcher for the given working directory.
@@ -66,6 +73,7 @@ func NewWithStore(workDir string, store *storage.Store, logger *slog.Logger) (*W
 		workDir:    normalized,
 		reviewPath: reviewPath,
 		reviewDir:  reviewDir,
+		store:      store,
 		fsWatcher:  fsWatcher,
 		logger:     logger,
 		Reviews:    make(chan model.Review, 1),
@@ -76,10 +84,15 @@ func NewWithStore(workDir string, store *storage.Store, logger *slog.Logger) (*W
 }
 
 // Start begins watching for file changes.
-// Loads any existing review file asynchronously to avoid blocking.
+// Loads the most recent existing review asynchronously to avoid blocking.
 func (w *Watcher) Start() {
 	go func() {
-		if review, err := w.loadReview(); err == nil {
+		entries, err := w.store.Reviews(w.workDir)
+		if err != nil || len(entries) == 0 {
+			return
+		}
+		if review, err := w.loadReview(entries[0].Path); err == nil {
+			w.setCurrentPath(entries[0].Path)
 			select {
 			case w.Reviews <- *review:
 			case <-w.done:
@@ -99,11 +112,14 @@ func (w *Watcher) watch() {
 			if !ok {
 				return
 			}
-			if event.Name != w.reviewPath {
+			if !w.store.IsReviewPathFor(w.workDir, event.Name) {
 				continue
 			}
 
 			if event.Has(fsnotify.Remove) {
+				if !w.isCurrentPath(event.Name) {
+					continue
+				}
 				select {
 				case w.Cleared <- struct{}{}:
 				case <-w.done:
@@ -113,9 +129,12 @@ func (w *Watcher) watch() {
 			}
 
 			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
-				review, err := w.loadReview()
+				review, err := w.loadReview(event.Name)
 				if err != nil {
 					if os.IsNotExist(err) {
+						if !w.isCurrentPath(event.Name) {
+							continue
+						}
 						select {
 						case w.Cleared <- struct{}{}:
 						case <-w.done:
@@ -124,7 +143,7 @@ func (w *Watcher) watch() {
 						continue
 					}
 					if w.logger != nil {
-						w.logger.Error("failed to load review", "path", w.reviewPath, "error", err)
+						w.logger.Error("failed to load review", "path", event.Name, "error", err)
 					}
 					select {
 					case w.Errors <- err:
@@ -155,19 +174,38 @@ func (w *Watcher) watch() {
 	}
 }
 
-func (w *Watcher) loadReview() (*model.Review, error) {
-	data, err := os.ReadFile(w.reviewPath)
+// Show tells the watcher which review the viewer shows, so that removal is
+// reported for the file it is stored in
+func (w *Watcher) Show(review model.Review) {
+	path, err := w.store.PathForReview(w.workDir, review.Branch, review.DiffSource)
 	if err != nil {
-		return nil, err
+		return
 	}
-	var review model.Review
-	if err := json.Unmarshal(data, &review); err != nil {
+	w.setCurrentPath(path)
+}
+
+func (w *Watcher) setCurrentPath(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.currentPath = path
+}
+
+func (w *Watcher) isCurrentPath(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.currentPath == path
+}
+
+func (w *Watcher) loadReview(path string) (*model.Review, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
 		return nil, err
 	}
-	return &review, nil
+	return model.ParseReview(data)
 }
 
-// ReviewPath returns the path being watched (for testing)
+// ReviewPath returns the directory-level review path, used by reviews that
+// record neither a branch nor a diff source (for testing)
 func (w *Watcher) ReviewPath() string {
 	return w.reviewPath
 }
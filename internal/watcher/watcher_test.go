@@ -422,6 +422,183 @@ func TestWatcher_CloseStopsWatching(t *testing.T) {
 	}
 }
 
+func TestWatcher_SendsReviewsForOtherBranchesAndSources(t *testing.T) {
+	dir := t.TempDir()
+	store := createTestStore(t, dir)
+	workDir := filepath.Join(dir, "project")
+	os.MkdirAll(workDir, 0755)
+
+	w, err := watcher.NewWithStore(workDir, store, discardLogger())
+	if err != nil {
+		t.Fatalf("NewWithStore failed: %v", err)
+	}
+	defer w.Close()
+
+	w.Start()
+
+	review := model.Review{
+		WorkingDirectory: workDir,
+		Title:            "Staged Review",
+		Branch:           "feature",
+		DiffSource:       "Staged changes",
+	}
+	if err := store.Write(review); err != nil {
+		t.Fatalf("store.Write failed: %v", err)
+	}
+
+	select {
+	case received := <-w.Reviews:
+		if received.Title != review.Title {
+			t.Errorf("Title = %q, want %q", received.Title, review.Title)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for keyed review")
+	}
+}
+
+func TestWatcher_LoadsMostRecentReviewOnStart(t *testing.T) {
+	dir := t.TempDir()
+	store := createTestStore(t, dir)
+	workDir := filepath.Join(dir, "project")
+	os.MkdirAll(workDir, 0755)
+
+	now := time.Now()
+	older := model.Review{WorkingDirectory: workDir, Title: "Older", DiffSource: "Staged changes", CreatedAt: now.Add(-time.Hour)}
+	newer := model.Review{WorkingDirectory: workDir, Title: "Newer", DiffSource: "Uncommitted changes", CreatedAt: now}
+	for _, r := range []model.Review{newer, older} {
+		if err := store.Write(r); err != nil {
+			t.Fatalf("store.Write failed: %v", err)
+		}
+	}
+
+	w, err := watcher.NewWithStore(workDir, store, discardLogger())
+	if err != nil {
+		t.Fatalf("NewWithStore failed: %v", err)
+	}
+	defer w.Close()
+
+	w.Start()
+
+	select {
+	case received := <-w.Reviews:
+		if received.Title != "Newer" {
+			t.Errorf("Title = %q, want %q", received.Title, "Newer")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for initial review")
+	}
+}
+
+func TestWatcher_IgnoresRemovalOfReviewNotBeingShown(t *testing.T) {
+	dir := t.TempDir()
+	store := createTestStore(t, dir)
+	workDir := filepath.Join(dir, "project")
+	os.MkdirAll(workDir, 0755)
+
+	now := time.Now()
+	other := model.Review{WorkingDirectory: workDir, Title: "Other", DiffSource: "Staged changes", CreatedAt: now.Add(-time.Hour)}
+	shown := model.Review{WorkingDirectory: workDir, Title: "Shown", DiffSource: "Uncommitted changes", CreatedAt: now}
+	for _, r := range []model.Review{other, shown} {
+		if err := store.Write(r); err != nil {
+			t.Fatalf("store.Write failed: %v", err)
+		}
+	}
+
+	w, err := watcher.NewWithStore(workDir, store, discardLogger())
+	if err != nil {
+		t.Fatalf("NewWithStore failed: %v", err)
+	}
+	defer w.Close()
+
+	w.Start()
+
+	select {
+	case <-w.Reviews:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for initial review")
+	}
+
+	otherPath, _ := store.PathForReview(workDir, "", "Staged changes")
+	if err := os.Remove(otherPath); err != nil {
+		t.Fatalf("failed to remove review file: %v", err)
+	}
+
+	select {
+	case <-w.Cleared:
+		t.Error("removing a review that is not shown should not clear the view")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcher_RemovalFollowsTheShownReview(t *testing.T) {
+	dir := t.TempDir()
+	store := createTestStore(t, dir)
+	workDir := filepath.Join(dir, "project")
+	os.MkdirAll(workDir, 0755)
+
+	shown := model.Review{WorkingDirectory: workDir, Title: "Shown", DiffSource: "Uncommitted changes", CreatedAt: time.Now()}
+	if err := store.Write(shown); err != nil {
+		t.Fatalf("store.Write failed: %v", err)
+	}
+
+	w, err := watcher.NewWithStore(workDir, store, discardLogger())
+	if err != nil {
+		t.Fatalf("NewWithStore failed: %v", err)
+	}
+	defer w.Close()
+
+	w.Start()
+
+	select {
+	case <-w.Reviews:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for initial review")
+	}
+
+	// Delivering another source's review does not make it the shown one
+	other := model.Review{WorkingDirectory: workDir, Title: "Other", DiffSource: "Staged changes", CreatedAt: time.Now()}
+	if err := store.Write(other); err != nil {
+		t.Fatalf("store.Write failed: %v", err)
+	}
+	select {
+	case <-w.Reviews:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the other review")
+	}
+	otherPath, _ := store.PathForReview(workDir, "", "Staged changes")
+	shownPath, _ := store.PathForReview(workDir, "", "Uncommitted changes")
+	if err := os.Remove(otherPath); err != nil {
+		t.Fatalf("failed to remove review file: %v", err)
+	}
+	select {
+	case <-w.Cleared:
+		t.Fatal("removing a review that is not shown should not clear the view")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Until the viewer shows it
+	if err := store.Write(other); err != nil {
+		t.Fatalf("store.Write failed: %v", err)
+	}
+	select {
+	case <-w.Reviews:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the other review")
+	}
+	w.Show(other)
+	if err := os.Remove(otherPath); err != nil {
+		t.Fatalf("failed to remove review file: %v", err)
+	}
+	select {
+	case <-w.Cleared:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for cleared signal")
+	}
+	if _, err := os.Stat(shownPath); err != nil {
+		t.Errorf("expected the first review to be untouched: %v", err)
+	}
+}
+
 // Helper to create a test store with isolated temp directory
 func createTestStore(t *testing.T, baseDir string) *storage.Store {
 	t.Helper()